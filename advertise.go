@@ -0,0 +1,144 @@
+package htcondor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// UpdateCommand is the collector command condor_advertise sends with the ads,
+// which determines the type of ad being updated or invalidated.
+type UpdateCommand string
+
+// Collector update and invalidation commands.
+const (
+	UpdateAdGeneric         UpdateCommand = "UPDATE_AD_GENERIC"
+	InvalidateAdsGeneric    UpdateCommand = "INVALIDATE_ADS_GENERIC"
+	UpdateStartdAd          UpdateCommand = "UPDATE_STARTD_AD"
+	InvalidateStartdAds     UpdateCommand = "INVALIDATE_STARTD_ADS"
+	UpdateScheddAd          UpdateCommand = "UPDATE_SCHEDD_AD"
+	InvalidateScheddAds     UpdateCommand = "INVALIDATE_SCHEDD_ADS"
+	UpdateMasterAd          UpdateCommand = "UPDATE_MASTER_AD"
+	InvalidateMasterAds     UpdateCommand = "INVALIDATE_MASTER_ADS"
+	UpdateSubmittorAd       UpdateCommand = "UPDATE_SUBMITTOR_AD"
+	InvalidateSubmittorAds  UpdateCommand = "INVALIDATE_SUBMITTOR_ADS"
+	UpdateNegotiatorAd      UpdateCommand = "UPDATE_NEGOTIATOR_AD"
+	InvalidateNegotiatorAds UpdateCommand = "INVALIDATE_NEGOTIATOR_ADS"
+	UpdateCollectorAd       UpdateCommand = "UPDATE_COLLECTOR_AD"
+	InvalidateCollectorAds  UpdateCommand = "INVALIDATE_COLLECTOR_ADS"
+	UpdateLicenseAd         UpdateCommand = "UPDATE_LICENSE_AD"
+	InvalidateLicenseAds    UpdateCommand = "INVALIDATE_LICENSE_ADS"
+	UpdateStorageAd         UpdateCommand = "UPDATE_STORAGE_AD"
+	InvalidateStorageAds    UpdateCommand = "INVALIDATE_STORAGE_ADS"
+	UpdateGridAd            UpdateCommand = "UPDATE_GRID_AD"
+	InvalidateGridAds       UpdateCommand = "INVALIDATE_GRID_ADS"
+	UpdateAccountingAd      UpdateCommand = "UPDATE_ACCOUNTING_AD"
+	InvalidateAccountingAds UpdateCommand = "INVALIDATE_ACCOUNTING_ADS"
+	MergeStartdAd           UpdateCommand = "MERGE_STARTD_AD"
+)
+
+// invalidateTargetTypes are the ad types removed by each invalidation command,
+// used as the TargetType of the invalidation query ad.
+var invalidateTargetTypes = map[UpdateCommand]string{
+	InvalidateAdsGeneric:    "Generic",
+	InvalidateStartdAds:     "Machine",
+	InvalidateScheddAds:     "Scheduler",
+	InvalidateMasterAds:     "DaemonMaster",
+	InvalidateSubmittorAds:  "Submitter",
+	InvalidateNegotiatorAds: "Negotiator",
+	InvalidateCollectorAds:  "Collector",
+	InvalidateLicenseAds:    "License",
+	InvalidateStorageAds:    "Storage",
+	InvalidateGridAds:       "Grid",
+	InvalidateAccountingAds: "Accounting",
+}
+
+// IsInvalidate returns true if the command invalidates ads rather than
+// updating them.
+func (u UpdateCommand) IsInvalidate() bool {
+	return strings.HasPrefix(string(u), "INVALIDATE_")
+}
+
+// AdvertiseOptions are options for Collector.Advertise.
+type AdvertiseOptions struct {
+	// Multiple sends every ad in a single condor_advertise invocation. It is
+	// set automatically when more than one ad is given.
+	Multiple bool
+	// TCP sends the updates with TCP rather than UDP.
+	TCP bool
+}
+
+// args returns the condor_advertise arguments for the options.
+func (o AdvertiseOptions) args() []string {
+	args := make([]string, 0)
+	if o.Multiple {
+		args = append(args, "-multiple")
+	}
+	if o.TCP {
+		args = append(args, "-tcp")
+	}
+	return args
+}
+
+// Advertise sends ads to the collector with condor_advertise, using the given
+// update (or invalidation) command.
+func (c *Collector) Advertise(ctx context.Context, command UpdateCommand, ads []classad.ClassAd, opts AdvertiseOptions) error {
+	if command == "" {
+		return fmt.Errorf("condor_advertise: no update command")
+	}
+	if len(ads) == 0 {
+		return fmt.Errorf("condor_advertise: no ads")
+	}
+	if len(ads) > 1 {
+		opts.Multiple = true
+	}
+
+	var in bytes.Buffer
+	if err := classad.WriteClassAds(&in, ads); err != nil {
+		return fmt.Errorf("error writing ads: %w", err)
+	}
+
+	// the ads are read from stdin, so that the arguments are the same on
+	// every run and with executors that don't share the local filesystem
+	cmd := c.Command("condor_advertise")
+	args := append(cmd.targetArgs(), opts.args()...)
+	args = append(args, string(command), "-")
+	if _, _, err := cmd.runOutput(ctx, args, &in); err != nil {
+		return fmt.Errorf("condor_advertise failed: %w", err)
+	}
+	return nil
+}
+
+// Invalidate removes the named ad from the collector with an invalidation
+// command, e.g. InvalidateAdsGeneric. For generic ads, myType is the MyType
+// the ad was published with; for other commands it may be empty to use the
+// type of ad the command applies to.
+func (c *Collector) Invalidate(ctx context.Context, command UpdateCommand, myType, name string, opts AdvertiseOptions) error {
+	if !command.IsInvalidate() {
+		return fmt.Errorf("condor_advertise: %s is not an invalidation command", command)
+	}
+	if name == "" {
+		return fmt.Errorf("condor_advertise: no ad name to invalidate")
+	}
+	return c.Advertise(ctx, command, []classad.ClassAd{invalidationAd(command, myType, name)}, opts)
+}
+
+// invalidationAd returns the query ad that invalidates the named ad.
+func invalidationAd(command UpdateCommand, myType, name string) classad.ClassAd {
+	if myType == "" {
+		myType = invalidateTargetTypes[command]
+	}
+	n := classad.Attribute{Type: classad.String, Value: name}
+	ad := classad.ClassAd{
+		"MyType":       {Type: classad.String, Value: "Query"},
+		"Name":         n,
+		"Requirements": {Type: classad.Expression, Value: "Name == " + n.Unparse()},
+	}
+	if myType != "" {
+		ad["TargetType"] = classad.Attribute{Type: classad.String, Value: myType}
+	}
+	return ad
+}
@@ -0,0 +1,50 @@
+package htcondor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestInvalidationAd(t *testing.T) {
+	ad := invalidationAd(InvalidateStartdAds, "", `slot1@host`)
+	expected := classad.ClassAd{
+		"MyType":       {Type: classad.String, Value: "Query"},
+		"TargetType":   {Type: classad.String, Value: "Machine"},
+		"Name":         {Type: classad.String, Value: "slot1@host"},
+		"Requirements": {Type: classad.Expression, Value: `Name == "slot1@host"`},
+	}
+	if !reflect.DeepEqual(ad, expected) {
+		t.Errorf("expected %+v, got %+v", expected, ad)
+	}
+	ad = invalidationAd(InvalidateAdsGeneric, "StorageHealth", "storage01")
+	if ad["TargetType"].Value != "StorageHealth" {
+		t.Errorf("expected TargetType StorageHealth, got %s", ad["TargetType"])
+	}
+}
+
+func TestAdvertiseOptionsArgs(t *testing.T) {
+	if args := (AdvertiseOptions{}).args(); len(args) != 0 {
+		t.Errorf("expected no args, got %q", args)
+	}
+	expected := []string{"-multiple", "-tcp"}
+	if args := (AdvertiseOptions{Multiple: true, TCP: true}).args(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestAdvertise_bad(t *testing.T) {
+	c := NewCollector("")
+	ctx := context.Background()
+	if err := c.Advertise(ctx, UpdateAdGeneric, nil, AdvertiseOptions{}); err == nil {
+		t.Error("expected error for no ads")
+	}
+	if err := c.Invalidate(ctx, UpdateAdGeneric, "Foo", "bar", AdvertiseOptions{}); err == nil {
+		t.Error("expected error for update command")
+	}
+	if err := c.Invalidate(ctx, InvalidateAdsGeneric, "Foo", "", AdvertiseOptions{}); err == nil {
+		t.Error("expected error for no name")
+	}
+}
@@ -0,0 +1,265 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without running the command, while the circuit
+// breaker for the command's pool is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// DefaultCircuitBreakerPolicy opens a pool's breaker after five consecutive
+// failures to reach its daemons, and probes the pool every 30 seconds.
+var DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{
+	Threshold: 5,
+	Cooldown:  30 * time.Second,
+}
+
+// CircuitBreakerPolicy configures a per-pool circuit breaker. After Threshold
+// consecutive commands against a pool fail with ErrDaemonUnreachable, the
+// breaker opens and commands fail fast with ErrCircuitOpen. After Cooldown,
+// one command is let through as a probe: if it reaches the pool the breaker
+// closes, otherwise it opens again for another Cooldown.
+//
+// Breakers are shared by every command using the same pool (-pool argument),
+// with the empty pool being the local pool. Schedd commands (condor_q,
+// condor_rm, etc.) use a separate breaker for each schedd, so that
+// unreachable schedds don't open the breaker for the rest of the pool.
+type CircuitBreakerPolicy struct {
+	// Threshold is the number of consecutive failures that opens the breaker.
+	// Defaults to 5.
+	Threshold int
+	// Cooldown is how long the breaker stays open before probing the pool.
+	// Defaults to 30 seconds.
+	Cooldown time.Duration
+}
+
+// WithCircuitBreaker sets the policy for the circuit breaker of the command's
+// pool, e.g. WithCircuitBreaker(DefaultCircuitBreakerPolicy).
+func (c *Command) WithCircuitBreaker(policy CircuitBreakerPolicy) *Command {
+	c.opts.breaker = &policy
+	return c
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+// Circuit breaker states.
+const (
+	// CircuitClosed lets commands run.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails commands without running them.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe command run.
+	CircuitHalfOpen
+)
+
+var circuitStateNames = map[CircuitState]string{
+	CircuitClosed:   "closed",
+	CircuitOpen:     "open",
+	CircuitHalfOpen: "half-open",
+}
+
+// String returns the name of the state.
+func (s CircuitState) String() string {
+	if name, ok := circuitStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// PoolCircuitState returns the state of the circuit breaker for a pool, which
+// is closed if no command with a circuit breaker has run against it.
+func PoolCircuitState(pool string) CircuitState {
+	return circuitState(breakerKey{pool: pool})
+}
+
+// ScheddCircuitState returns the state of the circuit breaker for the named
+// schedd in a pool, with the empty name being the local schedd. It is closed
+// if no command with a circuit breaker has run against the schedd.
+func ScheddCircuitState(pool, name string) CircuitState {
+	return circuitState(scheddBreakerKey(pool, name))
+}
+
+func circuitState(key breakerKey) CircuitState {
+	breakersMu.Lock()
+	b, ok := breakers[key]
+	breakersMu.Unlock()
+	if !ok {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// localSchedd is the schedd name of the breaker for the local schedd.
+const localSchedd = "(local)"
+
+// breakerKey identifies a circuit breaker: a pool, or a schedd in a pool.
+type breakerKey struct {
+	pool   string
+	schedd string
+}
+
+// scheddBreakerKey returns the key of the breaker for a schedd.
+func scheddBreakerKey(pool, name string) breakerKey {
+	if name == "" {
+		name = localSchedd
+	}
+	return breakerKey{pool: pool, schedd: name}
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[breakerKey]*circuitBreaker)
+)
+
+// circuitBreaker tracks the failures of commands against a pool or schedd.
+type circuitBreaker struct {
+	key      breakerKey
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// breakerFor returns the circuit breaker for a pool or schedd.
+func breakerFor(key breakerKey) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[key]
+	if !ok {
+		b = &circuitBreaker{key: key}
+		breakers[key] = b
+		CircuitBreakerState.WithLabelValues(key.pool, key.schedd).Set(float64(CircuitClosed))
+	}
+	return b
+}
+
+// allow returns nil if a command may run, or an error matching ErrCircuitOpen
+// if not.
+func (b *circuitBreaker) allow(policy *CircuitBreakerPolicy) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cooldown := policy.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitBreakerPolicy.Cooldown
+	}
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= cooldown {
+		b.setState(CircuitHalfOpen)
+	}
+	switch {
+	case b.state == CircuitOpen, b.state == CircuitHalfOpen && b.probing:
+		if b.key.schedd != "" {
+			return fmt.Errorf("schedd \"%s\" in pool \"%s\": %w", b.key.schedd, b.key.pool, ErrCircuitOpen)
+		}
+		return fmt.Errorf("pool \"%s\": %w", b.key.pool, ErrCircuitOpen)
+	case b.state == CircuitHalfOpen:
+		b.probing = true
+	}
+	return nil
+}
+
+// record records the outcome of a command that was allowed to run.
+func (b *circuitBreaker) record(policy *CircuitBreakerPolicy, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing
+	b.probing = false
+	switch {
+	case errors.Is(err, ErrDaemonUnreachable):
+		b.failures++
+		threshold := policy.Threshold
+		if threshold <= 0 {
+			threshold = DefaultCircuitBreakerPolicy.Threshold
+		}
+		if probe || b.failures >= threshold {
+			b.openedAt = time.Now()
+			b.setState(CircuitOpen)
+		}
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// the command was abandoned, which says nothing about the pool
+	default:
+		// the pool was reached, even if the command failed
+		b.failures = 0
+		b.setState(CircuitClosed)
+	}
+}
+
+func (b *circuitBreaker) setState(state CircuitState) {
+	b.state = state
+	CircuitBreakerState.WithLabelValues(b.key.pool, b.key.schedd).Set(float64(state))
+}
+
+// poolName returns the pool the command runs against, which for commands
+// decoded from a cache key is in the arguments.
+func (c *Command) poolName() string {
+	return c.argValue("-pool", c.Pool)
+}
+
+// breakerKey returns the key of the command's circuit breaker: its schedd's
+// for schedd commands, otherwise its pool's.
+func (c *Command) breakerKey() breakerKey {
+	if scheddCommands[c.Command] {
+		return scheddBreakerKey(c.poolName(), c.argValue("-name", c.Name))
+	}
+	return breakerKey{pool: c.poolName()}
+}
+
+// argValue returns value if it is set, otherwise the value of the flag in the
+// arguments, for commands decoded from a cache key.
+func (c *Command) argValue(flag, value string) string {
+	if value != "" {
+		return value
+	}
+	for i, arg := range c.Args {
+		if arg == flag && i+1 < len(c.Args) {
+			return c.Args[i+1]
+		}
+	}
+	return ""
+}
+
+// admit checks the command's circuit breaker, if it has one, returning an
+// error matching ErrCircuitOpen if the command may not run, or a function to
+// record the outcome of the command if it may.
+func (c *Command) admit() (func(error), error) {
+	if c.opts.breaker == nil {
+		return func(error) {}, nil
+	}
+	b := breakerFor(c.breakerKey())
+	if err := b.allow(c.opts.breaker); err != nil {
+		return nil, err
+	}
+	return func(err error) {
+		b.record(c.opts.breaker, err)
+	}, nil
+}
+
+// executeOnce runs the command once with its executor, subject to the
+// concurrency limits and its circuit breaker.
+func (c *Command) executeOnce(ctx context.Context, args []string, stdin io.Reader) (*Result, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return &Result{}, err
+	}
+	defer release()
+	record, err := c.admit()
+	if err != nil {
+		return &Result{}, err
+	}
+	res, err := c.executor().Run(ctx, c.Command, args, stdin)
+	if res == nil {
+		res = &Result{}
+	}
+	err = c.commandError(res, err)
+	record(err)
+	return res, err
+}
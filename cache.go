@@ -0,0 +1,105 @@
+package htcondor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// Cache caches the output of commands, keyed by the command and its
+// arguments. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the cached value for key. On a miss it calls load to get
+	// the value, and caches it for ttl (if ttl is zero it never expires,
+	// though it may be evicted).
+	Get(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) ([]byte, error)
+	// Set caches value for key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// WithCacheBackend sets the cache for the command's output. Set cacheLifetime
+// to 0 to *never* expire cached queries (unless they are evicted).
+func (c *Command) WithCacheBackend(cache Cache, cacheLifetime time.Duration) *Command {
+	c.cache = cache
+	c.cacheLifetime = cacheLifetime
+	return c
+}
+
+// GroupCache is a Cache backed by a groupcache group, which is shared with
+// the peers in the groupcache HTTPPool, if any. Since groupcache entries
+// can't expire, values are cached in time buckets of the ttl, i.e. a value
+// cached for a minute expires at the end of the minute, not a minute after
+// it was loaded.
+type GroupCache struct {
+	group *groupcache.Group
+}
+
+// NewGroupCache returns a GroupCache for the named groupcache group, creating
+// it with cacheBytes if it doesn't exist. Values requested by peers are
+// loaded by running the command decoded from the key with DefaultExecutor.
+func NewGroupCache(group string, cacheBytes int64) *GroupCache {
+	return newGroupCache(group, cacheBytes, runOptions{})
+}
+
+// newGroupCache returns a GroupCache for the named groupcache group, creating
+// it with cacheBytes if it doesn't exist. Values requested by peers are
+// loaded by running the command decoded from the key with opts.
+func newGroupCache(group string, cacheBytes int64, opts runOptions) *GroupCache {
+	g := groupcache.GetGroup(group)
+	if g == nil {
+		g = groupcache.NewGroup(group, cacheBytes, groupGetter(opts))
+	}
+	return &GroupCache{group: g}
+}
+
+// loaderKey is the context key for the function to load a value on a
+// groupcache miss.
+type loaderKey struct{}
+
+// groupGetter returns a groupcache.GetterFunc that loads a value with the
+// loader passed to GroupCache.Get in the context or, for requests from peers,
+// by running the command decoded from the key with opts.
+func groupGetter(opts runOptions) groupcache.GetterFunc {
+	getter := commandGetter(opts)
+	return func(ctx context.Context, key string, dest groupcache.Sink) error {
+		if load, ok := ctx.Value(loaderKey{}).(func(context.Context) ([]byte, error)); ok {
+			out, err := load(ctx)
+			if err != nil {
+				return err
+			}
+			return dest.SetBytes(out)
+		}
+		// strip the time bucket
+		_, key, _ = strings.Cut(key, keySeparator)
+		return getter(ctx, key, dest)
+	}
+}
+
+// groupKey returns the groupcache key for key in the current time bucket for
+// ttl.
+func groupKey(key string, ttl time.Duration) string {
+	bucket := "0"
+	if ttl > 0 {
+		bucket = time.Now().Truncate(ttl).Format(time.RFC3339)
+	}
+	return bucket + keySeparator + key
+}
+
+// Get returns the value for key from the group, calling load on a miss.
+func (g *GroupCache) Get(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	var value []byte
+	ctx = context.WithValue(ctx, loaderKey{}, load)
+	err := g.group.Get(ctx, groupKey(key, ttl), groupcache.AllocatingByteSliceSink(&value))
+	return value, err
+}
+
+// Set caches value for key, unless it is already cached, since groupcache
+// values can't be replaced.
+func (g *GroupCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := g.Get(ctx, key, ttl, func(context.Context) ([]byte, error) {
+		return value, nil
+	})
+	return err
+}
@@ -0,0 +1,216 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// countingLoader returns a loader that returns value, counting its calls.
+func countingLoader(value string, calls *int) func(context.Context) ([]byte, error) {
+	return func(context.Context) ([]byte, error) {
+		*calls++
+		return []byte(value), nil
+	}
+}
+
+func testCache(t *testing.T, cache Cache) {
+	t.Helper()
+	ctx := context.Background()
+	calls := 0
+	for i := 0; i < 2; i++ {
+		value, err := cache.Get(ctx, "key", 0, countingLoader("value", &calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != "value" {
+			t.Errorf("expected value, got %q", value)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 load, got %d", calls)
+	}
+
+	if err := cache.Set(ctx, "set", []byte("stored"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := cache.Get(ctx, "set", 0, countingLoader("loaded", &calls)); err != nil || string(value) != "stored" {
+		t.Errorf("expected stored value, got %q (%v)", value, err)
+	}
+
+	failed := errors.New("failed")
+	_, err := cache.Get(ctx, "error", 0, func(context.Context) ([]byte, error) { return nil, failed })
+	if !errors.Is(err, failed) {
+		t.Errorf("expected load error, got %v", err)
+	}
+	if value, err := cache.Get(ctx, "error", 0, countingLoader("retried", &calls)); err != nil || string(value) != "retried" {
+		t.Errorf("expected failed load not to be cached, got %q (%v)", value, err)
+	}
+}
+
+// testCacheCancel checks that a load shared by concurrent misses carries on
+// and fills the cache when the caller that started it is cancelled.
+func testCacheCancel(t *testing.T, cache Cache, loads *loadGroup) {
+	t.Helper()
+	started, unblock := make(chan struct{}), make(chan struct{})
+	load := func(ctx context.Context) ([]byte, error) {
+		close(started)
+		select {
+		case <-unblock:
+			return []byte("shared"), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := cache.Get(ctx, "shared", 0, load)
+		first <- err
+	}()
+	<-started
+	second := make(chan error)
+	go func() {
+		value, err := cache.Get(context.Background(), "shared", 0, load)
+		if err == nil && string(value) != "shared" {
+			err = fmt.Errorf("expected shared value, got %q", value)
+		}
+		second <- err
+	}()
+	waitFor(t, "shared load", func() bool { return loads.waiting("shared") == 2 })
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	close(unblock)
+	if err := <-second; err != nil {
+		t.Error(err)
+	}
+	calls := 0
+	if value, err := cache.Get(context.Background(), "shared", 0, countingLoader("other", &calls)); err != nil || string(value) != "shared" || calls != 0 {
+		t.Errorf("expected shared value to be cached, got %q after %d loads (%v)", value, calls, err)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	testCache(t, NewLRUCache(1<<20))
+	lru := NewLRUCache(1 << 20)
+	testCacheCancel(t, lru, &lru.loads)
+
+	ctx := context.Background()
+	cache := NewLRUCache(20)
+	cache.Set(ctx, "a", []byte("123456789"), 0)
+	cache.Set(ctx, "b", []byte("123456789"), 0)
+	cache.Get(ctx, "a", 0, nil)
+	cache.Set(ctx, "c", []byte("123456789"), 0)
+	calls := 0
+	for _, key := range []string{"a", "c"} {
+		cache.Get(ctx, key, 0, countingLoader("", &calls))
+	}
+	if calls != 0 {
+		t.Errorf("expected a and c to be cached, got %d loads", calls)
+	}
+	cache.Get(ctx, "b", 0, countingLoader("", &calls))
+	if calls != 1 {
+		t.Errorf("expected b to be evicted, got %d loads", calls)
+	}
+
+	cache.Set(ctx, "short", []byte("x"), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if value, _ := cache.Get(ctx, "short", 0, countingLoader("y", &calls)); string(value) != "y" {
+		t.Errorf("expected expired value to be reloaded, got %q", value)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCache(t, cache)
+	testCacheCancel(t, cache, &cache.loads)
+
+	// values survive a new cache on the same directory
+	ctx := context.Background()
+	cache, err = NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	if value, err := cache.Get(ctx, "key", 0, countingLoader("other", &calls)); err != nil || string(value) != "value" || calls != 0 {
+		t.Errorf("expected cached value from disk, got %q after %d loads (%v)", value, calls, err)
+	}
+
+	cache.Set(ctx, "short", []byte("x"), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if value, _ := cache.Get(ctx, "short", 0, countingLoader("y", &calls)); string(value) != "y" {
+		t.Errorf("expected expired value to be reloaded, got %q", value)
+	}
+
+	// values that can't be read are loaded instead
+	if err := os.Mkdir(cache.path("unreadable"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := cache.Get(ctx, "unreadable", 0, countingLoader("z", &calls)); err != nil || string(value) != "z" {
+		t.Errorf("expected unreadable value to be loaded, got %q (%v)", value, err)
+	}
+}
+
+func TestGroupCache(t *testing.T) {
+	// groups can't be removed, so use a new one each run
+	testCache(t, NewGroupCache(fmt.Sprintf("test_group_cache_%d", time.Now().UnixNano()), 1<<20))
+
+	// peers load by running the command decoded from the key
+	fake := &fakeExecutor{result: Result{Stdout: []byte("Name = \"slot1@host\"\n")}}
+	cmd := NewCommand("condor_status").WithPool("pool").WithAttribute("Name")
+	var value []byte
+	err := groupGetter(runOptions{runner: fake})(context.Background(), groupKey(cmd.encodeKey(), 0), groupcache.AllocatingByteSliceSink(&value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "Name = \"slot1@host\"\n" {
+		t.Errorf("unexpected value %q", value)
+	}
+	if got := strings.Join(fake.calls[0], " "); got != "condor_status -pool pool -af:lrng Name" {
+		t.Errorf("unexpected command %q", got)
+	}
+}
+
+func TestCommandCacheBackend(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeExecutor{result: Result{Stdout: []byte("Name = \"slot1@host\"\n\nName = \"slot2@host\"\n")}}
+	cmd := NewCommand("condor_status").WithExecutor(fake).WithCacheBackend(NewLRUCache(1<<20), time.Minute)
+	for i := 0; i < 2; i++ {
+		ads, err := cmd.Copy().RunWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ads) != 2 {
+			t.Errorf("expected 2 ads, got %d", len(ads))
+		}
+	}
+	ch := make(chan classad.ClassAd)
+	errs := make(chan error)
+	go cmd.StreamWithContext(ctx, ch, errs)
+	n := 0
+	for range ch {
+		n++
+	}
+	for err := range errs {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 streamed ads, got %d", n)
+	}
+	if len(fake.calls) != 1 {
+		t.Errorf("expected 1 command run, got %d", len(fake.calls))
+	}
+}
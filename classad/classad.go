@@ -2,9 +2,11 @@ package classad
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,6 +26,8 @@ const (
 	String
 	Undefined
 	Error
+	Boolean
+	Expression
 )
 
 // Attribute represents a typed Classad attribute.
@@ -55,6 +59,44 @@ func AttributeFromString(val string) Attribute {
 	}
 }
 
+// ParseAttribute parses an attribute value as written in the right-hand side
+// of a "long" format ClassAd.
+//
+// Unlike AttributeFromString it distinguishes between string literals and
+// unquoted values: quoted strings are unescaped and returned as String,
+// true/false as Boolean, undefined and error as Undefined and Error, and
+// anything else that is not a number is returned as an unevaluated
+// Expression.
+func ParseAttribute(val string) Attribute {
+	val = strings.TrimSpace(val)
+	if len(val) == 0 {
+		return Attribute{Type: Error}
+	}
+	if val[0] == '"' {
+		if s, ok := unquote(val); ok {
+			return Attribute{Type: String, Value: s}
+		}
+		return Attribute{Type: Expression, Value: val}
+	}
+	if ival, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return Attribute{Type: Integer, Value: ival}
+	}
+	if fval, err := strconv.ParseFloat(val, 64); err == nil {
+		return Attribute{Type: Real, Value: fval}
+	}
+	switch strings.ToLower(val) {
+	case "true":
+		return Attribute{Type: Boolean, Value: true}
+	case "false":
+		return Attribute{Type: Boolean, Value: false}
+	case "undefined":
+		return Attribute{Type: Undefined}
+	case "error":
+		return Attribute{Type: Error}
+	}
+	return Attribute{Type: Expression, Value: val}
+}
+
 // String returns the string representation of the ClassAd attribute.
 func (a Attribute) String() string {
 	switch a.Type {
@@ -68,10 +110,91 @@ func (a Attribute) String() string {
 		return "UNDEFINED"
 	case Error:
 		return "ERROR"
+	case Boolean:
+		v, _ := a.Value.(bool)
+		return strconv.FormatBool(v)
+	case Expression:
+		return fmt.Sprintf("%s", a.Value)
 	}
 	return "TYPEERROR"
 }
 
+// Unparse returns the attribute in ClassAd syntax, as it would be written on
+// the right-hand side of a "long" format ClassAd: strings are quoted and
+// escaped, expressions are written as-is, and reals always have a decimal
+// point or exponent so they are not read back as integers.
+func (a Attribute) Unparse() string {
+	switch a.Type {
+	case Integer:
+		return fmt.Sprintf("%d", a.Value)
+	case Real:
+		f, ok := a.Value.(float64)
+		if !ok {
+			return fmt.Sprintf("%v", a.Value)
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+		return s
+	case String:
+		return quote(fmt.Sprintf("%s", a.Value))
+	case Undefined:
+		return "undefined"
+	case Error:
+		return "error"
+	case Boolean:
+		v, _ := a.Value.(bool)
+		return strconv.FormatBool(v)
+	case Expression:
+		return fmt.Sprintf("%s", a.Value)
+	}
+	return "error"
+}
+
+// Int64 returns the value of a numeric attribute as an integer. Real values are
+// truncated.
+func (a Attribute) Int64() (int64, error) {
+	switch a.Type {
+	case Integer:
+		if v, ok := a.Value.(int64); ok {
+			return v, nil
+		}
+	case Real:
+		if v, ok := a.Value.(float64); ok {
+			return int64(v), nil
+		}
+	}
+	return 0, fmt.Errorf("attribute is not numeric: %s", a)
+}
+
+// Bool returns the value of a boolean attribute. Numeric values are true if
+// non-zero, and the strings "true" and "false" (as returned for unquoted
+// values by AttributeFromString) are accepted.
+func (a Attribute) Bool() (bool, error) {
+	switch a.Type {
+	case Boolean:
+		if v, ok := a.Value.(bool); ok {
+			return v, nil
+		}
+	case Integer:
+		if v, ok := a.Value.(int64); ok {
+			return v != 0, nil
+		}
+	case Real:
+		if v, ok := a.Value.(float64); ok {
+			return v != 0, nil
+		}
+	case String:
+		if v, ok := a.Value.(string); ok {
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return false, fmt.Errorf("attribute is not boolean: %s", a)
+}
+
 // MarshalJSON returns the attribute as a JSON value.
 func (a Attribute) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a.Value)
@@ -114,6 +237,37 @@ func ReadClassAds(r io.Reader) ([]ClassAd, error) {
 	return ads, nil
 }
 
+// WriteTo writes the ClassAd in "long" format, one "Name = value" line per
+// attribute, sorted by name. Values are written with Attribute.Unparse.
+func (c ClassAd) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(c))
+	for k := range c {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s = %s\n", k, c[k].Unparse())
+	}
+	return b.WriteTo(w)
+}
+
+// WriteClassAds writes multiple ClassAds in "long" format to w, separated by
+// blank lines, so they can be read back with ReadClassAds.
+func WriteClassAds(w io.Writer, ads []ClassAd) error {
+	for i, ad := range ads {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := ad.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // MapStringStringToClassAd converts a map[string]string to a ClassAd.
 // It will attempt to convert values to numeric Types when appropriate. For example, a string value of "42"
 // will be converted to Attribute{Type: Integer, Value: 42}
@@ -164,6 +318,20 @@ func StreamClassAds(r io.Reader, ch chan ClassAd, errors chan error) {
 	}
 }
 
+// Lookup returns the named attribute. Attribute names are case-insensitive,
+// as in HTCondor, but an exact match is preferred.
+func (c ClassAd) Lookup(name string) (Attribute, bool) {
+	if a, ok := c[name]; ok {
+		return a, true
+	}
+	for k, a := range c {
+		if strings.EqualFold(k, name) {
+			return a, true
+		}
+	}
+	return Attribute{Type: Undefined}, false
+}
+
 // Strings returns a map of the string representation for all the attributes in the ClassAd.
 func (c ClassAd) Strings() map[string]string {
 	ad := make(map[string]string, len(c))
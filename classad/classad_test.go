@@ -284,3 +284,121 @@ LastJobLeaseRenewal = 1486423593
 JobsubClientDN = "/DC=org/DC=cilogon/C=US/O=Fermi National Accelerator Laboratory/OU=People/CN=Paul Lebrun/CN=UID:lebrun"
 LastRemoteHost = "slot1@glidein_3386316_395885625@fnpc9051.fnal.gov"
 `
+
+func TestUnparse(t *testing.T) {
+	tests := []struct {
+		attr     Attribute
+		expected string
+	}{
+		{Attribute{Type: Integer, Value: int64(42)}, "42"},
+		{Attribute{Type: Real, Value: 2.0}, "2.0"},
+		{Attribute{Type: Real, Value: 0.25}, "0.25"},
+		{Attribute{Type: Real, Value: 1e30}, "1e+30"},
+		{Attribute{Type: String, Value: `say "hi"\now`}, `"say \"hi\"\\now"`},
+		{Attribute{Type: Boolean, Value: true}, "true"},
+		{Attribute{Type: Undefined}, "undefined"},
+		{Attribute{Type: Error}, "error"},
+		{Attribute{Type: Expression, Value: "RequestMemory * 2"}, "RequestMemory * 2"},
+	}
+	for _, tt := range tests {
+		s := tt.attr.Unparse()
+		if s != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, s)
+		}
+		if tt.attr.Type != Expression {
+			if a := ParseAttribute(s); !reflect.DeepEqual(a, tt.attr) {
+				t.Errorf("%s: round trip got %+v", s, a)
+			}
+		}
+	}
+	// zero values are formatted as the zero value of the type
+	if s := (Attribute{Type: Boolean}).Unparse(); s != "false" {
+		t.Errorf("expected false, got %s", s)
+	}
+	if s := (Attribute{Type: Boolean}).String(); s != "false" {
+		t.Errorf("expected false, got %s", s)
+	}
+}
+
+func TestWriteClassAds(t *testing.T) {
+	ads := []ClassAd{
+		{
+			"Name":    {Type: String, Value: "storage01"},
+			"MyType":  {Type: String, Value: "StorageHealth"},
+			"FreeTB":  {Type: Real, Value: 12.5},
+			"Healthy": {Type: Boolean, Value: true},
+		},
+		{
+			"Name":     {Type: String, Value: "license"},
+			"Count":    {Type: Integer, Value: int64(3)},
+			"Required": {Type: Expression, Value: "Count > 0"},
+		},
+	}
+	var b strings.Builder
+	if err := WriteClassAds(&b, ads); err != nil {
+		t.Fatal(err)
+	}
+	expected := `FreeTB = 12.5
+Healthy = true
+MyType = "StorageHealth"
+Name = "storage01"
+
+Count = 3
+Name = "license"
+Required = Count > 0
+`
+	if b.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+	read, err := ReadClassAds(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 || read[0]["Name"].Value != "storage01" || read[1]["Count"].Value != int64(3) {
+		t.Errorf("unexpected ads read back: %+v", read)
+	}
+}
+
+func TestAttributeInt64(t *testing.T) {
+	if i, err := (Attribute{Type: Integer, Value: int64(42)}).Int64(); err != nil || i != 42 {
+		t.Errorf("expected 42, got %d (%v)", i, err)
+	}
+	if i, err := (Attribute{Type: Real, Value: 2.5}).Int64(); err != nil || i != 2 {
+		t.Errorf("expected 2, got %d (%v)", i, err)
+	}
+	// values of the wrong type are an error rather than a panic
+	for _, a := range []Attribute{
+		{Type: Integer},
+		{Type: Integer, Value: 5},
+		{Type: Real, Value: "2.5"},
+		{Type: String, Value: "42"},
+	} {
+		if _, err := a.Int64(); err == nil {
+			t.Errorf("%#v: expected error", a)
+		}
+	}
+}
+
+func TestAttributeBool(t *testing.T) {
+	for _, a := range []Attribute{
+		{Type: Boolean, Value: true},
+		{Type: Integer, Value: int64(1)},
+		{Type: Real, Value: 0.5},
+		{Type: String, Value: "true"},
+	} {
+		if b, err := a.Bool(); err != nil || !b {
+			t.Errorf("%#v: expected true, got %v (%v)", a, b, err)
+		}
+	}
+	// values of the wrong type are an error rather than a panic
+	for _, a := range []Attribute{
+		{Type: Boolean},
+		{Type: Integer, Value: 1},
+		{Type: Real},
+		{Type: String, Value: 1},
+	} {
+		if _, err := a.Bool(); err == nil {
+			t.Errorf("%#v: expected error", a)
+		}
+	}
+}
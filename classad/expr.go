@@ -0,0 +1,1059 @@
+package classad
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxEvalDepth limits how deeply attribute references are followed, to guard
+// against self-referencing attributes.
+const maxEvalDepth = 32
+
+// Expr is a parsed ClassAd expression, such as a job constraint.
+//
+// Only a subset of the ClassAd language is supported: literals, attribute
+// references (optionally scoped with MY. or TARGET., which both refer to the
+// ad being evaluated), the arithmetic, comparison, logical and meta-equality
+// operators, the ternary operator, and a selection of common built-in
+// functions. Lists and nested ClassAds are not supported.
+type Expr struct {
+	src  string
+	root node
+}
+
+// ParseExpr parses a ClassAd expression.
+func ParseExpr(s string) (*Expr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{toks: toks}
+	n, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q in expression \"%s\"", p.peek().text, s)
+	}
+	return &Expr{src: s, root: n}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against ad. Attribute references that are not
+// in ad evaluate to Undefined.
+func (e *Expr) Eval(ad ClassAd) Attribute {
+	return e.root.eval(&env{ad: ad})
+}
+
+// Matches returns true if the expression evaluates to true (or a non-zero
+// number) against ad, i.e. ad satisfies the expression as a constraint.
+func (e *Expr) Matches(ad ClassAd) bool {
+	b, ok := truth(e.Eval(ad))
+	return ok && b
+}
+
+// EvalAttribute evaluates the named attribute, following any attribute
+// references in its expression. Missing attributes evaluate to Undefined.
+func (c ClassAd) EvalAttribute(name string) Attribute {
+	return (&env{ad: c}).lookup(name)
+}
+
+type env struct {
+	ad    ClassAd
+	depth int
+}
+
+// lookup resolves an attribute reference, evaluating expression attributes.
+func (e *env) lookup(name string) Attribute {
+	a, ok := e.ad.Lookup(name)
+	if !ok {
+		return undefined
+	}
+	if a.Type != Expression {
+		return checked(a)
+	}
+	if e.depth >= maxEvalDepth {
+		return errorValue
+	}
+	x, err := ParseExpr(fmt.Sprintf("%s", a.Value))
+	if err != nil {
+		return errorValue
+	}
+	return x.root.eval(&env{ad: e.ad, depth: e.depth + 1})
+}
+
+var (
+	undefined  = Attribute{Type: Undefined}
+	errorValue = Attribute{Type: Error}
+)
+
+func boolValue(b bool) Attribute {
+	return Attribute{Type: Boolean, Value: b}
+}
+
+// checked returns a, or Error if its value is not of its type, e.g. a
+// zero-value or hand-built attribute, so that it can't upset the evaluator.
+func checked(a Attribute) Attribute {
+	var ok bool
+	switch a.Type {
+	case Integer:
+		_, ok = intValue(a)
+	case Real:
+		_, ok = realValue(a)
+	case String:
+		_, ok = stringValue(a)
+	case Boolean:
+		_, ok = boolOf(a)
+	case Undefined, Error:
+		return Attribute{Type: a.Type}
+	}
+	if !ok {
+		return errorValue
+	}
+	return a
+}
+
+// intValue returns the value of an Integer attribute, and whether it is one.
+func intValue(a Attribute) (int64, bool) {
+	v, ok := a.Value.(int64)
+	return v, ok && a.Type == Integer
+}
+
+// realValue returns the value of a Real attribute, and whether it is one.
+func realValue(a Attribute) (float64, bool) {
+	v, ok := a.Value.(float64)
+	return v, ok && a.Type == Real
+}
+
+// stringValue returns the value of a String attribute, and whether it is one.
+func stringValue(a Attribute) (string, bool) {
+	v, ok := a.Value.(string)
+	return v, ok && a.Type == String
+}
+
+// boolOf returns the value of a Boolean attribute, and whether it is one.
+func boolOf(a Attribute) (bool, bool) {
+	v, ok := a.Value.(bool)
+	return v, ok && a.Type == Boolean
+}
+
+// truth returns the boolean value of a, and whether it has one.
+func truth(a Attribute) (bool, bool) {
+	if b, ok := boolOf(a); ok {
+		return b, true
+	}
+	if f, _, ok := number(a); ok {
+		return f != 0, true
+	}
+	return false, false
+}
+
+// number returns the numeric value of a, whether it is an integer, and
+// whether it is numeric at all. Booleans are treated as 0 or 1.
+func number(a Attribute) (float64, bool, bool) {
+	if i, ok := intValue(a); ok {
+		return float64(i), true, true
+	}
+	if f, ok := realValue(a); ok {
+		return f, false, true
+	}
+	if b, ok := boolOf(a); ok {
+		if b {
+			return 1, true, true
+		}
+		return 0, true, true
+	}
+	return 0, false, false
+}
+
+// strictness returns Error or Undefined if either value is, so that most
+// operators propagate them.
+func strictness(x, y Attribute) (Attribute, bool) {
+	if x.Type == Error || y.Type == Error {
+		return errorValue, true
+	}
+	if x.Type == Undefined || y.Type == Undefined {
+		return undefined, true
+	}
+	return Attribute{}, false
+}
+
+//
+// Lexer
+//
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokInt
+	tokReal
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	val  interface{}
+}
+
+var operators = []string{
+	"=?=", "=!=", "==", "!=", "<=", ">=", "&&", "||",
+	"(", ")", ",", "?", ":", "!", "<", ">", "+", "-", "*", "/", "%", ".",
+}
+
+func lex(s string) ([]token, error) {
+	toks := make([]token, 0)
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string in expression \"%s\"", s)
+			}
+			str, _ := unquote(s[i : j+1])
+			toks = append(toks, token{kind: tokString, text: s[i : j+1], val: str})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i
+			isReal := false
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				if s[j] == '.' {
+					isReal = true
+				}
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && s[k] >= '0' && s[k] <= '9' {
+					isReal = true
+					j = k
+					for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+						j++
+					}
+				}
+			}
+			text := s[i:j]
+			if isReal {
+				v, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q in expression \"%s\"", text, s)
+				}
+				toks = append(toks, token{kind: tokReal, text: text, val: v})
+			} else {
+				v, err := strconv.ParseInt(text, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q in expression \"%s\"", text, s)
+				}
+				toks = append(toks, token{kind: tokInt, text: text, val: v})
+			}
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: s[i:j]})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(s[i:], op) {
+					toks = append(toks, token{kind: tokOp, text: op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q in expression \"%s\"", c, s)
+			}
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+// quote returns s as a ClassAd string literal.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// unquote unescapes a double-quoted ClassAd string literal, returning false if
+// s is not a single complete literal.
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return "", false
+		case c == '\\' && i+1 < len(s)-1:
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
+
+//
+// Parser
+//
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given operators or
+// keywords.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	for _, op := range ops {
+		if (t.kind == tokOp && t.text == op) || (t.kind == tokIdent && strings.EqualFold(t.text, op)) {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return fmt.Errorf("expected %q, got %q", op, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) ternary() (node, error) {
+	c, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return c, nil
+	}
+	t, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	f, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{c, t, f}, nil
+}
+
+// precedence lists the binary operators from lowest to highest precedence.
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "=?=", "=!=", "isnt", "is"},
+	{"<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(precedence[level]...)
+		if !ok {
+			return x, nil
+		}
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op, x, y}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if op, ok := p.accept("!", "-", "+"); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op, x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt:
+		return &literalNode{Attribute{Type: Integer, Value: t.val}}, nil
+	case tokReal:
+		return &literalNode{Attribute{Type: Real, Value: t.val}}, nil
+	case tokString:
+		return &literalNode{Attribute{Type: String, Value: t.val}}, nil
+	case tokOp:
+		if t.text == "(" {
+			n, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		}
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return &literalNode{boolValue(true)}, nil
+		case "false":
+			return &literalNode{boolValue(false)}, nil
+		case "undefined":
+			return &literalNode{undefined}, nil
+		case "error":
+			return &literalNode{errorValue}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			args := make([]node, 0)
+			if _, ok := p.accept(")"); ok {
+				return &callNode{strings.ToLower(t.text), args}, nil
+			}
+			for {
+				arg, err := p.ternary()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if _, ok := p.accept(")"); ok {
+					return &callNode{strings.ToLower(t.text), args}, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+		scope := strings.ToLower(t.text)
+		if (scope == "my" || scope == "target") && p.peek().kind == tokOp && p.peek().text == "." {
+			p.next()
+			attr := p.next()
+			if attr.kind != tokIdent {
+				return nil, fmt.Errorf("expected attribute name after %s., got %q", t.text, attr.text)
+			}
+			return &attrNode{attr.text}, nil
+		}
+		return &attrNode{t.text}, nil
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q in expression", t.text)
+}
+
+//
+// Evaluation
+//
+
+type node interface {
+	eval(e *env) Attribute
+}
+
+type literalNode struct {
+	val Attribute
+}
+
+func (n *literalNode) eval(e *env) Attribute {
+	return n.val
+}
+
+type attrNode struct {
+	name string
+}
+
+func (n *attrNode) eval(e *env) Attribute {
+	return e.lookup(n.name)
+}
+
+type condNode struct {
+	c, t, f node
+}
+
+func (n *condNode) eval(e *env) Attribute {
+	c := n.c.eval(e)
+	if c.Type == Undefined || c.Type == Error {
+		return c
+	}
+	b, ok := truth(c)
+	if !ok {
+		return errorValue
+	}
+	if b {
+		return n.t.eval(e)
+	}
+	return n.f.eval(e)
+}
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n *unaryNode) eval(e *env) Attribute {
+	x := n.x.eval(e)
+	if x.Type == Undefined || x.Type == Error {
+		return x
+	}
+	switch n.op {
+	case "!":
+		if b, ok := truth(x); ok {
+			return boolValue(!b)
+		}
+	case "-":
+		if i, ok := intValue(x); ok {
+			return Attribute{Type: Integer, Value: -i}
+		}
+		if f, ok := realValue(x); ok {
+			return Attribute{Type: Real, Value: -f}
+		}
+	case "+":
+		if x.Type == Integer || x.Type == Real {
+			return x
+		}
+	}
+	return errorValue
+}
+
+type binaryNode struct {
+	op   string
+	x, y node
+}
+
+func (n *binaryNode) eval(e *env) Attribute {
+	switch n.op {
+	case "&&":
+		return evalAnd(n.x.eval(e), func() Attribute { return n.y.eval(e) })
+	case "||":
+		return evalOr(n.x.eval(e), func() Attribute { return n.y.eval(e) })
+	}
+	x, y := n.x.eval(e), n.y.eval(e)
+	switch n.op {
+	case "=?=", "is":
+		return boolValue(identical(x, y))
+	case "=!=", "isnt":
+		return boolValue(!identical(x, y))
+	}
+	if v, ok := strictness(x, y); ok {
+		return v
+	}
+	switch n.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return compare(n.op, x, y)
+	}
+	return arithmetic(n.op, x, y)
+}
+
+func evalAnd(x Attribute, y func() Attribute) Attribute {
+	if x.Type == Error {
+		return x
+	}
+	if x.Type != Undefined {
+		b, ok := truth(x)
+		if !ok {
+			return errorValue
+		}
+		if !b {
+			return boolValue(false)
+		}
+	}
+	yv := y()
+	if yv.Type == Error {
+		return yv
+	}
+	if yv.Type == Undefined {
+		return undefined
+	}
+	b, ok := truth(yv)
+	if !ok {
+		return errorValue
+	}
+	if x.Type == Undefined && b {
+		return undefined
+	}
+	return boolValue(b)
+}
+
+func evalOr(x Attribute, y func() Attribute) Attribute {
+	if x.Type == Error {
+		return x
+	}
+	if x.Type != Undefined {
+		b, ok := truth(x)
+		if !ok {
+			return errorValue
+		}
+		if b {
+			return boolValue(true)
+		}
+	}
+	yv := y()
+	if yv.Type == Error {
+		return yv
+	}
+	if yv.Type == Undefined {
+		return undefined
+	}
+	b, ok := truth(yv)
+	if !ok {
+		return errorValue
+	}
+	if x.Type == Undefined && !b {
+		return undefined
+	}
+	return boolValue(b)
+}
+
+// identical implements the meta-equality operator =?=.
+func identical(x, y Attribute) bool {
+	if x.Type != y.Type {
+		return false
+	}
+	switch x.Type {
+	case Undefined, Error:
+		return true
+	}
+	return x.Value == y.Value
+}
+
+func compare(op string, x, y Attribute) Attribute {
+	var c int
+	xs, xok := stringValue(x)
+	ys, yok := stringValue(y)
+	if xok && yok {
+		c = strings.Compare(strings.ToLower(xs), strings.ToLower(ys))
+	} else {
+		xf, _, xok := number(x)
+		yf, _, yok := number(y)
+		if !xok || !yok {
+			return errorValue
+		}
+		switch {
+		case xf < yf:
+			c = -1
+		case xf > yf:
+			c = 1
+		}
+	}
+	switch op {
+	case "==":
+		return boolValue(c == 0)
+	case "!=":
+		return boolValue(c != 0)
+	case "<":
+		return boolValue(c < 0)
+	case "<=":
+		return boolValue(c <= 0)
+	case ">":
+		return boolValue(c > 0)
+	case ">=":
+		return boolValue(c >= 0)
+	}
+	return errorValue
+}
+
+func arithmetic(op string, x, y Attribute) Attribute {
+	if op == "+" && x.Type == String && y.Type == String {
+		return errorValue
+	}
+	xf, xint, xok := number(x)
+	yf, yint, yok := number(y)
+	if !xok || !yok {
+		return errorValue
+	}
+	if xint && yint {
+		xi, yi := int64(xf), int64(yf)
+		switch op {
+		case "+":
+			return Attribute{Type: Integer, Value: xi + yi}
+		case "-":
+			return Attribute{Type: Integer, Value: xi - yi}
+		case "*":
+			return Attribute{Type: Integer, Value: xi * yi}
+		case "/":
+			if yi == 0 {
+				return errorValue
+			}
+			return Attribute{Type: Integer, Value: xi / yi}
+		case "%":
+			if yi == 0 {
+				return errorValue
+			}
+			return Attribute{Type: Integer, Value: xi % yi}
+		}
+		return errorValue
+	}
+	switch op {
+	case "+":
+		return Attribute{Type: Real, Value: xf + yf}
+	case "-":
+		return Attribute{Type: Real, Value: xf - yf}
+	case "*":
+		return Attribute{Type: Real, Value: xf * yf}
+	case "/":
+		if yf == 0 {
+			return errorValue
+		}
+		return Attribute{Type: Real, Value: xf / yf}
+	case "%":
+		if yf == 0 {
+			return errorValue
+		}
+		return Attribute{Type: Real, Value: math.Mod(xf, yf)}
+	}
+	return errorValue
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(e *env) Attribute {
+	// ifThenElse only evaluates the branch it takes
+	if n.name == "ifthenelse" {
+		if len(n.args) != 3 {
+			return errorValue
+		}
+		return (&condNode{n.args[0], n.args[1], n.args[2]}).eval(e)
+	}
+	args := make([]Attribute, len(n.args))
+	for i, a := range n.args {
+		args[i] = a.eval(e)
+	}
+	f, ok := functions[n.name]
+	if !ok {
+		return errorValue
+	}
+	return f(args)
+}
+
+// functions are the supported built-in ClassAd functions, keyed by lower-case
+// name.
+var functions = map[string]func(args []Attribute) Attribute{
+	"isundefined": typeTest(Undefined),
+	"iserror":     typeTest(Error),
+	"isstring":    typeTest(String),
+	"isinteger":   typeTest(Integer),
+	"isreal":      typeTest(Real),
+	"isboolean":   typeTest(Boolean),
+	"strcat": func(args []Attribute) Attribute {
+		var b strings.Builder
+		for _, a := range args {
+			if a.Type == Undefined || a.Type == Error {
+				return a
+			}
+			b.WriteString(a.String())
+		}
+		return Attribute{Type: String, Value: b.String()}
+	},
+	"size": stringFunc(func(s string) Attribute {
+		return Attribute{Type: Integer, Value: int64(len(s))}
+	}),
+	"tolower": stringFunc(func(s string) Attribute {
+		return Attribute{Type: String, Value: strings.ToLower(s)}
+	}),
+	"toupper": stringFunc(func(s string) Attribute {
+		return Attribute{Type: String, Value: strings.ToUpper(s)}
+	}),
+	"substr": func(args []Attribute) Attribute {
+		if len(args) < 2 || len(args) > 3 {
+			return errorValue
+		}
+		s, ok := stringValue(args[0])
+		i, iok := intValue(args[1])
+		if !ok || !iok {
+			return errorValue
+		}
+		off := int(i)
+		if off < 0 {
+			off += len(s)
+		}
+		if off < 0 {
+			off = 0
+		}
+		if off > len(s) {
+			off = len(s)
+		}
+		end := len(s)
+		if len(args) == 3 {
+			n, ok := intValue(args[2])
+			if !ok {
+				return errorValue
+			}
+			l := int(n)
+			if l < 0 {
+				end += l
+			} else if off+l < end {
+				end = off + l
+			}
+		}
+		if end < off {
+			end = off
+		}
+		return Attribute{Type: String, Value: s[off:end]}
+	},
+	"strcmp":  strcmpFunc(false),
+	"stricmp": strcmpFunc(true),
+	"regexp": func(args []Attribute) Attribute {
+		if len(args) < 2 || len(args) > 3 {
+			return errorValue
+		}
+		strs := make([]string, len(args))
+		for i, a := range args {
+			if a.Type == Undefined {
+				return undefined
+			}
+			s, ok := stringValue(a)
+			if !ok {
+				return errorValue
+			}
+			strs[i] = s
+		}
+		pattern := strs[0]
+		if len(strs) == 3 && strings.Contains(strings.ToLower(strs[2]), "i") {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return errorValue
+		}
+		return boolValue(re.MatchString(strs[1]))
+	},
+	"stringlistmember":  stringListMember(false),
+	"stringlistimember": stringListMember(true),
+	"int": func(args []Attribute) Attribute {
+		if len(args) != 1 {
+			return errorValue
+		}
+		switch a := args[0]; a.Type {
+		case Undefined, Error:
+			return a
+		case String:
+			if s, ok := stringValue(a); ok {
+				if v := ParseAttribute(s); v.Type == Integer || v.Type == Real {
+					f, _, _ := number(v)
+					return Attribute{Type: Integer, Value: int64(f)}
+				}
+			}
+		default:
+			if _, ok := intValue(a); ok {
+				return a
+			}
+			if f, _, ok := number(a); ok {
+				return Attribute{Type: Integer, Value: int64(f)}
+			}
+		}
+		return errorValue
+	},
+	"real": func(args []Attribute) Attribute {
+		if len(args) != 1 {
+			return errorValue
+		}
+		switch a := args[0]; a.Type {
+		case Undefined, Error:
+			return a
+		case String:
+			if s, ok := stringValue(a); ok {
+				if v := ParseAttribute(s); v.Type == Integer || v.Type == Real {
+					f, _, _ := number(v)
+					return Attribute{Type: Real, Value: f}
+				}
+			}
+		default:
+			if f, _, ok := number(a); ok {
+				return Attribute{Type: Real, Value: f}
+			}
+		}
+		return errorValue
+	},
+	"string": func(args []Attribute) Attribute {
+		if len(args) != 1 {
+			return errorValue
+		}
+		if a := args[0]; a.Type == Undefined || a.Type == Error {
+			return a
+		}
+		return Attribute{Type: String, Value: args[0].String()}
+	},
+	"time": func(args []Attribute) Attribute {
+		if len(args) != 0 {
+			return errorValue
+		}
+		return Attribute{Type: Integer, Value: time.Now().Unix()}
+	},
+	"floor":   roundFunc(math.Floor),
+	"ceiling": roundFunc(math.Ceil),
+	"round":   roundFunc(math.Round),
+}
+
+func typeTest(t AttributeType) func(args []Attribute) Attribute {
+	return func(args []Attribute) Attribute {
+		if len(args) != 1 {
+			return errorValue
+		}
+		return boolValue(args[0].Type == t)
+	}
+}
+
+func stringFunc(f func(s string) Attribute) func(args []Attribute) Attribute {
+	return func(args []Attribute) Attribute {
+		if len(args) != 1 {
+			return errorValue
+		}
+		switch args[0].Type {
+		case Undefined, Error:
+			return args[0]
+		}
+		if s, ok := stringValue(args[0]); ok {
+			return f(s)
+		}
+		return errorValue
+	}
+}
+
+func strcmpFunc(fold bool) func(args []Attribute) Attribute {
+	return func(args []Attribute) Attribute {
+		if len(args) != 2 {
+			return errorValue
+		}
+		if v, ok := strictness(args[0], args[1]); ok {
+			return v
+		}
+		x, y := args[0].String(), args[1].String()
+		if fold {
+			x, y = strings.ToLower(x), strings.ToLower(y)
+		}
+		return Attribute{Type: Integer, Value: int64(strings.Compare(x, y))}
+	}
+}
+
+func stringListMember(fold bool) func(args []Attribute) Attribute {
+	return func(args []Attribute) Attribute {
+		if len(args) < 2 || len(args) > 3 {
+			return errorValue
+		}
+		for _, a := range args {
+			if a.Type == Undefined || a.Type == Error {
+				return a
+			}
+		}
+		list, ok := stringValue(args[1])
+		if !ok {
+			return errorValue
+		}
+		delims := ", "
+		if len(args) == 3 {
+			if delims, ok = stringValue(args[2]); !ok {
+				return errorValue
+			}
+		}
+		item := args[0].String()
+		items := strings.FieldsFunc(list, func(r rune) bool {
+			return strings.ContainsRune(delims, r)
+		})
+		for _, i := range items {
+			if i == item || (fold && strings.EqualFold(i, item)) {
+				return boolValue(true)
+			}
+		}
+		return boolValue(false)
+	}
+}
+
+func roundFunc(f func(float64) float64) func(args []Attribute) Attribute {
+	return func(args []Attribute) Attribute {
+		if len(args) != 1 {
+			return errorValue
+		}
+		switch a := args[0]; a.Type {
+		case Undefined, Error:
+			return a
+		}
+		if _, ok := intValue(args[0]); ok {
+			return args[0]
+		}
+		if r, ok := realValue(args[0]); ok {
+			return Attribute{Type: Integer, Value: int64(f(r))}
+		}
+		return errorValue
+	}
+}
@@ -0,0 +1,173 @@
+package classad
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAttribute(t *testing.T) {
+	testCases := []struct {
+		val      string
+		expected Attribute
+	}{
+		{`42`, Attribute{Type: Integer, Value: int64(42)}},
+		{` 4.5 `, Attribute{Type: Real, Value: 4.5}},
+		{`"foo \"bar\""`, Attribute{Type: String, Value: `foo "bar"`}},
+		{`true`, Attribute{Type: Boolean, Value: true}},
+		{`FALSE`, Attribute{Type: Boolean, Value: false}},
+		{`undefined`, Attribute{Type: Undefined}},
+		{`error`, Attribute{Type: Error}},
+		{``, Attribute{Type: Error}},
+		{`Foo`, Attribute{Type: Expression, Value: "Foo"}},
+		{`"a" + "b"`, Attribute{Type: Expression, Value: `"a" + "b"`}},
+	}
+	for _, tc := range testCases {
+		if a := ParseAttribute(tc.val); !reflect.DeepEqual(a, tc.expected) {
+			t.Errorf("ParseAttribute(%q): expected %#v, got %#v", tc.val, tc.expected, a)
+		}
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	ad := ClassAd{
+		"Owner":          ParseAttribute(`"alice"`),
+		"JobStatus":      ParseAttribute(`2`),
+		"RequestMemory":  ParseAttribute(`2048`),
+		"MemoryUsage":    ParseAttribute(`1536.5`),
+		"WantGPU":        ParseAttribute(`false`),
+		"Requirements":   ParseAttribute(`TARGET.Memory >= RequestMemory`),
+		"Memory":         ParseAttribute(`4096`),
+		"AcctGroup":      ParseAttribute(`"group_a.alice"`),
+		"Loop":           ParseAttribute(`Loop + 1`),
+		"LegacyOwner":    AttributeFromString(`"bob"`),
+		"SiteWhitelist":  ParseAttribute(`"FNAL,CERN, T2_US_MIT"`),
+		"CompletionDate": ParseAttribute(`0`),
+	}
+	testCases := []struct {
+		expr     string
+		expected Attribute
+	}{
+		{`Owner == "ALICE"`, boolValue(true)},
+		{`Owner =?= "ALICE"`, boolValue(false)},
+		{`JobStatus == 2 && RequestMemory > 1024`, boolValue(true)},
+		{`JobStatus == 1 || MY.WantGPU`, boolValue(false)},
+		{`NoSuchAttr == 1`, undefined},
+		{`NoSuchAttr =?= undefined`, boolValue(true)},
+		{`NoSuchAttr is undefined`, boolValue(true)},
+		{`NoSuchAttr == 1 && false`, boolValue(false)},
+		{`NoSuchAttr == 1 || true`, boolValue(true)},
+		{`!WantGPU`, boolValue(true)},
+		{`Requirements`, boolValue(true)},
+		{`RequestMemory / 1024 + 1`, Attribute{Type: Integer, Value: int64(3)}},
+		{`MemoryUsage * 2`, Attribute{Type: Real, Value: 3073.0}},
+		{`-JobStatus % 3`, Attribute{Type: Integer, Value: int64(-2)}},
+		{`1 / 0`, errorValue},
+		{`Owner + 1`, errorValue},
+		{`JobStatus == 2 ? "running" : "other"`, Attribute{Type: String, Value: "running"}},
+		{`ifThenElse(WantGPU, 1, 2)`, Attribute{Type: Integer, Value: int64(2)}},
+		{`regexp("^group_a\\.", AcctGroup)`, boolValue(true)},
+		{`regexp("ALICE", Owner, "i")`, boolValue(true)},
+		{`stringListMember("T2_US_MIT", SiteWhitelist)`, boolValue(true)},
+		{`stringListIMember("cern", SiteWhitelist)`, boolValue(true)},
+		{`strcat(Owner, "@", "fnal")`, Attribute{Type: String, Value: "alice@fnal"}},
+		{`toUpper(substr(Owner, 1, 3))`, Attribute{Type: String, Value: "LIC"}},
+		{`size(Owner)`, Attribute{Type: Integer, Value: int64(5)}},
+		{`isUndefined(NoSuchAttr) && isString(Owner)`, boolValue(true)},
+		{`int(MemoryUsage) == 1536`, boolValue(true)},
+		{`floor(2.7) + ceiling(2.1) + round(2.5)`, Attribute{Type: Integer, Value: int64(8)}},
+		{`Loop`, errorValue},
+		{`LegacyOwner == "bob"`, boolValue(true)},
+		{`CompletionDate > 0 || JobStatus != 4`, boolValue(true)},
+		{`1.5e3 == 1500`, boolValue(true)},
+	}
+	for _, tc := range testCases {
+		x, err := ParseExpr(tc.expr)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %s", tc.expr, err)
+			continue
+		}
+		if a := x.Eval(ad); !reflect.DeepEqual(a, tc.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tc.expr, tc.expected, a)
+		}
+	}
+}
+
+func TestExprMatches(t *testing.T) {
+	ads := []ClassAd{
+		{"Owner": ParseAttribute(`"alice"`), "JobStatus": ParseAttribute(`2`)},
+		{"Owner": ParseAttribute(`"bob"`), "JobStatus": ParseAttribute(`5`)},
+		{"Owner": ParseAttribute(`"carol"`)},
+	}
+	x, err := ParseExpr(`JobStatus != 5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, ad := range ads {
+		if x.Matches(ad) {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected one match, got %d", n)
+	}
+}
+
+func TestExprEval_handBuilt(t *testing.T) {
+	// attributes built without ParseAttribute, including zero values and
+	// values of the wrong type, evaluate to Error rather than panicking
+	ad := ClassAd{
+		"ZeroBool":   {Type: Boolean},
+		"ZeroInt":    {Type: Integer},
+		"ZeroReal":   {Type: Real},
+		"ZeroString": {Type: String},
+		"WrongInt":   {Type: Integer, Value: 5},
+		"WrongStr":   {Type: String, Value: []string{"a"}},
+		"Good":       {Type: Integer, Value: int64(5)},
+		"Zero":       {},
+	}
+	for _, src := range []string{
+		`ZeroBool`, `!ZeroBool`, `ZeroBool ? 1 : 2`, `ZeroInt + 1`, `-ZeroInt`, `-ZeroReal`,
+		`ZeroString == "x"`, `WrongInt > 1`, `WrongStr == "a"`,
+		`size(ZeroString)`, `substr(ZeroString, 1)`, `substr("abc", WrongInt)`,
+		`regexp("a", ZeroString)`, `int(ZeroReal)`, `int(WrongInt)`, `real(ZeroString)`,
+		`stringListMember("a", WrongStr)`, `floor(ZeroReal)`, `round(WrongInt)`,
+	} {
+		x, err := ParseExpr(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := x.Eval(ad); v.Type != Error {
+			t.Errorf("%s: expected error, got %v", src, v)
+		}
+		if x.Matches(ad) {
+			t.Errorf("%s: expected no match", src)
+		}
+	}
+	// uncomparable values aren't compared
+	x, err := ParseExpr(`WrongStr =?= WrongStr && isError(WrongStr)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !x.Matches(ad) {
+		t.Error("expected mistyped attribute to be identical to itself as an error")
+	}
+	x, err = ParseExpr(`Good + 1 == 6 && isUndefined(Missing)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !x.Matches(ad) {
+		t.Error("expected well-typed hand-built attributes to match")
+	}
+	if v := ad.EvalAttribute("Zero"); v.Type != Error {
+		t.Errorf("expected error for zero attribute, got %v", v)
+	}
+}
+
+func TestParseExpr_bad(t *testing.T) {
+	for _, s := range []string{`Owner ==`, `(1 + 2`, `"unterminated`, `1 2`, `foo(1,`, `MY.`, `a # b`} {
+		if _, err := ParseExpr(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
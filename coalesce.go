@@ -0,0 +1,38 @@
+package htcondor
+
+import "context"
+
+// inflight holds the uncached commands currently running with coalescing.
+var inflight loadGroup
+
+// WithCoalescing coalesces identical uncached commands, i.e. with the same
+// command and arguments, that run at the same time, so that only one
+// subprocess is run and its output is shared by every caller. Commands with
+// a cache are coalesced by the cache, if it supports it, as the caches in
+// this package do.
+//
+// The command runs with the executor, retry policy and circuit breaker of
+// the first caller, so commands that are coalesced should use the same
+// options. It runs independently of any one caller's context: a caller that
+// is cancelled returns its context's error while the others keep waiting,
+// and the command is only cancelled once every caller has gone. Stream reads
+// the entire output before sending the ClassAds, as it does with a cache.
+func (c *Command) WithCoalescing() *Command {
+	c.opts.coalesce = true
+	return c
+}
+
+// getDirect runs the command directly, without a cache. If the command is
+// coalescing and an identical command is already running, it waits for and
+// returns that command's output instead.
+func (c *Command) getDirect(ctx context.Context, key string) ([]byte, error) {
+	if !c.opts.coalesce {
+		return c.load(ctx)
+	}
+	// the caller may return and reuse the command while it runs
+	out, shared, err := inflight.do(ctx, key, c.Copy().load)
+	if shared {
+		CommandsCoalesced.WithLabelValues(c.Command).Inc()
+	}
+	return out, err
+}
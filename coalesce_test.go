@@ -0,0 +1,165 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestCoalescing(t *testing.T) {
+	ctx := context.Background()
+	blocking := newBlockingExecutor()
+	blocking.stdout = []byte("Name = \"slot1@host\"\n\nName = \"slot2@host\"\n")
+	cmd := NewCommand("condor_status").WithPool("coalesce.example.com").WithExecutor(blocking).WithCoalescing()
+
+	var wg sync.WaitGroup
+	counts := make(chan int, 6)
+	for i := 0; i < 3; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ads, err := cmd.Copy().RunWithContext(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			counts <- len(ads)
+		}()
+		go func() {
+			defer wg.Done()
+			ch := make(chan classad.ClassAd)
+			errs := make(chan error)
+			go cmd.Copy().StreamWithContext(ctx, ch, errs)
+			n := 0
+			for ch != nil || errs != nil {
+				select {
+				case _, ok := <-ch:
+					if !ok {
+						ch = nil
+						continue
+					}
+					n++
+				case err, ok := <-errs:
+					if !ok {
+						errs = nil
+						continue
+					}
+					t.Error(err)
+				}
+			}
+			counts <- n
+		}()
+	}
+	<-blocking.started
+	waitFor(t, "coalesced callers", func() bool { return coalescedCallers(cmd.encodeKey()) == 6 })
+	close(blocking.unblock)
+	wg.Wait()
+	close(counts)
+	for n := range counts {
+		if n != 2 {
+			t.Errorf("expected 2 ads, got %d", n)
+		}
+	}
+	if blocking.calls != 1 {
+		t.Errorf("expected 1 command run, got %d", blocking.calls)
+	}
+
+	// commands with different arguments are not coalesced
+	if _, err := cmd.Copy().WithConstraint("Cpus > 1").RunWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if blocking.calls != 2 {
+		t.Errorf("expected 2 commands run, got %d", blocking.calls)
+	}
+}
+
+func TestCoalescingCancel(t *testing.T) {
+	blocking := newBlockingExecutor()
+	blocking.stdout = []byte("Name = \"slot1@host\"\n")
+	cmd := NewCommand("condor_status").WithPool("coalesce-cancel.example.com").WithExecutor(blocking).WithCoalescing()
+	key := cmd.encodeKey()
+
+	// the first caller gives up, but the command keeps running for the other
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := cmd.Copy().RunWithContext(ctx)
+		leader <- err
+	}()
+	<-blocking.started
+	follower := make(chan error)
+	go func() {
+		ads, err := cmd.Copy().RunWithContext(context.Background())
+		if err == nil && len(ads) != 1 {
+			err = fmt.Errorf("expected 1 ad, got %d", len(ads))
+		}
+		follower <- err
+	}()
+	waitFor(t, "coalesced callers", func() bool { return coalescedCallers(key) == 2 })
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	close(blocking.unblock)
+	if err := <-follower; err != nil {
+		t.Error(err)
+	}
+	if blocking.calls != 1 {
+		t.Errorf("expected 1 command run, got %d", blocking.calls)
+	}
+
+	// the command is cancelled once every caller has gone
+	blocking = newBlockingExecutor()
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := cmd.Copy().WithExecutor(blocking).RunWithContext(ctx)
+		leader <- err
+	}()
+	<-blocking.started
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	waitFor(t, "cancelled command", func() bool {
+		blocking.mu.Lock()
+		defer blocking.mu.Unlock()
+		return blocking.total == 0
+	})
+
+	// the first caller can reuse its command once it has given up, and the
+	// error the others get is still from the command it started
+	blocking = newBlockingExecutor()
+	blocking.exit = 1
+	first := cmd.Copy().WithExecutor(blocking).WithRetry(RetryPolicy{MaxAttempts: 1})
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := first.RunWithContext(ctx)
+		leader <- err
+	}()
+	<-blocking.started
+	go func() {
+		_, err := cmd.Copy().RunWithContext(context.Background())
+		follower <- err
+	}()
+	waitFor(t, "coalesced callers", func() bool { return coalescedCallers(key) == 2 })
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	first.Command = "condor_q"
+	first.WithPool("other.example.com").WithConstraint("true").WithExecutor(&fakeExecutor{}).WithRetry(RetryPolicy{})
+	close(blocking.unblock)
+	var exitErr *ExitError
+	if err := <-follower; !errors.As(err, &exitErr) || exitErr.Command != "condor_status" {
+		t.Errorf("expected condor_status to fail, got %v", err)
+	}
+}
+
+// coalescedCallers returns the number of callers waiting for the coalesced
+// command with key.
+func coalescedCallers(key string) int {
+	return inflight.waiting(key)
+}
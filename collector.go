@@ -0,0 +1,321 @@
+package htcondor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// AdType is a type of ad held by the collector, as selected by condor_status.
+type AdType string
+
+// Ad types that can be queried with condor_status.
+const (
+	StartdAdType     AdType = "-startd"
+	ScheddAdType     AdType = "-schedd"
+	NegotiatorAdType AdType = "-negotiator"
+	MasterAdType     AdType = "-master"
+	SubmitterAdType  AdType = "-submitters"
+	AccountingAdType AdType = "-accounting"
+	GridAdType       AdType = "-grid"
+	GenericAdType    AdType = "-generic"
+	AnyAdType        AdType = "-any"
+)
+
+// Collector is a client for a pool's collector, for querying the ads of the
+// daemons and users in the pool with condor_status.
+type Collector struct {
+	// cmd holds the -pool argument shared by all commands.
+	cmd *Command
+}
+
+// NewCollector creates a client for the collector of pool, which may be empty
+// to use the local pool.
+func NewCollector(pool string) *Collector {
+	return &Collector{
+		cmd: NewCommand("").WithPool(pool),
+	}
+}
+
+// Command returns a new Command for the collector, e.g. to run condor_status
+// with options not covered by the query methods.
+func (c *Collector) Command(command string) *Command {
+	cc := c.cmd.Copy()
+	cc.Command = command
+	return cc
+}
+
+// WithExecutor sets the executor used to run the collector's commands, e.g. a
+// fake for testing. It is also used for the schedd queries of QueryJobs.
+func (c *Collector) WithExecutor(e Executor) *Collector {
+	c.cmd.WithExecutor(e)
+	return c
+}
+
+// QueryCommand returns the condor_status Command that queries ads of the
+// given type. The constraint may be empty to return every ad.
+func (c *Collector) QueryCommand(adType AdType, constraint string, attributes ...string) *Command {
+	cmd := c.Command("condor_status").WithArg(string(adType)).WithConstraint(constraint)
+	for _, a := range attributes {
+		cmd.WithAttribute(a)
+	}
+	return cmd
+}
+
+// Query returns the ads of the given type matching the constraint, which may
+// be empty to return every ad. If attributes are given, only those attributes
+// are returned.
+func (c *Collector) Query(ctx context.Context, adType AdType, constraint string, attributes ...string) ([]classad.ClassAd, error) {
+	return c.QueryCommand(adType, constraint, attributes...).RunWithContext(ctx)
+}
+
+// Startds returns the ads of the startds (execute slots) in the pool.
+func (c *Collector) Startds(ctx context.Context, constraint string, attributes ...string) ([]StartdAd, error) {
+	return queryAds[StartdAd](ctx, c.QueryCommand(StartdAdType, constraint, attributes...))
+}
+
+// Schedds returns the ads of the schedds in the pool.
+func (c *Collector) Schedds(ctx context.Context, constraint string, attributes ...string) ([]ScheddAd, error) {
+	return queryAds[ScheddAd](ctx, c.QueryCommand(ScheddAdType, constraint, attributes...))
+}
+
+// Negotiators returns the ads of the negotiators in the pool.
+func (c *Collector) Negotiators(ctx context.Context, constraint string, attributes ...string) ([]DaemonAd, error) {
+	return queryAds[DaemonAd](ctx, c.QueryCommand(NegotiatorAdType, constraint, attributes...))
+}
+
+// Masters returns the ads of the condor_master daemons in the pool.
+func (c *Collector) Masters(ctx context.Context, constraint string, attributes ...string) ([]DaemonAd, error) {
+	return queryAds[DaemonAd](ctx, c.QueryCommand(MasterAdType, constraint, attributes...))
+}
+
+// Submitters returns the submitter ads, which summarize each user's jobs on
+// each schedd.
+func (c *Collector) Submitters(ctx context.Context, constraint string, attributes ...string) ([]SubmitterAd, error) {
+	return queryAds[SubmitterAd](ctx, c.QueryCommand(SubmitterAdType, constraint, attributes...))
+}
+
+// Accounting returns the accounting ads published by the negotiator, with the
+// priority and usage of each user and accounting group.
+func (c *Collector) Accounting(ctx context.Context, constraint string, attributes ...string) ([]AccountingAd, error) {
+	return queryAds[AccountingAd](ctx, c.QueryCommand(AccountingAdType, constraint, attributes...))
+}
+
+// Grid returns the ads of the grid resources in the pool.
+func (c *Collector) Grid(ctx context.Context, constraint string, attributes ...string) ([]DaemonAd, error) {
+	return queryAds[DaemonAd](ctx, c.QueryCommand(GridAdType, constraint, attributes...))
+}
+
+// Any returns ads of every type.
+func (c *Collector) Any(ctx context.Context, constraint string, attributes ...string) ([]DaemonAd, error) {
+	return queryAds[DaemonAd](ctx, c.QueryCommand(AnyAdType, constraint, attributes...))
+}
+
+// Generic returns ads with the given MyType, e.g. ads published with
+// condor_advertise. If myType is empty, every generic ad is returned.
+func (c *Collector) Generic(ctx context.Context, myType string, constraint string, attributes ...string) ([]DaemonAd, error) {
+	cmd := c.QueryCommand(GenericAdType, constraint, attributes...)
+	if myType != "" {
+		cmd.Args = []string{"-subsystem", myType}
+	}
+	return queryAds[DaemonAd](ctx, cmd)
+}
+
+// queryAds runs a query and converts the results to a typed ad.
+func queryAds[T ~map[string]classad.Attribute](ctx context.Context, cmd *Command) ([]T, error) {
+	ads, err := cmd.RunWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	typed := make([]T, len(ads))
+	for i, ad := range ads {
+		typed[i] = T(ad)
+	}
+	return typed, nil
+}
+
+// adString returns the value of a string attribute, or the empty string if it
+// is missing or not a string.
+func adString(ad classad.ClassAd, name string) string {
+	a := ad.EvalAttribute(name)
+	if a.Type != classad.String {
+		return ""
+	}
+	return fmt.Sprintf("%s", a.Value)
+}
+
+// adInt returns the value of a numeric attribute.
+func adInt(ad classad.ClassAd, name string) (int64, error) {
+	v, err := ad.EvalAttribute(name).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+// adFloat returns the value of a numeric attribute as a float.
+func adFloat(ad classad.ClassAd, name string) (float64, error) {
+	a := ad.EvalAttribute(name)
+	switch a.Type {
+	case classad.Real:
+		return a.Value.(float64), nil
+	case classad.Integer:
+		return float64(a.Value.(int64)), nil
+	}
+	return 0, fmt.Errorf("%s: attribute is not numeric: %s", name, a)
+}
+
+// DaemonAd is the ClassAd of a daemon or other collector ad.
+type DaemonAd classad.ClassAd
+
+// Name returns the name of the daemon.
+func (d DaemonAd) Name() string {
+	return adString(classad.ClassAd(d), "Name")
+}
+
+// MyType returns the type of the ad, e.g. "Negotiator".
+func (d DaemonAd) MyType() string {
+	return adString(classad.ClassAd(d), "MyType")
+}
+
+// Machine returns the host the daemon is running on.
+func (d DaemonAd) Machine() string {
+	return adString(classad.ClassAd(d), "Machine")
+}
+
+// MyAddress returns the daemon's address ("sinful string").
+func (d DaemonAd) MyAddress() string {
+	return adString(classad.ClassAd(d), "MyAddress")
+}
+
+// StartdAd is the ClassAd of a startd slot.
+type StartdAd classad.ClassAd
+
+// Name returns the name of the slot, e.g. "slot1@host".
+func (s StartdAd) Name() string {
+	return adString(classad.ClassAd(s), "Name")
+}
+
+// Machine returns the host of the slot.
+func (s StartdAd) Machine() string {
+	return adString(classad.ClassAd(s), "Machine")
+}
+
+// State returns the state of the slot, e.g. "Unclaimed" or "Claimed".
+func (s StartdAd) State() string {
+	return adString(classad.ClassAd(s), "State")
+}
+
+// Activity returns the activity of the slot, e.g. "Idle" or "Busy".
+func (s StartdAd) Activity() string {
+	return adString(classad.ClassAd(s), "Activity")
+}
+
+// Cpus returns the number of CPUs in the slot.
+func (s StartdAd) Cpus() (int64, error) {
+	return adInt(classad.ClassAd(s), "Cpus")
+}
+
+// Memory returns the memory of the slot, in MiB.
+func (s StartdAd) Memory() (int64, error) {
+	return adInt(classad.ClassAd(s), "Memory")
+}
+
+// IsPartitionable returns true if the slot is a partitionable slot.
+func (s StartdAd) IsPartitionable() bool {
+	b, _ := classad.ClassAd(s).EvalAttribute("PartitionableSlot").Bool()
+	return b
+}
+
+// ScheddAd is the ClassAd of a schedd.
+type ScheddAd classad.ClassAd
+
+// Name returns the name of the schedd.
+func (s ScheddAd) Name() string {
+	return adString(classad.ClassAd(s), "Name")
+}
+
+// Machine returns the host of the schedd.
+func (s ScheddAd) Machine() string {
+	return adString(classad.ClassAd(s), "Machine")
+}
+
+// TotalRunningJobs returns the number of running jobs in the schedd.
+func (s ScheddAd) TotalRunningJobs() (int64, error) {
+	return adInt(classad.ClassAd(s), "TotalRunningJobs")
+}
+
+// TotalIdleJobs returns the number of idle jobs in the schedd.
+func (s ScheddAd) TotalIdleJobs() (int64, error) {
+	return adInt(classad.ClassAd(s), "TotalIdleJobs")
+}
+
+// TotalHeldJobs returns the number of held jobs in the schedd.
+func (s ScheddAd) TotalHeldJobs() (int64, error) {
+	return adInt(classad.ClassAd(s), "TotalHeldJobs")
+}
+
+// Schedd returns a client for the schedd, in the given pool.
+func (s ScheddAd) Schedd(pool string) *Schedd {
+	return NewSchedd(pool, s.Name())
+}
+
+// SubmitterAd is the ClassAd summarizing a user's jobs on a schedd.
+type SubmitterAd classad.ClassAd
+
+// Name returns the submitter name, e.g. "alice@example.com".
+func (s SubmitterAd) Name() string {
+	return adString(classad.ClassAd(s), "Name")
+}
+
+// ScheddName returns the name of the schedd the jobs are on.
+func (s SubmitterAd) ScheddName() string {
+	return adString(classad.ClassAd(s), "ScheddName")
+}
+
+// RunningJobs returns the number of the user's running jobs.
+func (s SubmitterAd) RunningJobs() (int64, error) {
+	return adInt(classad.ClassAd(s), "RunningJobs")
+}
+
+// IdleJobs returns the number of the user's idle jobs.
+func (s SubmitterAd) IdleJobs() (int64, error) {
+	return adInt(classad.ClassAd(s), "IdleJobs")
+}
+
+// HeldJobs returns the number of the user's held jobs.
+func (s SubmitterAd) HeldJobs() (int64, error) {
+	return adInt(classad.ClassAd(s), "HeldJobs")
+}
+
+// AccountingAd is the ClassAd of a user or accounting group, as published by
+// the negotiator.
+type AccountingAd classad.ClassAd
+
+// Name returns the user or group name.
+func (a AccountingAd) Name() string {
+	return adString(classad.ClassAd(a), "Name")
+}
+
+// IsAccountingGroup returns true if the ad is for an accounting group rather
+// than a user.
+func (a AccountingAd) IsAccountingGroup() bool {
+	b, _ := classad.ClassAd(a).EvalAttribute("IsAccountingGroup").Bool()
+	return b
+}
+
+// Priority returns the effective priority.
+func (a AccountingAd) Priority() (float64, error) {
+	return adFloat(classad.ClassAd(a), "Priority")
+}
+
+// PriorityFactor returns the priority factor.
+func (a AccountingAd) PriorityFactor() (float64, error) {
+	return adFloat(classad.ClassAd(a), "PriorityFactor")
+}
+
+// ResourcesUsed returns the number of resources currently in use.
+func (a AccountingAd) ResourcesUsed() (int64, error) {
+	return adInt(classad.ClassAd(a), "ResourcesUsed")
+}
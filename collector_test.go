@@ -0,0 +1,85 @@
+package htcondor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestCollectorQueryCommand(t *testing.T) {
+	c := NewCollector("mypool:9618")
+	cmd := c.QueryCommand(ScheddAdType, `Name == "foo"`, "Name", "TotalRunningJobs")
+	if cmd.Command != "condor_status" {
+		t.Errorf("expected condor_status, got %s", cmd.Command)
+	}
+	expected := []string{"-pool", "mypool:9618", "-constraint", `Name == "foo"`, "-schedd", "-af:lrng", "Name", "TotalRunningJobs"}
+	if args := cmd.MakeArgs(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+	expected = []string{"-accounting", "-long"}
+	if args := NewCollector("").QueryCommand(AccountingAdType, "").MakeArgs(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestCollectorAds(t *testing.T) {
+	startd := StartdAd{
+		"Name":              {Type: classad.String, Value: "slot1@host"},
+		"State":             {Type: classad.String, Value: "Unclaimed"},
+		"Cpus":              {Type: classad.Integer, Value: int64(8)},
+		"TotalSlotMemory":   {Type: classad.Integer, Value: int64(16384)},
+		"Memory":            {Type: classad.Expression, Value: "TotalSlotMemory / 2"},
+		"PartitionableSlot": {Type: classad.Boolean, Value: true},
+	}
+	if startd.Name() != "slot1@host" || startd.State() != "Unclaimed" || startd.Activity() != "" {
+		t.Errorf("unexpected startd strings %q %q %q", startd.Name(), startd.State(), startd.Activity())
+	}
+	if cpus, err := startd.Cpus(); err != nil || cpus != 8 {
+		t.Errorf("expected 8 cpus, got %d (%v)", cpus, err)
+	}
+	if mem, err := startd.Memory(); err != nil || mem != 8192 {
+		t.Errorf("expected 8192 memory, got %d (%v)", mem, err)
+	}
+	if !startd.IsPartitionable() {
+		t.Error("expected partitionable slot")
+	}
+
+	acct := AccountingAd{
+		"Name":              {Type: classad.String, Value: "group_a.alice@example.com"},
+		"Priority":          {Type: classad.Real, Value: 500.5},
+		"PriorityFactor":    {Type: classad.Integer, Value: int64(1000)},
+		"IsAccountingGroup": {Type: classad.Boolean, Value: false},
+	}
+	if p, err := acct.Priority(); err != nil || p != 500.5 {
+		t.Errorf("expected priority 500.5, got %f (%v)", p, err)
+	}
+	if f, err := acct.PriorityFactor(); err != nil || f != 1000 {
+		t.Errorf("expected priority factor 1000, got %f (%v)", f, err)
+	}
+	if _, err := acct.ResourcesUsed(); err == nil {
+		t.Error("expected error for missing ResourcesUsed")
+	}
+	if acct.IsAccountingGroup() {
+		t.Error("expected user, not group")
+	}
+
+	schedd := ScheddAd{"Name": {Type: classad.String, Value: "schedd@host"}}
+	if s := schedd.Schedd("mypool"); s.cmd.Pool != "mypool" || s.cmd.Name != "schedd@host" {
+		t.Errorf("unexpected schedd client %+v", s.cmd)
+	}
+}
+
+func TestCondorCollectorSchedds(t *testing.T) {
+	schedds, err := NewCollector("").Schedds(context.Background(), "", "Name", "TotalRunningJobs")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(schedds) != 1 {
+		t.Errorf("expected one schedd, got %d", len(schedds))
+	}
+	for _, s := range schedds {
+		t.Log(s.Name())
+	}
+}
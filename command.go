@@ -1,6 +1,7 @@
 package htcondor
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/golang/groupcache"
@@ -33,6 +34,57 @@ var (
 		},
 		[]string{"command"},
 	)
+	// CommandRetries is a prometheus counter of the number of times each
+	// command has been retried. Like CommandDuration, it is up to the client
+	// to register it.
+	CommandRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "htcondor_client_command_retries_total",
+			Help: "Number of times commands have been retried.",
+		},
+		[]string{"command"},
+	)
+	// CircuitBreakerState is a prometheus gauge of the state of each pool's
+	// and schedd's circuit breaker: 0 (closed), 1 (open) or 2 (half-open).
+	// The schedd label is empty for pools. Like CommandDuration, it is up to
+	// the client to register it.
+	CircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "htcondor_client_circuit_breaker_state",
+			Help: "State of the circuit breaker for each pool and schedd: 0 closed, 1 open, 2 half-open.",
+		},
+		[]string{"pool", "schedd"},
+	)
+	// CommandQueueWait is a prometheus histogram of the time each command
+	// waited to run under the concurrency limits. Like CommandDuration, it is
+	// up to the client to register it.
+	CommandQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "htcondor_client_command_queue_wait_seconds",
+			Help: "Histogram of time commands waited to run.",
+		},
+		[]string{"command"},
+	)
+	// CommandQueueDepth is a prometheus gauge of the number of commands
+	// waiting to run under the concurrency limits. Like CommandDuration, it
+	// is up to the client to register it.
+	CommandQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "htcondor_client_command_queue_depth",
+			Help: "Number of commands waiting to run.",
+		},
+	)
+	// CommandsCoalesced is a prometheus counter of the number of calls that
+	// shared the output of an identical command already running, rather than
+	// running their own. Like CommandDuration, it is up to the client to
+	// register it.
+	CommandsCoalesced = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "htcondor_client_commands_coalesced_total",
+			Help: "Number of calls that shared the output of an identical running command.",
+		},
+		[]string{"command"},
+	)
 	tracer = otel.Tracer("htcondor")
 )
 
@@ -71,11 +123,29 @@ type Command struct {
 	Attributes []string
 	// Args is a list of any extra arguments to pass.
 	Args []string
-	// cache is an optional groupcache pool to cache
-	// queries. Inititalize with WithCache().
-	cache         *groupcache.HTTPPool
-	cacheGroup    string
+	// cache is an optional cache for queries. Inititalize with WithCache()
+	// or WithCacheBackend().
+	cache         Cache
 	cacheLifetime time.Duration
+	// opts are the options for how the command is run, e.g. the executor.
+	opts runOptions
+}
+
+// runOptions are the options for how a command is run, which are shared by
+// copies of the command and used for cache misses.
+type runOptions struct {
+	// runner is the executor used to run the command. If nil, DefaultExecutor
+	// is used. Initialize with WithExecutor().
+	runner Executor
+	// retry is the policy for retrying failed commands. If nil, commands are
+	// not retried. Initialize with WithRetry().
+	retry *RetryPolicy
+	// breaker is the policy for the pool's circuit breaker. If nil, no
+	// breaker is used. Initialize with WithCircuitBreaker().
+	breaker *CircuitBreakerPolicy
+	// coalesce shares the output of identical uncached commands running at
+	// the same time. Initialize with WithCoalescing().
+	coalesce bool
 }
 
 // NewCommand creates a new HTCondor command.
@@ -86,7 +156,8 @@ func NewCommand(command string) *Command {
 }
 
 // Copy returns a new copy of the command, useful for adding further arguments
-// without changing the base command. The commands share the cache.
+// without changing the base command. The commands share the cache and
+// executor.
 func (c *Command) Copy() *Command {
 	cc := Command{
 		Command:       c.Command,
@@ -97,8 +168,8 @@ func (c *Command) Copy() *Command {
 		Attributes:    make([]string, len(c.Attributes)),
 		Args:          make([]string, len(c.Args)),
 		cache:         c.cache,
-		cacheGroup:    c.cacheGroup,
 		cacheLifetime: c.cacheLifetime,
+		opts:          c.opts,
 	}
 	if len(c.Attributes) > 0 {
 		copy(cc.Attributes, c.Attributes)
@@ -109,15 +180,22 @@ func (c *Command) Copy() *Command {
 	return &cc
 }
 
-// WithCache initializes a groupcache group for the client. Set cacheLifetime to
-// 0 to *never* expire cached queries (unless they are LRU evicted).
+// WithCache initializes a groupcache group for the client, shared with the
+// peers in pool. Set cacheLifetime to 0 to *never* expire cached queries
+// (unless they are LRU evicted). Use WithCacheBackend for other caches.
+//
+// Cache misses requested by peers are run with the command's executor, retry
+// policy and circuit breaker at the time the group is first created, so call
+// WithExecutor, WithRetry and WithCircuitBreaker first to use them for cached
+// queries.
 func (c *Command) WithCache(pool *groupcache.HTTPPool, group string, cacheBytes int64, cacheLifetime time.Duration) *Command {
-	c.cache = pool
-	c.cacheGroup = group
-	c.cacheLifetime = cacheLifetime
-	if groupcache.GetGroup(group) == nil {
-		groupcache.NewGroup(c.cacheGroup, cacheBytes, commandGetter())
-	}
+	return c.WithCacheBackend(newGroupCache(group, cacheBytes, c.opts), cacheLifetime)
+}
+
+// WithExecutor sets the executor used to run the command, e.g. a fake for
+// testing. By default commands are run with DefaultExecutor.
+func (c *Command) WithExecutor(e Executor) *Command {
+	c.opts.runner = e
 	return c
 }
 
@@ -166,8 +244,9 @@ func (c *Command) WithArg(arg string) *Command {
 	return c
 }
 
-// MakeArgs builds the complete argument list to be passed to the command.
-func (c *Command) MakeArgs() []string {
+// targetArgs returns the -pool and -name arguments, which select the daemon
+// that most HTCondor tools talk to.
+func (c *Command) targetArgs() []string {
 	args := make([]string, 0)
 	if c.Pool != "" {
 		args = append(args, "-pool", c.Pool)
@@ -175,6 +254,12 @@ func (c *Command) MakeArgs() []string {
 	if c.Name != "" {
 		args = append(args, "-name", c.Name)
 	}
+	return args
+}
+
+// MakeArgs builds the complete argument list to be passed to the command.
+func (c *Command) MakeArgs() []string {
+	args := c.targetArgs()
 	if c.Limit > 0 {
 		args = append(args, "-limit", fmt.Sprintf("%d", c.Limit))
 	}
@@ -194,25 +279,22 @@ func (c *Command) MakeArgs() []string {
 }
 
 // Cmd generates an exec.Cmd you can use to run the command manually.
-// Use Run() to run the command and get back ClassAds.
+// Use Run() to run the command and get back ClassAds. The command's executor
+// is not used.
 func (c *Command) Cmd() *exec.Cmd {
 	return exec.Command(c.Command, c.MakeArgs()...)
 }
 
 // CmdContext generates an exec.Cmd with context you can use to run the command
-// manually. Use Run() to run the command and get back ClassAds.
+// manually. Use Run() to run the command and get back ClassAds. The command's
+// executor is not used.
 func (c *Command) CmdContext(ctx context.Context) *exec.Cmd {
 	return exec.CommandContext(ctx, c.Command, c.MakeArgs()...)
 }
 
 // encodeKey encodes the command into a string, to be used as a cache key.
 func (c *Command) encodeKey() string {
-	timeKey := "0"
-	if c.cacheLifetime > 0 {
-		timeKey = time.Now().Truncate(c.cacheLifetime).Format(time.RFC3339)
-	}
-	return timeKey + keySeparator +
-		c.Command + keySeparator +
+	return c.Command + keySeparator +
 		strings.Join(c.MakeArgs(), keySeparator)
 }
 
@@ -223,11 +305,10 @@ func decodeKey(key string) (*Command, error) {
 	if len(parts) < 2 {
 		return nil, fmt.Errorf("unable to decode cache key: %s", key)
 	}
-	// first field is time key, we don't need it
 	c := Command{
-		Command: parts[1],
+		Command: parts[0],
 	}
-	if len(parts) > 2 {
+	if len(parts) > 1 {
 		endArgs := len(parts) - 1
 		for i, arg := range parts {
 			if arg == attributeFormat {
@@ -235,7 +316,7 @@ func decodeKey(key string) (*Command, error) {
 				break
 			}
 		}
-		c.Args = parts[2:endArgs]
+		c.Args = parts[1:endArgs]
 		if endArgs < len(parts)-1 {
 			c.Attributes = parts[endArgs+1:]
 		}
@@ -244,8 +325,9 @@ func decodeKey(key string) (*Command, error) {
 }
 
 // commandGetter returns a groupCache.GetterFunc that queries HTCondor with the
-// configured command, and stores the raw response in dest.
-func commandGetter() groupcache.GetterFunc {
+// configured command, and stores the raw response in dest. The command is run
+// with the given options.
+func commandGetter(opts runOptions) groupcache.GetterFunc {
 	return func(ctx context.Context, key string, dest groupcache.Sink) error {
 		ctx, span := tracer.Start(ctx, "Getter")
 		defer span.End()
@@ -257,50 +339,60 @@ func commandGetter() groupcache.GetterFunc {
 			span.SetStatus(codes.Error, err.Error())
 			return err
 		}
-		c.addTracingTags(span)
-		timer := prometheus.NewTimer(CommandDuration.WithLabelValues(c.Command))
-		defer timer.ObserveDuration()
-
-		cmd := c.CmdContext(ctx)
-		out, err := cmd.StdoutPipe()
-		if err != nil {
-			err := fmt.Errorf("error creating stdout pipe: %w", err)
-			span.SetStatus(codes.Error, err.Error())
-			return err
-		}
-		stderr, err := cmd.StderrPipe()
+		c.opts = opts
+		out, err := c.load(ctx)
 		if err != nil {
-			err := fmt.Errorf("error creating stderr pipe: %w", err)
-			span.SetStatus(codes.Error, err.Error())
-			return err
-		}
-		if err := cmd.Start(); err != nil {
-			err := fmt.Errorf("error creating command: %w", err)
-			span.SetStatus(codes.Error, err.Error())
-			return err
-		}
-		resp, err := io.ReadAll(out)
-		if err != nil {
-			err := fmt.Errorf("error reading stdout: %w", err)
-			span.SetStatus(codes.Error, err.Error())
-			return err
-		}
-		rerr, err := io.ReadAll(stderr)
-		if err != nil {
-			err := fmt.Errorf("error reading stderr: %w", err)
-			span.SetStatus(codes.Error, err.Error())
-			return err
-		}
-		if err := cmd.Wait(); err != nil {
-			span.SetStatus(codes.Error, err.Error())
-			span.SetAttributes(
-				attribute.String("stdout", string(resp)),
-				attribute.String("stderr", string(rerr)),
-			)
 			return err
 		}
-		return dest.SetBytes(resp)
+		return dest.SetBytes(out)
+	}
+}
+
+// load runs the command and returns its raw response, for uncached queries
+// and cache misses.
+func (c *Command) load(ctx context.Context) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "Load")
+	defer span.End()
+	c.addTracingTags(span)
+	timer := prometheus.NewTimer(CommandDuration.WithLabelValues(c.Command))
+	defer timer.ObserveDuration()
+
+	res, err := c.execute(ctx, c.MakeArgs(), nil)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(
+			attribute.String("stdout", string(res.Stdout)),
+			attribute.String("stderr", string(res.Stderr)),
+		)
+		return nil, err
+	}
+	return res.Stdout, nil
+}
+
+// runOutput runs the command with the given arguments, rather than those built
+// by MakeArgs, and returns its output. It is used for tools that don't return
+// ClassAds, e.g. condor_rm. If the command exits with a non-zero status the
+// output is returned along with a *CommandError.
+func (c *Command) runOutput(ctx context.Context, args []string, stdin io.Reader) ([]byte, []byte, error) {
+	ctx, span := tracer.Start(ctx, "Exec")
+	defer span.End()
+	span.SetAttributes(attribute.String("component", "htcondor"))
+	span.SetAttributes(attribute.String("db.type", "htcondor"))
+	span.SetAttributes(attribute.String("db.instance", c.Pool))
+	span.SetAttributes(attribute.String("db.statement", c.Command+" "+strings.Join(args, " ")))
+	timer := prometheus.NewTimer(CommandDuration.WithLabelValues(c.Command))
+	defer timer.ObserveDuration()
+
+	res, err := c.execute(ctx, args, stdin)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(
+			attribute.String("stdout", string(res.Stdout)),
+			attribute.String("stderr", string(res.Stderr)),
+		)
+		return res.Stdout, res.Stderr, err
 	}
+	return res.Stdout, res.Stderr, nil
 }
 
 // Run runs the command and returns the ClassAds.
@@ -317,20 +409,20 @@ func (c *Command) RunWithContext(ctx context.Context) ([]classad.ClassAd, error)
 	c.addTracingTags(span)
 
 	key := c.encodeKey()
-	var resp groupcache.ByteView
+	var resp []byte
 	var err error
 	if c.cache != nil {
-		group := groupcache.GetGroup(c.cacheGroup)
-		err = group.Get(ctx, key, groupcache.ByteViewSink(&resp))
+		// the load may outlive this call if it is shared, so it gets its
+		// own copy of the command
+		resp, err = c.cache.Get(ctx, key, c.cacheLifetime, c.Copy().load)
 	} else {
-		// call the getter directly
-		err = commandGetter()(ctx, key, groupcache.ByteViewSink(&resp))
+		resp, err = c.getDirect(ctx, key)
 	}
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
-	ads, err := classad.ReadClassAds(resp.Reader())
+	ads, err := classad.ReadClassAds(bytes.NewReader(resp))
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return nil, err
@@ -340,6 +432,8 @@ func (c *Command) RunWithContext(ctx context.Context) ([]classad.ClassAd, error)
 
 // Stream runs the command and sends the ClassAds on a channel. Errors are
 // returned on a separate channel. Both will be closed when the command is done.
+// If the command fails, a *CommandError with its standard error output is
+// sent before the channels are closed.
 //
 // N.B. if using Stream with a cache you'll lose much of performance and memory
 // advantages of streaming, since the entire HTCondor response must be read,
@@ -350,7 +444,8 @@ func (c *Command) Stream(ch chan classad.ClassAd, errors chan error) {
 
 // StreamWithContext runs the command with the given context and sends the
 // ClassAds on a channel. Errors are returned on a separate channel. Both will
-// be closed when the command is done.
+// be closed when the command is done. If the command fails, a *CommandError
+// with its standard error output is sent before the channels are closed.
 //
 // N.B. if using Stream with a cache you'll lose much of performance and memory
 // advantages of streaming, since the entire HTCondor response must be read,
@@ -361,11 +456,7 @@ func (c *Command) StreamWithContext(ctx context.Context, ch chan classad.ClassAd
 	c.addTracingTags(span)
 
 	if c.cache != nil {
-		key := c.encodeKey()
-		var resp groupcache.ByteView
-		var err error
-		group := groupcache.GetGroup(c.cacheGroup)
-		err = group.Get(ctx, key, groupcache.ByteViewSink(&resp))
+		resp, err := c.cache.Get(ctx, c.encodeKey(), c.cacheLifetime, c.Copy().load)
 		if err != nil {
 			err = fmt.Errorf("error getting response from cache: %w", err)
 			span.SetStatus(codes.Error, err.Error())
@@ -374,19 +465,36 @@ func (c *Command) StreamWithContext(ctx context.Context, ch chan classad.ClassAd
 			close(ch)
 			return
 		}
-		classad.StreamClassAds(resp.Reader(), ch, errors)
-	} else {
-		cmd := c.CmdContext(ctx)
-		out, err := cmd.StdoutPipe()
+		classad.StreamClassAds(bytes.NewReader(resp), ch, errors)
+	} else if c.opts.coalesce {
+		resp, err := c.getDirect(ctx, c.encodeKey())
 		if err != nil {
-			err = fmt.Errorf("error opening command pipe: %w", err)
+			err = fmt.Errorf("error running command: %w", err)
 			span.SetStatus(codes.Error, err.Error())
 			errors <- err
 			close(errors)
 			close(ch)
 			return
 		}
-		if err := cmd.Start(); err != nil {
+		classad.StreamClassAds(bytes.NewReader(resp), ch, errors)
+	} else if se, ok := c.executor().(StreamExecutor); ok {
+		release, err := c.acquire(ctx)
+		var record func(error)
+		if err == nil {
+			record, err = c.admit()
+		}
+		var p Process
+		if err == nil {
+			p, err = se.Start(ctx, c.Command, c.MakeArgs(), nil)
+			if err != nil {
+				err = c.commandError(nil, err)
+				record(err)
+			}
+		}
+		if err != nil {
+			if release != nil {
+				release()
+			}
 			err = fmt.Errorf("error running command: %w", err)
 			span.SetStatus(codes.Error, err.Error())
 			errors <- err
@@ -394,9 +502,66 @@ func (c *Command) StreamWithContext(ctx context.Context, ch chan classad.ClassAd
 			close(ch)
 			return
 		}
-		classad.StreamClassAds(out, ch, errors)
-		cmd.Wait()
+		streamOutput(p.Stdout(), ch, errors, func() error {
+			res, err := p.Wait()
+			release()
+			err = c.commandError(res, err)
+			record(err)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		})
+	} else {
+		// the executor can't stream, so buffer the output
+		res, err := c.executeOnce(ctx, c.MakeArgs(), nil)
+		if err != nil && res.ExitCode == 0 {
+			err = fmt.Errorf("error running command: %w", err)
+			span.SetStatus(codes.Error, err.Error())
+			errors <- err
+			close(errors)
+			close(ch)
+			return
+		}
+		streamOutput(bytes.NewReader(res.Stdout), ch, errors, func() error {
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		})
+	}
+}
+
+// streamOutput reads ClassAds from r and sends them on ch, with any errors
+// reading them on errors. When r is exhausted it calls wait, which returns the
+// error from the command, if any, and sends that too before closing both
+// channels, so that callers can tell a failed command from one with no
+// output.
+func streamOutput(r io.Reader, ch chan classad.ClassAd, errors chan error, wait func() error) {
+	ads := make(chan classad.ClassAd)
+	readErrs := make(chan error)
+	go classad.StreamClassAds(r, ads, readErrs)
+	for ads != nil || readErrs != nil {
+		select {
+		case ad, ok := <-ads:
+			if !ok {
+				ads = nil
+				continue
+			}
+			ch <- ad
+		case err, ok := <-readErrs:
+			if !ok {
+				readErrs = nil
+				continue
+			}
+			errors <- err
+		}
+	}
+	if err := wait(); err != nil {
+		errors <- err
 	}
+	close(errors)
+	close(ch)
 }
 
 func (c *Command) addTracingTags(span trace.Span) {
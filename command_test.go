@@ -1,6 +1,10 @@
 package htcondor
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"testing"
 
 	"github.com/golang/groupcache"
@@ -202,3 +206,60 @@ func TestCondorHistoryAttribute(t *testing.T) {
 	}
 	t.Log(ads)
 }
+
+// fakeStreamExecutor streams fixed output and exits with a fixed result.
+type fakeStreamExecutor struct {
+	fakeExecutor
+}
+
+type fakeProcess struct {
+	stdout io.Reader
+	result Result
+}
+
+func (p *fakeProcess) Stdout() io.Reader {
+	return p.stdout
+}
+
+func (p *fakeProcess) Wait() (*Result, error) {
+	res := p.result
+	res.Stdout = nil
+	return &res, nil
+}
+
+func (f *fakeStreamExecutor) Start(ctx context.Context, name string, args []string, stdin io.Reader) (Process, error) {
+	return &fakeProcess{stdout: bytes.NewReader(f.result.Stdout), result: f.result}, nil
+}
+
+func TestStreamExitError(t *testing.T) {
+	failed := Result{
+		Stdout:   []byte("Name = \"slot1@host\"\n\n"),
+		Stderr:   []byte("Error: communication error\n"),
+		ExitCode: 1,
+	}
+	executors := map[string]Executor{
+		"buffered":  &fakeExecutor{result: failed},
+		"streaming": &fakeStreamExecutor{fakeExecutor{result: failed}},
+	}
+	for name, e := range executors {
+		t.Run(name, func(t *testing.T) {
+			ads, err := stream(NewCommand("condor_status").WithExecutor(e))
+			if len(ads) != 1 {
+				t.Errorf("expected the ad output before the failure, got %d ads", len(ads))
+			}
+			var exitErr *ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("expected *ExitError, got %v", err)
+			}
+			if exitErr.ExitCode != 1 || exitErr.Stderr != "Error: communication error" {
+				t.Errorf("unexpected error %+v", exitErr)
+			}
+		})
+	}
+
+	ok := &fakeStreamExecutor{}
+	ads, err := stream(NewCommand("condor_status").WithExecutor(ok))
+	if err != nil || len(ads) != 0 {
+		t.Errorf("expected no ads and no error, got %d ads, %v", len(ads), err)
+	}
+}
@@ -0,0 +1,301 @@
+package htcondor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// ErrConfigNotDefined is returned by Config.Get when the parameter is not
+// defined.
+var ErrConfigNotDefined = errors.New("configuration parameter not defined")
+
+// ConfigDaemon selects which daemon's configuration condor_config_val queries
+// remotely.
+type ConfigDaemon string
+
+// Daemons whose configuration can be queried.
+const (
+	ConfigMaster     ConfigDaemon = "-master"
+	ConfigSchedd     ConfigDaemon = "-schedd"
+	ConfigStartd     ConfigDaemon = "-startd"
+	ConfigCollector  ConfigDaemon = "-collector"
+	ConfigNegotiator ConfigDaemon = "-negotiator"
+)
+
+var (
+	configValueRegexp      = regexp.MustCompile(`^([A-Za-z0-9_.]+)\s*[=:]\s?(.*)$`)
+	configAtRegexp         = regexp.MustCompile(`^#\s*at:\s*(.*?)(?:, line (\d+))?$`)
+	configRawRegexp        = regexp.MustCompile(`^#\s*raw:\s*[A-Za-z0-9_.]+\s*=\s?(.*)$`)
+	configDefinedInRegexp  = regexp.MustCompile(`^Defined in '(.*)', line (\d+)\.?$`)
+	configNotDefinedRegexp = regexp.MustCompile(`Not defined: (\S+)`)
+)
+
+// Config queries HTCondor configuration with condor_config_val, either locally
+// or from a running daemon.
+type Config struct {
+	// cmd holds the -pool and -name arguments shared by all commands.
+	cmd    *Command
+	daemon ConfigDaemon
+}
+
+// NewConfig creates a client for the local configuration, as read from the
+// configuration files by condor_config_val.
+func NewConfig() *Config {
+	return &Config{cmd: NewCommand("")}
+}
+
+// NewDaemonConfig creates a client for the configuration of a running daemon.
+// The pool and name may be empty to use the local pool or daemon.
+func NewDaemonConfig(pool, name string, daemon ConfigDaemon) *Config {
+	return &Config{
+		cmd:    NewCommand("").WithPool(pool).WithName(name),
+		daemon: daemon,
+	}
+}
+
+// Command returns a new Command for the configuration's target, e.g. to run
+// condor_config_val with options not covered by Config.
+func (c *Config) Command(command string) *Command {
+	cc := c.cmd.Copy()
+	cc.Command = command
+	return cc
+}
+
+// WithExecutor sets the executor used to run condor_config_val, e.g. a fake
+// for testing.
+func (c *Config) WithExecutor(e Executor) *Config {
+	c.cmd.WithExecutor(e)
+	return c
+}
+
+// args returns the arguments selecting the configuration to query.
+func (c *Config) args() []string {
+	args := c.cmd.targetArgs()
+	if c.daemon != "" {
+		args = append(args, string(c.daemon))
+	}
+	return args
+}
+
+// ConfigValue is the value of a configuration parameter.
+type ConfigValue struct {
+	Name  string
+	Value string
+	// Raw is the value as written in the configuration, before macros are
+	// expanded, if known.
+	Raw string
+	// Source is the file the value was defined in, or e.g. "<Default>" for
+	// built-in defaults, if known.
+	Source string
+	// Line is the line number of the definition in Source, if known.
+	Line int
+}
+
+// IsDefault returns true if the value is HTCondor's built-in default.
+func (v ConfigValue) IsDefault() bool {
+	return v.Source == "<Default>"
+}
+
+// String returns the value.
+func (v ConfigValue) String() string {
+	return v.Value
+}
+
+// Bool returns the value as a boolean. Like HTCondor, it accepts true/false
+// (and T/F, yes/no, or numbers) as well as ClassAd expressions.
+func (v ConfigValue) Bool() (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(v.Value)) {
+	case "true", "t", "yes", "y":
+		return true, nil
+	case "false", "f", "no", "n":
+		return false, nil
+	}
+	a, err := v.eval()
+	if err != nil {
+		return false, err
+	}
+	b, err := a.Bool()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", v.Name, err)
+	}
+	return b, nil
+}
+
+// Int returns the value as an integer. ClassAd expressions such as "60 * 5"
+// are evaluated.
+func (v ConfigValue) Int() (int64, error) {
+	a, err := v.eval()
+	if err != nil {
+		return 0, err
+	}
+	if a.Type != classad.Integer {
+		return 0, fmt.Errorf("%s: not an integer: %s", v.Name, v.Value)
+	}
+	return a.Value.(int64), nil
+}
+
+// Float returns the value as a real number. ClassAd expressions are
+// evaluated.
+func (v ConfigValue) Float() (float64, error) {
+	a, err := v.eval()
+	if err != nil {
+		return 0, err
+	}
+	switch a.Type {
+	case classad.Integer:
+		return float64(a.Value.(int64)), nil
+	case classad.Real:
+		return a.Value.(float64), nil
+	}
+	return 0, fmt.Errorf("%s: not a number: %s", v.Name, v.Value)
+}
+
+// Duration returns the value as a duration. HTCondor durations are numbers of
+// seconds (which may be expressions); Go duration strings such as "5m" are
+// also accepted.
+func (v ConfigValue) Duration() (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.TrimSpace(v.Value)); err == nil {
+		return d, nil
+	}
+	f, err := v.Float()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+// List returns the value as a list, split on commas and whitespace as
+// HTCondor does for list parameters.
+func (v ConfigValue) List() []string {
+	return strings.FieldsFunc(v.Value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// eval evaluates the value as a ClassAd expression.
+func (v ConfigValue) eval() (classad.Attribute, error) {
+	x, err := classad.ParseExpr(v.Value)
+	if err != nil {
+		return classad.Attribute{}, fmt.Errorf("%s: invalid value \"%s\": %w", v.Name, v.Value, err)
+	}
+	return x.Eval(classad.ClassAd{}), nil
+}
+
+// ConfigValues is a list of configuration values, as returned by Config.Dump.
+type ConfigValues []ConfigValue
+
+// Lookup returns the named value. Names are case-insensitive.
+func (vs ConfigValues) Lookup(name string) (ConfigValue, bool) {
+	for _, v := range vs {
+		if strings.EqualFold(v.Name, name) {
+			return v, true
+		}
+	}
+	return ConfigValue{}, false
+}
+
+// Get returns the value of a configuration parameter, with where it was
+// defined. If the parameter is not defined, the error matches
+// ErrConfigNotDefined.
+func (c *Config) Get(ctx context.Context, name string) (ConfigValue, error) {
+	cmd := c.Command("condor_config_val")
+	args := append(c.args(), "-verbose", name)
+	stdout, stderr, err := cmd.runOutput(ctx, args, nil)
+	if m := configNotDefinedRegexp.FindSubmatch(append(stdout, stderr...)); m != nil {
+		return ConfigValue{}, fmt.Errorf("%s: %w", m[1], ErrConfigNotDefined)
+	}
+	if err != nil {
+		return ConfigValue{}, configError(err)
+	}
+	vs := parseConfigOutput(stdout)
+	if v, ok := vs.Lookup(name); ok {
+		return v, nil
+	}
+	return ConfigValue{}, fmt.Errorf("%s: %w", name, ErrConfigNotDefined)
+}
+
+// ConfigDumpOptions are options for Config.Dump.
+type ConfigDumpOptions struct {
+	// Pattern restricts the dump to parameters whose names match the
+	// (case-insensitive) regular expression.
+	Pattern string
+	// Verbose records where each value is defined.
+	Verbose bool
+	// Expand expands macros in the values. Otherwise values are returned as
+	// written.
+	Expand bool
+}
+
+// Dump returns every configuration parameter, or those matching the pattern,
+// with condor_config_val -dump.
+func (c *Config) Dump(ctx context.Context, opts ConfigDumpOptions) (ConfigValues, error) {
+	cmd := c.Command("condor_config_val")
+	args := append(c.args(), "-dump")
+	if opts.Verbose {
+		args = append(args, "-verbose")
+	}
+	if opts.Expand {
+		args = append(args, "-expand")
+	}
+	if opts.Pattern != "" {
+		args = append(args, opts.Pattern)
+	}
+	stdout, _, err := cmd.runOutput(ctx, args, nil)
+	if err != nil {
+		return nil, configError(err)
+	}
+	return parseConfigOutput(stdout), nil
+}
+
+// configError returns the error for a failed condor_config_val. The standard
+// error output is reported by the *ExitError.
+func configError(err error) error {
+	return fmt.Errorf("condor_config_val failed: %w", err)
+}
+
+// parseConfigOutput parses "NAME = value" lines from condor_config_val, with
+// the "# at:" and "# raw:" lines that follow each value in verbose output
+// (or "Defined in" lines from older versions). Other comment lines are
+// ignored.
+func parseConfigOutput(out []byte) ConfigValues {
+	vs := make(ConfigValues, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), classad.ScanBufferSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if indented && len(vs) > 0 {
+			v := &vs[len(vs)-1]
+			if m := configAtRegexp.FindStringSubmatch(line); m != nil {
+				v.Source = m[1]
+				v.Line, _ = strconv.Atoi(m[2])
+			} else if m := configRawRegexp.FindStringSubmatch(line); m != nil {
+				v.Raw = m[1]
+			} else if m := configDefinedInRegexp.FindStringSubmatch(line); m != nil {
+				v.Source = m[1]
+				v.Line, _ = strconv.Atoi(m[2])
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := configValueRegexp.FindStringSubmatch(line); m != nil {
+			vs = append(vs, ConfigValue{Name: m[1], Value: m[2]})
+		}
+	}
+	return vs
+}
@@ -0,0 +1,365 @@
+// Package config reads HTCondor configuration files without HTCondor: it finds
+// the configuration with CONDOR_CONFIG, follows LOCAL_CONFIG_DIR,
+// LOCAL_CONFIG_FILE and include statements, applies @use meta-knobs and
+// if/elif/else/endif conditionals, and expands macros as a daemon of a given
+// subsystem would see them.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by Find when no configuration file is found.
+var ErrNotFound = errors.New("HTCondor configuration file not found")
+
+// envPrefix is the prefix of environment variables that override
+// configuration parameters, e.g. _CONDOR_SCHEDD_NAME.
+const envPrefix = "_CONDOR_"
+
+// Defaults are the built-in values of the parameters that affect how the
+// configuration is read. Other HTCondor defaults are not included; use
+// Options.Defaults to add them.
+var Defaults = map[string]string{
+	"DAEMON_LIST":                     "MASTER",
+	"LOCAL_CONFIG_DIR_EXCLUDE_REGEXP": `^((\..*)|(.*~)|(#.*)|(.*\.rpmsave)|(.*\.rpmnew))$`,
+	"REQUIRE_LOCAL_CONFIG_FILE":       "true",
+}
+
+// Options configures how the configuration is read and expanded.
+type Options struct {
+	// Subsystem is the daemon subsystem, e.g. "SCHEDD", whose prefixed
+	// parameters (SCHEDD.FOO) override unprefixed ones.
+	Subsystem string
+	// LocalName is the daemon's local name, whose prefixed parameters
+	// (SCHEDD.name.FOO or name.FOO) override subsystem ones.
+	LocalName string
+	// Version is the HTCondor version compared against by "if version"
+	// conditionals, e.g. "23.0.4".
+	Version string
+	// Defaults are additional default parameter values, set before any file
+	// is read.
+	Defaults map[string]string
+	// MetaKnobs are additional meta-knobs for @use statements, keyed by
+	// category and then name, which override the built-in MetaKnobs.
+	MetaKnobs map[string]map[string]string
+	// Getenv looks up environment variables, for CONDOR_CONFIG and $ENV().
+	// Defaults to os.Getenv.
+	Getenv func(string) string
+	// Environ lists environment variables, for _CONDOR_ overrides. Defaults
+	// to os.Environ.
+	Environ func() []string
+	// Rand is the source for $RANDOM_INTEGER() and $RANDOM_CHOICE(). Defaults
+	// to a randomly seeded source.
+	Rand *rand.Rand
+}
+
+// Param is a configuration parameter as it was set, before macro expansion.
+type Param struct {
+	// Name is the parameter name, as written, including any subsystem or
+	// local name prefix.
+	Name string
+	// Value is the unexpanded value.
+	Value string
+	// File is the file the parameter was set in, or "<Default>",
+	// "<Environment>" or a meta-knob name such as "<ROLE:Submit>".
+	File string
+	// Line is the line number in File, if known.
+	Line int
+}
+
+// Config is a parsed HTCondor configuration.
+type Config struct {
+	opts   Options
+	params map[string]Param
+	files  []string
+}
+
+// New creates a configuration with only the default parameters set.
+func New(opts Options) *Config {
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+	if opts.Environ == nil {
+		opts.Environ = os.Environ
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.New(rand.NewSource(rand.Int63()))
+	}
+	c := Config{
+		opts:   opts,
+		params: make(map[string]Param),
+		files:  make([]string, 0),
+	}
+	for k, v := range Defaults {
+		c.params[strings.ToUpper(k)] = Param{Name: k, Value: v, File: "<Default>"}
+	}
+	for k, v := range opts.Defaults {
+		c.params[strings.ToUpper(k)] = Param{Name: k, Value: v, File: "<Default>"}
+	}
+	if h, err := os.Hostname(); err == nil {
+		c.setDefault("FULL_HOSTNAME", h)
+		c.setDefault("HOSTNAME", strings.SplitN(h, ".", 2)[0])
+	}
+	if opts.Subsystem != "" {
+		c.setDefault("SUBSYSTEM", strings.ToUpper(opts.Subsystem))
+	}
+	if opts.LocalName != "" {
+		c.setDefault("LOCALNAME", opts.LocalName)
+	}
+	return &c
+}
+
+// setDefault sets a default value if the parameter isn't already set.
+func (c *Config) setDefault(name, value string) {
+	if _, ok := c.params[name]; !ok {
+		c.params[name] = Param{Name: name, Value: value, File: "<Default>"}
+	}
+}
+
+// Find returns the path of the main configuration file: the CONDOR_CONFIG
+// environment variable if set, or else the first of /etc/condor/condor_config,
+// /usr/local/etc/condor_config and ~condor/condor_config that exists. It
+// returns an empty path if CONDOR_CONFIG is "ONLY_ENV", meaning the
+// configuration comes only from the environment.
+func Find(getenv func(string) string) (string, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if path := getenv("CONDOR_CONFIG"); path != "" {
+		if path == "ONLY_ENV" {
+			return "", nil
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("CONDOR_CONFIG: %w", err)
+		}
+		return path, nil
+	}
+	paths := []string{"/etc/condor/condor_config", "/usr/local/etc/condor_config"}
+	if u, err := user.Lookup("condor"); err == nil {
+		paths = append(paths, filepath.Join(u.HomeDir, "condor_config"))
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Load finds and reads the configuration as a daemon would: the main file
+// (see Find), then the files in LOCAL_CONFIG_DIR, then LOCAL_CONFIG_FILE,
+// then _CONDOR_ environment overrides.
+func Load(opts Options) (*Config, error) {
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+	path, err := Find(opts.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		c := New(opts)
+		c.applyEnvironment()
+		return c, nil
+	}
+	return LoadFile(path, opts)
+}
+
+// LoadFile reads the configuration starting from the given main file,
+// followed by LOCAL_CONFIG_DIR, LOCAL_CONFIG_FILE and _CONDOR_ environment
+// overrides.
+func LoadFile(path string, opts Options) (*Config, error) {
+	c := New(opts)
+	if err := c.ReadFile(path); err != nil {
+		return nil, err
+	}
+	if err := c.readLocalConfigDirs(); err != nil {
+		return nil, err
+	}
+	if err := c.readLocalConfigFiles(); err != nil {
+		return nil, err
+	}
+	c.applyEnvironment()
+	return c, nil
+}
+
+// readLocalConfigDirs reads the files in each LOCAL_CONFIG_DIR, in lexical
+// order, skipping those matching LOCAL_CONFIG_DIR_EXCLUDE_REGEXP.
+func (c *Config) readLocalConfigDirs() error {
+	var exclude *regexp.Regexp
+	if re, _ := c.Get("LOCAL_CONFIG_DIR_EXCLUDE_REGEXP"); re != "" {
+		var err error
+		if exclude, err = regexp.Compile(re); err != nil {
+			return fmt.Errorf("invalid LOCAL_CONFIG_DIR_EXCLUDE_REGEXP: %w", err)
+		}
+	}
+	dirs, _ := c.Get("LOCAL_CONFIG_DIR")
+	for _, dir := range splitList(dirs) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("error reading LOCAL_CONFIG_DIR: %w", err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if e.IsDir() || (exclude != nil && exclude.MatchString(e.Name())) {
+				continue
+			}
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := c.ReadFile(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readLocalConfigFiles reads the files in LOCAL_CONFIG_FILE, in order.
+func (c *Config) readLocalConfigFiles() error {
+	files, _ := c.Get("LOCAL_CONFIG_FILE")
+	require := c.Bool("REQUIRE_LOCAL_CONFIG_FILE", true)
+	for _, f := range splitList(files) {
+		if strings.HasSuffix(f, "|") {
+			return fmt.Errorf("LOCAL_CONFIG_FILE %s: commands are not supported", f)
+		}
+		if _, err := os.Stat(f); err != nil {
+			if os.IsNotExist(err) && !require {
+				continue
+			}
+			return fmt.Errorf("LOCAL_CONFIG_FILE: %w", err)
+		}
+		if err := c.ReadFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEnvironment sets parameters from _CONDOR_ environment variables.
+func (c *Config) applyEnvironment() {
+	for _, kv := range c.opts.Environ() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || len(k) <= len(envPrefix) || !strings.EqualFold(k[:len(envPrefix)], envPrefix) {
+			continue
+		}
+		c.set(Param{Name: k[len(envPrefix):], Value: v, File: "<Environment>"})
+	}
+}
+
+// set sets a parameter. References to the parameter's own previous value, as
+// in "FOO = $(FOO) bar", and $RANDOM_INTEGER() and $RANDOM_CHOICE(), are
+// expanded immediately.
+func (c *Config) set(p Param) {
+	key := strings.ToUpper(p.Name)
+	old, had := c.params[key]
+	p.Value = c.expandAssignment(p.Value, p.Name, old.Value, had)
+	c.params[key] = p
+}
+
+// Files returns the files read, in order.
+func (c *Config) Files() []string {
+	return c.files
+}
+
+// Names returns the names of every parameter set, sorted.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.params))
+	for _, p := range c.params {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Param returns the parameter that applies to the subsystem and local name,
+// before macro expansion. Names are case-insensitive.
+func (c *Config) Param(name string) (Param, bool) {
+	for _, n := range c.candidates(name) {
+		if p, ok := c.params[strings.ToUpper(n)]; ok {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// candidates returns the names to look up for a parameter, most specific
+// first: SUBSYS.LOCALNAME.NAME, LOCALNAME.NAME, SUBSYS.NAME, NAME.
+func (c *Config) candidates(name string) []string {
+	names := make([]string, 0, 4)
+	if c.opts.LocalName != "" {
+		if c.opts.Subsystem != "" {
+			names = append(names, c.opts.Subsystem+"."+c.opts.LocalName+"."+name)
+		}
+		names = append(names, c.opts.LocalName+"."+name)
+	}
+	if c.opts.Subsystem != "" {
+		names = append(names, c.opts.Subsystem+"."+name)
+	}
+	return append(names, name)
+}
+
+// Get returns the expanded value of a parameter, as seen by the subsystem and
+// local name. Parameters that are not set, or set to an empty value, are not
+// defined.
+func (c *Config) Get(name string) (string, bool) {
+	p, ok := c.Param(name)
+	if !ok {
+		return "", false
+	}
+	v := c.expand(p.Value, 0)
+	return v, v != ""
+}
+
+// Bool returns the value of a boolean parameter, or def if it is not defined
+// or not a boolean.
+func (c *Config) Bool(name string, def bool) bool {
+	v, ok := c.Get(name)
+	if !ok {
+		return def
+	}
+	if b, ok := parseBool(v); ok {
+		return b
+	}
+	return def
+}
+
+// Expanded returns the expanded value of every parameter, keyed by name, e.g.
+// for diffing configurations.
+func (c *Config) Expanded() map[string]string {
+	m := make(map[string]string, len(c.params))
+	for _, p := range c.params {
+		m[p.Name] = c.expand(p.Value, 0)
+	}
+	return m
+}
+
+// parseBool parses HTCondor boolean values.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "y", "1":
+		return true, true
+	case "false", "f", "no", "n", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// splitList splits a list parameter on commas and whitespace.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
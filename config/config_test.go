@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testOptions() Options {
+	return Options{
+		Version: "23.0.4",
+		Getenv: func(k string) string {
+			if k == "HOME" {
+				return "/home/condor"
+			}
+			return ""
+		},
+		Environ: func() []string { return []string{"_CONDOR_SCHEDD_DEBUG=D_FULLDEBUG", "PATH=/bin"} },
+		Rand:    rand.New(rand.NewSource(1)),
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"condor_config": `# main config
+RELEASE_DIR = /usr
+LOCAL_DIR = /var
+LOG = $(LOCAL_DIR)/log/condor
+LOCAL_CONFIG_DIR = ` + filepath.Join(dir, "config.d") + `
+LOCAL_CONFIG_FILE = ` + filepath.Join(dir, "local") + `
+use ROLE : Submit, Execute
+include : common.conf
+`,
+		"common.conf":         "ALLOW_WRITE = *.example.com\n",
+		"config.d/10-first":   "MAX_JOBS_RUNNING = 100\nSCHEDD.MAX_JOBS_RUNNING = 200\n",
+		"config.d/20-second":  "MAX_JOBS_RUNNING = $(MAX_JOBS_RUNNING) + 1\n",
+		"config.d/20-second~": "MAX_JOBS_RUNNING = 0\n",
+		"config.d/.hidden":    "MAX_JOBS_RUNNING = 0\n",
+		"local":               "ALLOW_WRITE = $(ALLOW_WRITE), *.example.org\nSCHEDD.backup.MAX_JOBS_RUNNING = 5\n",
+	})
+	c, err := LoadFile(filepath.Join(dir, "condor_config"), testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []string{
+		filepath.Join(dir, "condor_config"),
+		filepath.Join(dir, "common.conf"),
+		filepath.Join(dir, "config.d/10-first"),
+		filepath.Join(dir, "config.d/20-second"),
+		filepath.Join(dir, "local"),
+	}
+	if !reflect.DeepEqual(c.Files(), expectedFiles) {
+		t.Errorf("expected files %q, got %q", expectedFiles, c.Files())
+	}
+	tests := map[string]string{
+		"log":              "/var/log/condor",
+		"DAEMON_LIST":      "MASTER SCHEDD STARTD",
+		"ALLOW_WRITE":      "*.example.com, *.example.org",
+		"MAX_JOBS_RUNNING": "100 + 1",
+		"SCHEDD_DEBUG":     "D_FULLDEBUG",
+	}
+	for name, expected := range tests {
+		if v, _ := c.Get(name); v != expected {
+			t.Errorf("%s: expected \"%s\", got \"%s\"", name, expected, v)
+		}
+	}
+	p, _ := c.Param("MAX_JOBS_RUNNING")
+	if p.File != filepath.Join(dir, "config.d/20-second") || p.Line != 1 {
+		t.Errorf("unexpected source %+v", p)
+	}
+
+	// subsystem and local name overrides
+	opts := testOptions()
+	opts.Subsystem = "SCHEDD"
+	c, err = LoadFile(filepath.Join(dir, "condor_config"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := c.Get("MAX_JOBS_RUNNING"); v != "200" {
+		t.Errorf("expected SCHEDD override 200, got %s", v)
+	}
+	opts.LocalName = "backup"
+	c, err = LoadFile(filepath.Join(dir, "condor_config"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := c.Get("MAX_JOBS_RUNNING"); v != "5" {
+		t.Errorf("expected local name override 5, got %s", v)
+	}
+}
+
+func TestLoadFile_missingLocal(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"condor_config": "LOCAL_CONFIG_FILE = /nonexistent/local\n",
+	})
+	if _, err := LoadFile(filepath.Join(dir, "condor_config"), testOptions()); err == nil {
+		t.Error("expected error for missing local config file")
+	}
+	writeFiles(t, dir, map[string]string{
+		"condor_config": "LOCAL_CONFIG_FILE = /nonexistent/local\nREQUIRE_LOCAL_CONFIG_FILE = false\n",
+	})
+	if _, err := LoadFile(filepath.Join(dir, "condor_config"), testOptions()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	src := `A = 1
+if defined A
+  B = yes
+elif version >= 8.0
+  B = elif
+else
+  B = no
+endif
+if version < 10
+  C = old
+elif version >= 23.0.1
+  C = new
+  if false
+    C = nested
+  endif
+endif
+if ! defined UNDEFINED_THING
+  D = $(UNDEFINED_THING:default) $(DOLLAR)(x) $$(OpSys) $ENV(HOME)
+endif
+if $(A) == 1
+  E = expr
+endif
+MULTI @=end
+line one
+line two
+@end
+R = $RANDOM_INTEGER(10, 20, 5)
+RC = $RANDOM_CHOICE(a, b)
+SELF = $(SELF:start) more
+SELF = $(SELF) again
+`
+	c := New(testOptions())
+	if err := c.Read(strings.NewReader(src), "test"); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"B":     "yes",
+		"C":     "new",
+		"D":     "default $(x) $$(OpSys) /home/condor",
+		"E":     "expr",
+		"MULTI": "line one\nline two",
+		"SELF":  "start more again",
+	}
+	for name, expected := range tests {
+		if v, _ := c.Get(name); v != expected {
+			t.Errorf("%s: expected \"%s\", got \"%s\"", name, expected, v)
+		}
+	}
+	r, _ := c.Get("R")
+	if r != "10" && r != "15" && r != "20" {
+		t.Errorf("unexpected random integer %s", r)
+	}
+	if r2, _ := c.Get("R"); r2 != r {
+		t.Error("random integer changed between lookups")
+	}
+	if rc, _ := c.Get("RC"); rc != "a" && rc != "b" {
+		t.Errorf("unexpected random choice %s", rc)
+	}
+}
+
+func TestRead_bad(t *testing.T) {
+	tests := []string{
+		"if true\nA = 1\n",
+		"endif\n",
+		"if true\nelse\nelif true\nendif\n",
+		"not a statement\n",
+		"use ROLE : NoSuchRole\n",
+		"include command : /bin/true\n",
+		"error : stop here\n",
+		"A @=end\nno end\n",
+	}
+	for _, src := range tests {
+		err := New(testOptions()).Read(strings.NewReader(src), "test")
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%q: expected syntax error, got %v", src, err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"condor_config": ""})
+	path := filepath.Join(dir, "condor_config")
+	p, err := Find(func(k string) string {
+		if k == "CONDOR_CONFIG" {
+			return path
+		}
+		return ""
+	})
+	if err != nil || p != path {
+		t.Errorf("expected %s, got %s (%v)", path, p, err)
+	}
+	p, err = Find(func(string) string { return "ONLY_ENV" })
+	if err != nil || p != "" {
+		t.Errorf("expected no path for ONLY_ENV, got %s (%v)", p, err)
+	}
+	if _, err := Find(func(string) string { return filepath.Join(dir, "missing") }); err == nil {
+		t.Error("expected error for missing CONDOR_CONFIG")
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/internal/macro"
+)
+
+// expand expands the macros in s: $(NAME), $(NAME:default), $ENV(NAME) and
+// $(DOLLAR). Undefined macros expand to the empty string. Match-time
+// substitutions ($$(NAME)) and unsupported functions are left as they are.
+func (c *Config) expand(s string, depth int) string {
+	if depth > macro.MaxDepth {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		ref, ok := macro.Next(s, i)
+		if !ok || ref.End < 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		b.WriteString(s[i:ref.Start])
+		i = ref.End + 1
+		if ref.Func == "$" {
+			b.WriteString(s[ref.Start:i])
+			continue
+		}
+		arg := c.expand(ref.Arg(s), depth+1)
+		switch ref.Func {
+		case "":
+			name, def, hasDef := strings.Cut(arg, ":")
+			if strings.EqualFold(name, "DOLLAR") {
+				b.WriteByte('$')
+			} else if p, ok := c.Param(strings.TrimSpace(name)); ok && p.Value != "" {
+				b.WriteString(c.expand(p.Value, depth+1))
+			} else if hasDef {
+				b.WriteString(def)
+			}
+		case "ENV":
+			b.WriteString(c.opts.Getenv(strings.TrimSpace(arg)))
+		default:
+			b.WriteString("$" + ref.Func + "(" + arg + ")")
+		}
+	}
+	return b.String()
+}
+
+// expandAssignment expands what HTCondor expands when a parameter is set:
+// references to the parameter's own previous value, and the random functions,
+// which are evaluated once.
+func (c *Config) expandAssignment(s, name, old string, had bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		ref, ok := macro.Next(s, i)
+		if !ok || ref.End < 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		b.WriteString(s[i:ref.Start])
+		i = ref.End + 1
+		start, end := ref.Start, ref.End
+		arg := ref.Arg(s)
+		switch ref.Func {
+		case "":
+			n, def, hasDef := strings.Cut(arg, ":")
+			switch {
+			case !strings.EqualFold(strings.TrimSpace(n), name):
+				b.WriteString(s[start : end+1])
+			case had && old != "":
+				b.WriteString(old)
+			case hasDef:
+				b.WriteString(def)
+			}
+		case "RANDOM_INTEGER":
+			b.WriteString(c.randomInteger(c.expand(arg, 0), s[start:end+1]))
+		case "RANDOM_CHOICE":
+			choices := strings.Split(c.expand(arg, 0), ",")
+			b.WriteString(strings.TrimSpace(choices[c.opts.Rand.Intn(len(choices))]))
+		default:
+			b.WriteString(s[start : end+1])
+		}
+	}
+	return b.String()
+}
+
+// randomInteger evaluates $RANDOM_INTEGER(min, max[, step]), returning orig if
+// the arguments are invalid.
+func (c *Config) randomInteger(arg, orig string) string {
+	args := strings.Split(arg, ",")
+	if len(args) < 2 || len(args) > 3 {
+		return orig
+	}
+	vals := make([]int64, len(args))
+	for i, a := range args {
+		v, err := strconv.ParseInt(strings.TrimSpace(a), 10, 64)
+		if err != nil {
+			return orig
+		}
+		vals[i] = v
+	}
+	step := int64(1)
+	if len(vals) == 3 && vals[2] > 0 {
+		step = vals[2]
+	}
+	if vals[1] < vals[0] {
+		return orig
+	}
+	n := (vals[1]-vals[0])/step + 1
+	return strconv.FormatInt(vals[0]+c.opts.Rand.Int63n(n)*step, 10)
+}
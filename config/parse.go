@@ -0,0 +1,404 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// maxIncludeDepth limits nested include files and meta-knobs.
+const maxIncludeDepth = 20
+
+var (
+	assignmentRegexp = regexp.MustCompile(`^([A-Za-z0-9_.]+)\s*=\s?(.*)$`)
+	multilineRegexp  = regexp.MustCompile(`^([A-Za-z0-9_.]+)\s*@=\s*(\S+)$`)
+	versionRegexp    = regexp.MustCompile(`^version\s*(==|!=|<=|>=|<|>)?\s*(\d+(?:\.\d+){0,2})$`)
+)
+
+// SyntaxError is an error in a configuration file.
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s, line %d: %s", e.File, e.Line, e.Msg)
+}
+
+// ReadFile reads a configuration file into the configuration. Relative include
+// paths are resolved against the file's directory.
+func (c *Config) ReadFile(path string) error {
+	return c.readFile(path, 0)
+}
+
+func (c *Config) readFile(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: include files nested too deeply", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %w", err)
+	}
+	defer f.Close()
+	c.files = append(c.files, path)
+	return c.read(f, path, filepath.Dir(path), depth)
+}
+
+// Read reads configuration statements from r into the configuration. The name
+// is used in errors and as the File of the parameters set.
+func (c *Config) Read(r io.Reader, name string) error {
+	return c.read(r, name, ".", 0)
+}
+
+// conditional is the state of an if/elif/else/endif block.
+type conditional struct {
+	// active is true if statements in the current branch are processed.
+	active bool
+	// taken is true once a branch of the block has been active.
+	taken bool
+	// parent is true if the enclosing block is active.
+	parent bool
+	// inElse is true after the else statement.
+	inElse bool
+	line   int
+}
+
+func (c *Config) read(r io.Reader, name, dir string, depth int) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), classad.ScanBufferSize)
+	conds := make([]conditional, 0)
+	active := func() bool {
+		return len(conds) == 0 || conds[len(conds)-1].active
+	}
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		start := lineNum
+		line := scanner.Text()
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			lineNum++
+			line = strings.TrimSuffix(line, "\\") + scanner.Text()
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		synErr := func(format string, a ...interface{}) error {
+			return &SyntaxError{File: name, Line: start, Msg: fmt.Sprintf(format, a...)}
+		}
+
+		keyword, rest := splitKeyword(line)
+		switch keyword {
+		case "if":
+			cond := conditional{parent: active(), line: start}
+			if cond.parent {
+				ok, err := c.condition(rest)
+				if err != nil {
+					return synErr("%s", err)
+				}
+				cond.active, cond.taken = ok, ok
+			}
+			conds = append(conds, cond)
+			continue
+		case "elif", "else":
+			if len(conds) == 0 {
+				return synErr("%s without if", keyword)
+			}
+			cond := &conds[len(conds)-1]
+			if cond.inElse {
+				return synErr("%s after else", keyword)
+			}
+			cond.active = false
+			if keyword == "else" {
+				cond.inElse = true
+				cond.active = cond.parent && !cond.taken
+			} else if cond.parent && !cond.taken {
+				ok, err := c.condition(rest)
+				if err != nil {
+					return synErr("%s", err)
+				}
+				cond.active, cond.taken = ok, ok
+			}
+			continue
+		case "endif":
+			if len(conds) == 0 {
+				return synErr("endif without if")
+			}
+			conds = conds[:len(conds)-1]
+			continue
+		}
+		if !active() {
+			// skip the body of multi-line values in inactive branches too
+			if m := multilineRegexp.FindStringSubmatch(line); m != nil {
+				if _, err := readMultiline(scanner, &lineNum, m[2]); err != nil {
+					return synErr("%s", err)
+				}
+			}
+			continue
+		}
+
+		switch keyword {
+		case "include":
+			if err := c.include(rest, dir, depth); err != nil {
+				return synErr("%s", err)
+			}
+			continue
+		case "use", "@use":
+			if err := c.use(rest, depth); err != nil {
+				return synErr("%s", err)
+			}
+			continue
+		case "error":
+			return synErr("%s", c.expand(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ":")), 0))
+		case "warning":
+			continue
+		}
+
+		if m := multilineRegexp.FindStringSubmatch(line); m != nil {
+			v, err := readMultiline(scanner, &lineNum, m[2])
+			if err != nil {
+				return synErr("%s", err)
+			}
+			c.set(Param{Name: m[1], Value: v, File: name, Line: start})
+			continue
+		}
+		m := assignmentRegexp.FindStringSubmatch(line)
+		if m == nil {
+			return synErr("invalid statement: \"%s\"", line)
+		}
+		c.set(Param{Name: m[1], Value: strings.TrimSpace(m[2]), File: name, Line: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(conds) > 0 {
+		return &SyntaxError{File: name, Line: conds[len(conds)-1].line, Msg: "if without endif"}
+	}
+	return nil
+}
+
+// splitKeyword returns the statement keyword of a line (lower case), if it
+// starts with one, and the rest of the line.
+func splitKeyword(line string) (string, string) {
+	word := line
+	if i := strings.IndexAny(line, " \t:"); i >= 0 {
+		word = line[:i]
+	}
+	kw := strings.ToLower(word)
+	switch kw {
+	case "if", "elif", "else", "endif", "include", "use", "@use", "error", "warning":
+		rest := line[len(word):]
+		// "use = 1" is an assignment, not a statement
+		if strings.HasPrefix(strings.TrimSpace(rest), "=") {
+			return "", line
+		}
+		return kw, strings.TrimSpace(rest)
+	}
+	return "", line
+}
+
+// readMultiline reads the lines of a "NAME @=tag" value up to "@tag".
+func readMultiline(scanner *bufio.Scanner, lineNum *int, tag string) (string, error) {
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		*lineNum++
+		if strings.TrimSpace(scanner.Text()) == "@"+tag {
+			return strings.Join(lines, "\n"), nil
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return "", fmt.Errorf("missing @%s", tag)
+}
+
+// include reads the file of an "include [ifexist] : file" statement.
+func (c *Config) include(rest, dir string, depth int) error {
+	mod, file, ok := strings.Cut(rest, ":")
+	if !ok {
+		return fmt.Errorf("include statement missing ':'")
+	}
+	mod = strings.ToLower(strings.TrimSpace(mod))
+	file = strings.TrimSpace(c.expand(strings.TrimSpace(file), 0))
+	if file == "" {
+		return fmt.Errorf("include statement missing file")
+	}
+	switch mod {
+	case "", "ifexist":
+	case "command":
+		return fmt.Errorf("include command is not supported")
+	default:
+		return fmt.Errorf("unsupported include modifier \"%s\"", mod)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if mod == "ifexist" {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	return c.readFile(file, depth+1)
+}
+
+// use applies the meta-knobs of a "use CATEGORY : name[, name...]" statement.
+func (c *Config) use(rest string, depth int) error {
+	category, names, ok := strings.Cut(rest, ":")
+	if !ok {
+		return fmt.Errorf("use statement missing ':'")
+	}
+	category = strings.ToUpper(strings.TrimSpace(category))
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		// arguments, e.g. FEATURE : GPUs(...), are not supported and ignored
+		if i := strings.Index(name, "("); i >= 0 {
+			name = strings.TrimSpace(name[:i])
+		}
+		if name == "" {
+			continue
+		}
+		body, ok := c.metaKnob(category, name)
+		if !ok {
+			return fmt.Errorf("unknown meta-knob %s:%s", category, name)
+		}
+		src := "<" + category + ":" + name + ">"
+		if depth > maxIncludeDepth {
+			return fmt.Errorf("meta-knobs nested too deeply")
+		}
+		if err := c.read(strings.NewReader(body), src, ".", depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metaKnob returns the body of a meta-knob, from Options.MetaKnobs or the
+// built-in MetaKnobs. Names are case-insensitive.
+func (c *Config) metaKnob(category, name string) (string, bool) {
+	for _, knobs := range []map[string]map[string]string{c.opts.MetaKnobs, MetaKnobs} {
+		for cat, m := range knobs {
+			if !strings.EqualFold(cat, category) {
+				continue
+			}
+			for n, body := range m {
+				if strings.EqualFold(n, name) {
+					return body, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// condition evaluates the condition of an if or elif statement: "[!] defined
+// NAME", "[!] version [op] X.Y.Z", or a boolean or number, after macro
+// expansion. Other expressions are evaluated as ClassAd expressions.
+func (c *Config) condition(cond string) (bool, error) {
+	cond = strings.TrimSpace(c.expand(cond, 0))
+	if strings.HasPrefix(cond, "!") {
+		ok, err := c.condition(cond[1:])
+		return !ok, err
+	}
+	if cond == "" {
+		return false, fmt.Errorf("missing condition")
+	}
+	if word, name, ok := strings.Cut(cond, " "); ok && strings.EqualFold(word, "defined") {
+		_, defined := c.Get(strings.TrimSpace(name))
+		return defined, nil
+	}
+	if m := versionRegexp.FindStringSubmatch(strings.ToLower(cond)); m != nil {
+		if c.opts.Version == "" {
+			return false, fmt.Errorf("version conditional, but no version set")
+		}
+		cmp := compareVersions(c.opts.Version, m[2])
+		switch m[1] {
+		case "", "==":
+			return cmp == 0, nil
+		case "!=":
+			return cmp != 0, nil
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		case ">=":
+			return cmp >= 0, nil
+		}
+	}
+	if b, ok := parseBool(cond); ok {
+		return b, nil
+	}
+	x, err := classad.ParseExpr(cond)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition \"%s\"", cond)
+	}
+	b, err := x.Eval(classad.ClassAd{}).Bool()
+	if err != nil {
+		return false, fmt.Errorf("condition \"%s\" is not boolean", cond)
+	}
+	return b, nil
+}
+
+// compareVersions compares dotted versions, treating missing components as
+// zero.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// MetaKnobs are the built-in meta-knobs for @use statements, keyed by category
+// and name. They are a subset of those built into HTCondor, covering the
+// common roles and policies.
+var MetaKnobs = map[string]map[string]string{
+	"ROLE": {
+		"Personal": `CONDOR_HOST = 127.0.0.1
+COLLECTOR_HOST = $(CONDOR_HOST):0
+DAEMON_LIST = MASTER COLLECTOR NEGOTIATOR STARTD SCHEDD
+RunBenchmarks = 0`,
+		"CentralManager": `DAEMON_LIST = $(DAEMON_LIST) COLLECTOR NEGOTIATOR`,
+		"Submit":         `DAEMON_LIST = $(DAEMON_LIST) SCHEDD`,
+		"Execute":        `DAEMON_LIST = $(DAEMON_LIST) STARTD`,
+	},
+	"FEATURE": {
+		"PartitionableSlot": `NUM_SLOTS_TYPE_1 = 1
+SLOT_TYPE_1 = 100%
+SLOT_TYPE_1_PARTITIONABLE = true`,
+		"GPUs": `MACHINE_RESOURCE_INVENTORY_GPUs = $(LIBEXEC)/condor_gpu_discovery -properties $(GPU_DISCOVERY_EXTRA)
+ENVIRONMENT_FOR_AssignedGPUs = CUDA_VISIBLE_DEVICES, GPU_DEVICE_ORDINAL`,
+	},
+	"POLICY": {
+		"Always_Run_Jobs": `START = true
+SUSPEND = false
+CONTINUE = true
+PREEMPT = false
+KILL = false
+WANT_SUSPEND = false
+WANT_VACATE = false`,
+		"Hold_If_Memory_Exceeded": `MEMORY_EXCEEDED = (isDefined(MemoryUsage) && MemoryUsage > RequestMemory)
+PREEMPT = $(MEMORY_EXCEEDED)
+WANT_HOLD = $(MEMORY_EXCEEDED)
+WANT_HOLD_REASON = ifThenElse($(MEMORY_EXCEEDED), "memory usage exceeded request_memory", undefined)`,
+	},
+}
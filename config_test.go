@@ -0,0 +1,88 @@
+package htcondor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseConfigOutput(t *testing.T) {
+	out := `# Configuration from machine: host.example.com
+
+# Parameters with names that match SCHEDD:
+SCHEDD_INTERVAL = 300
+ # at: /etc/condor/config.d/10-schedd.conf, line 4
+ # raw: SCHEDD_INTERVAL = 60 * 5
+SCHEDD_LOG = /var/log/condor/SchedLog
+ # at: <Default>
+ # raw: SCHEDD_LOG = $(LOG)/SchedLog
+OLD_STYLE: true
+  Defined in '/etc/condor/condor_config', line 12.
+`
+	vs := parseConfigOutput([]byte(out))
+	if len(vs) != 3 {
+		t.Fatalf("expected 3 values, got %d: %+v", len(vs), vs)
+	}
+	v, ok := vs.Lookup("schedd_interval")
+	if !ok {
+		t.Fatal("SCHEDD_INTERVAL not found")
+	}
+	expected := ConfigValue{
+		Name:   "SCHEDD_INTERVAL",
+		Value:  "300",
+		Raw:    "60 * 5",
+		Source: "/etc/condor/config.d/10-schedd.conf",
+		Line:   4,
+	}
+	if v != expected {
+		t.Errorf("expected %+v, got %+v", expected, v)
+	}
+	if v, _ := vs.Lookup("SCHEDD_LOG"); !v.IsDefault() || v.Raw != "$(LOG)/SchedLog" {
+		t.Errorf("unexpected SCHEDD_LOG %+v", v)
+	}
+	if v, _ := vs.Lookup("OLD_STYLE"); v.Value != "true" || v.Source != "/etc/condor/condor_config" || v.Line != 12 {
+		t.Errorf("unexpected OLD_STYLE %+v", v)
+	}
+}
+
+func TestConfigValueTypes(t *testing.T) {
+	if b, err := (ConfigValue{Value: "True"}).Bool(); err != nil || !b {
+		t.Errorf("expected true, got %v (%v)", b, err)
+	}
+	if b, err := (ConfigValue{Value: "1 > 2"}).Bool(); err != nil || b {
+		t.Errorf("expected false, got %v (%v)", b, err)
+	}
+	if _, err := (ConfigValue{Value: "maybe"}).Bool(); err == nil {
+		t.Error("expected error for non-boolean")
+	}
+	if i, err := (ConfigValue{Value: "60 * 5"}).Int(); err != nil || i != 300 {
+		t.Errorf("expected 300, got %d (%v)", i, err)
+	}
+	if _, err := (ConfigValue{Value: "1.5"}).Int(); err == nil {
+		t.Error("expected error for real")
+	}
+	if f, err := (ConfigValue{Value: "1.5"}).Float(); err != nil || f != 1.5 {
+		t.Errorf("expected 1.5, got %f (%v)", f, err)
+	}
+	if d, err := (ConfigValue{Value: "300"}).Duration(); err != nil || d != 5*time.Minute {
+		t.Errorf("expected 5m, got %s (%v)", d, err)
+	}
+	if d, err := (ConfigValue{Value: "90s"}).Duration(); err != nil || d != 90*time.Second {
+		t.Errorf("expected 90s, got %s (%v)", d, err)
+	}
+	l := (ConfigValue{Value: "MASTER, SCHEDD  COLLECTOR,NEGOTIATOR"}).List()
+	if len(l) != 4 || l[1] != "SCHEDD" || l[3] != "NEGOTIATOR" {
+		t.Errorf("unexpected list %q", l)
+	}
+}
+
+func TestConfigArgs(t *testing.T) {
+	args := NewDaemonConfig("cm.example.com", "schedd@host", ConfigSchedd).args()
+	expected := []string{"-pool", "cm.example.com", "-name", "schedd@host", "-schedd"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+	if args := NewConfig().args(); len(args) != 0 {
+		t.Errorf("expected no args, got %q", args)
+	}
+}
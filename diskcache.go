@@ -0,0 +1,109 @@
+package htcondor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DiskCache is a Cache that stores values as files in a directory, so that
+// they survive restarts and can be shared by processes on the same machine.
+// Expired files are replaced when the key is next loaded, but are otherwise
+// not removed. Concurrent misses for the same key in a process are loaded
+// once, and the load isn't cancelled while any of their callers is waiting.
+type DiskCache struct {
+	// Dir is the directory the values are stored in.
+	Dir   string
+	loads loadGroup
+}
+
+// NewDiskCache returns a DiskCache storing values in dir, which is created if
+// it doesn't exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+// path returns the path of the file for key.
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the value for key, calling load on a miss. A value that can't
+// be read from disk is treated as a miss, and errors writing the value are
+// ignored, since the value can still be loaded.
+func (c *DiskCache) Get(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	if value, err := c.get(key); err == nil {
+		return value, nil
+	}
+	value, _, err := c.loads.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(ctx, key, value, ttl)
+		return value, nil
+	})
+	return value, err
+}
+
+// get reads the value for key, returning an error matching fs.ErrNotExist if
+// it isn't cached or has expired, or any other error reading it. Each file
+// holds the expiry time, in Unix nanoseconds or 0 for none, on the first
+// line, followed by the value.
+func (c *DiskCache) get(key string) ([]byte, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache file: %w", err)
+	}
+	header, value, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil, fmt.Errorf("corrupt cache file for %q: %w", key, fs.ErrNotExist)
+	}
+	expires, err := strconv.ParseInt(string(header), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt cache file for %q: %w", key, fs.ErrNotExist)
+	}
+	if expires > 0 && time.Now().UnixNano() > expires {
+		return nil, fmt.Errorf("cache file for %q expired: %w", key, fs.ErrNotExist)
+	}
+	return value, nil
+}
+
+// Set writes value for key to disk, replacing any existing value.
+func (c *DiskCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires int64
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).UnixNano()
+	}
+	f, err := os.CreateTemp(c.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating cache file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = fmt.Fprintf(f, "%d\n", expires)
+	if err == nil {
+		_, err = f.Write(value)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing cache file: %w", err)
+	}
+	// rename so that readers never see a partial file
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("error writing cache file: %w", err)
+	}
+	return nil
+}
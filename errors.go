@@ -0,0 +1,114 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Errors matched (with errors.Is) by a *CommandError, classifying why an
+// HTCondor tool failed from its standard error output.
+var (
+	// ErrAuthentication means the tool could not authenticate with the
+	// daemon.
+	ErrAuthentication = errors.New("authentication failed")
+	// ErrPermissionDenied means the daemon refused the request, e.g. to
+	// remove another user's jobs.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrDaemonUnreachable means the daemon could not be contacted. The
+	// CommandError's Address is set if the tool reported it.
+	ErrDaemonUnreachable = errors.New("daemon unreachable")
+	// ErrNoSuchSchedd means the collector doesn't know the schedd named with
+	// -name.
+	ErrNoSuchSchedd = errors.New("no such schedd")
+	// ErrConstraintParse means the constraint is not a valid ClassAd
+	// expression.
+	ErrConstraintParse = errors.New("invalid constraint")
+	// ErrTimeout means the tool, or its connection to the daemon, timed out.
+	ErrTimeout = errors.New("timeout")
+)
+
+// stderrClassifiers match the messages HTCondor tools print for each class of
+// error.
+var stderrClassifiers = []struct {
+	kind   error
+	regexp *regexp.Regexp
+}{
+	{ErrAuthentication, regexp.MustCompile(`(?i)AUTHENTICATE:\d+|failed to authenticate|authentication (?:failed|error)|SECMAN:2010`)},
+	{ErrPermissionDenied, regexp.MustCompile(`(?i)permission denied|not authorized|unauthorized`)},
+	{ErrNoSuchSchedd, regexp.MustCompile(`(?i)can't find address (?:for|of) (?:local )?schedd`)},
+	{ErrConstraintParse, regexp.MustCompile(`(?i)parse error[^\n]*constraint|invalid constraint|couldn't parse constraint`)},
+	{ErrTimeout, regexp.MustCompile(`(?i)timed out|timeout|deadline expired`)},
+	{ErrDaemonUnreachable, regexp.MustCompile(`(?i)failed to connect|can't connect|couldn't contact|unable to connect|failed to fetch ads|communication error|CEDAR:6001`)},
+}
+
+// daemonAddressRegexp extracts the address of an unreachable daemon, e.g.
+// "Failed to connect to <192.168.1.1:9618>" or "Couldn't contact the
+// condor_collector on cm.example.com."
+var daemonAddressRegexp = regexp.MustCompile(`(?i)(?:connect to|contact the \S+ on|fetch ads from:?)\s+(<[^>]+>|[^\s,;]+)`)
+
+// CommandError is returned when an HTCondor tool fails. It wraps the
+// underlying error, usually an *ExitError, and matches the Err* variables
+// above (with errors.Is) according to the messages the tool printed, so
+// callers can decide whether to retry, e.g.
+//
+//	if errors.Is(err, htcondor.ErrDaemonUnreachable) || errors.Is(err, htcondor.ErrTimeout) {
+//	    // try again later
+//	}
+type CommandError struct {
+	// Command is the tool that was run, e.g. condor_q.
+	Command string
+	// Stderr is the standard error output of the tool.
+	Stderr string
+	// Address is the address of the daemon that could not be contacted, if
+	// the tool reported it.
+	Address string
+	// Err is the underlying error.
+	Err error
+
+	kinds []error
+}
+
+// newCommandError classifies the failure of a command from its standard
+// error output and the error from running it.
+func newCommandError(command string, stderr []byte, err error) *CommandError {
+	e := CommandError{
+		Command: command,
+		Stderr:  strings.TrimSpace(string(stderr)),
+		Err:     err,
+	}
+	for _, c := range stderrClassifiers {
+		if c.regexp.MatchString(e.Stderr) {
+			e.kinds = append(e.kinds, c.kind)
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) && !slices.Contains(e.kinds, ErrTimeout) {
+		e.kinds = append(e.kinds, ErrTimeout)
+	}
+	if slices.Contains(e.kinds, ErrDaemonUnreachable) {
+		if m := daemonAddressRegexp.FindStringSubmatch(e.Stderr); m != nil {
+			e.Address = strings.TrimRight(m[1], ".")
+		}
+	}
+	return &e
+}
+
+func (e *CommandError) Error() string {
+	var exitErr *ExitError
+	if errors.As(e.Err, &exitErr) {
+		return e.Err.Error()
+	}
+	return e.Command + ": " + e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is one of the classes of error the tool's
+// output indicated, e.g. ErrAuthentication.
+func (e *CommandError) Is(target error) bool {
+	return slices.Contains(e.kinds, target)
+}
@@ -0,0 +1,71 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCommandErrorClassification(t *testing.T) {
+	testCases := []struct {
+		stderr  string
+		kinds   []error
+		address string
+	}{
+		{"AUTHENTICATE:1003:Failed to authenticate with any method", []error{ErrAuthentication}, ""},
+		{"SECMAN:2010:Received \"DENIED\" from server for user alice using method FS.", []error{ErrAuthentication}, ""},
+		{"ERROR: Permission denied: alice is not the owner of job 42.0", []error{ErrPermissionDenied}, ""},
+		{"Error: Can't find address for schedd nosuchschedd", []error{ErrNoSuchSchedd}, ""},
+		{"Error: Can't find address of local schedd", []error{ErrNoSuchSchedd}, ""},
+		{"Error: Parse error of constraint expression: Owner ==", []error{ErrConstraintParse}, ""},
+		{"CEDAR:6001:Failed to connect to <192.168.1.10:9618?addrs=192.168.1.10-9618>", []error{ErrDaemonUnreachable}, "<192.168.1.10:9618?addrs=192.168.1.10-9618>"},
+		{"Error: Couldn't contact the condor_collector on cm.example.com.", []error{ErrDaemonUnreachable}, "cm.example.com"},
+		{"-- Failed to fetch ads from: <10.0.0.1:9618> : submit.example.com\nSECMAN:2007:Failed to end classad message.", []error{ErrDaemonUnreachable}, "<10.0.0.1:9618>"},
+		{"Failed to connect to <10.0.0.1:9618>: connection timed out", []error{ErrTimeout, ErrDaemonUnreachable}, "<10.0.0.1:9618>"},
+		{"something else went wrong", nil, ""},
+	}
+	all := []error{ErrAuthentication, ErrPermissionDenied, ErrDaemonUnreachable, ErrNoSuchSchedd, ErrConstraintParse, ErrTimeout}
+	for _, tc := range testCases {
+		err := newCommandError("condor_q", []byte(tc.stderr), &ExitError{Command: "condor_q", ExitCode: 1, Stderr: tc.stderr})
+		for _, kind := range all {
+			expected := false
+			for _, k := range tc.kinds {
+				expected = expected || k == kind
+			}
+			if errors.Is(err, kind) != expected {
+				t.Errorf("%q: expected errors.Is(%v) to be %t", tc.stderr, kind, expected)
+			}
+		}
+		if err.Address != tc.address {
+			t.Errorf("%q: expected address %q, got %q", tc.stderr, tc.address, err.Address)
+		}
+	}
+}
+
+func TestCommandErrorFromCommand(t *testing.T) {
+	fake := &fakeExecutor{result: Result{Stderr: []byte("Error: Can't find address for schedd nosuchschedd\n"), ExitCode: 1}}
+	_, err := NewSchedd("", "nosuchschedd").WithExecutor(fake).Query(context.Background(), JobSelection{})
+	if !errors.Is(err, ErrNoSuchSchedd) {
+		t.Errorf("expected ErrNoSuchSchedd, got %v", err)
+	}
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Stderr != "Error: Can't find address for schedd nosuchschedd" {
+		t.Errorf("expected *CommandError with stderr, got %v", err)
+	}
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 1 {
+		t.Errorf("expected wrapped *ExitError, got %v", err)
+	}
+
+	slow := ExecutorFunc(func(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	_, err = NewCommand("condor_status").WithExecutor(slow).RunWithContext(ctx)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected ErrTimeout wrapping context.DeadlineExceeded, got %v", err)
+	}
+}
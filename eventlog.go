@@ -0,0 +1,272 @@
+package htcondor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventType is the numeric type of a job event log (user log) event.
+type EventType int
+
+// Job event log event types.
+const (
+	SubmitEvent             EventType = 0
+	ExecuteEvent            EventType = 1
+	ExecutableErrorEvent    EventType = 2
+	CheckpointedEvent       EventType = 3
+	JobEvictedEvent         EventType = 4
+	JobTerminatedEvent      EventType = 5
+	ImageSizeEvent          EventType = 6
+	ShadowExceptionEvent    EventType = 7
+	GenericEvent            EventType = 8
+	JobAbortedEvent         EventType = 9
+	JobSuspendedEvent       EventType = 10
+	JobUnsuspendedEvent     EventType = 11
+	JobHeldEvent            EventType = 12
+	JobReleasedEvent        EventType = 13
+	NodeExecuteEvent        EventType = 14
+	NodeTerminatedEvent     EventType = 15
+	PostScriptTerminated    EventType = 16
+	RemoteErrorEvent        EventType = 21
+	JobDisconnectedEvent    EventType = 22
+	JobReconnectedEvent     EventType = 23
+	JobReconnectFailedEvent EventType = 24
+	GridResourceUpEvent     EventType = 25
+	GridResourceDownEvent   EventType = 26
+	GridSubmitEvent         EventType = 27
+	JobAdInformationEvent   EventType = 28
+	AttributeUpdateEvent    EventType = 33
+	ClusterSubmitEvent      EventType = 35
+	ClusterRemoveEvent      EventType = 36
+	FactoryPausedEvent      EventType = 37
+	FactoryResumedEvent     EventType = 38
+	FileTransferEvent       EventType = 40
+	ReserveSpaceEvent       EventType = 41
+	ReleaseSpaceEvent       EventType = 42
+	FileCompleteEvent       EventType = 43
+	FileUsedEvent           EventType = 44
+	FileRemovedEvent        EventType = 45
+	DataflowJobSkippedEvent EventType = 46
+)
+
+const (
+	eventTerminator          = "..."
+	eventLogISOTimeLayout    = "2006-01-02 15:04:05"
+	eventLogLegacyTimeLayout = "01/02 15:04:05"
+)
+
+var eventTypeNames = map[EventType]string{
+	SubmitEvent:             "Submit",
+	ExecuteEvent:            "Execute",
+	ExecutableErrorEvent:    "ExecutableError",
+	CheckpointedEvent:       "Checkpointed",
+	JobEvictedEvent:         "JobEvicted",
+	JobTerminatedEvent:      "JobTerminated",
+	ImageSizeEvent:          "ImageSize",
+	ShadowExceptionEvent:    "ShadowException",
+	GenericEvent:            "Generic",
+	JobAbortedEvent:         "JobAborted",
+	JobSuspendedEvent:       "JobSuspended",
+	JobUnsuspendedEvent:     "JobUnsuspended",
+	JobHeldEvent:            "JobHeld",
+	JobReleasedEvent:        "JobReleased",
+	NodeExecuteEvent:        "NodeExecute",
+	NodeTerminatedEvent:     "NodeTerminated",
+	PostScriptTerminated:    "PostScriptTerminated",
+	RemoteErrorEvent:        "RemoteError",
+	JobDisconnectedEvent:    "JobDisconnected",
+	JobReconnectedEvent:     "JobReconnected",
+	JobReconnectFailedEvent: "JobReconnectFailed",
+	GridResourceUpEvent:     "GridResourceUp",
+	GridResourceDownEvent:   "GridResourceDown",
+	GridSubmitEvent:         "GridSubmit",
+	JobAdInformationEvent:   "JobAdInformation",
+	AttributeUpdateEvent:    "AttributeUpdate",
+	ClusterSubmitEvent:      "ClusterSubmit",
+	ClusterRemoveEvent:      "ClusterRemove",
+	FactoryPausedEvent:      "FactoryPaused",
+	FactoryResumedEvent:     "FactoryResumed",
+	FileTransferEvent:       "FileTransfer",
+	ReserveSpaceEvent:       "ReserveSpace",
+	ReleaseSpaceEvent:       "ReleaseSpace",
+	FileCompleteEvent:       "FileComplete",
+	FileUsedEvent:           "FileUsed",
+	FileRemovedEvent:        "FileRemoved",
+	DataflowJobSkippedEvent: "DataflowJobSkipped",
+}
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Event%03d", int(t))
+}
+
+var (
+	eventHeaderRegexp = regexp.MustCompile(`^(\d{3}) \((-?\d+)\.(-?\d+)\.(-?\d+)\) (\S+ \S+) ?(.*)$`)
+	normalTermRegexp  = regexp.MustCompile(`Normal termination \(return value (-?\d+)\)`)
+	signalTermRegexp  = regexp.MustCompile(`Abnormal termination \(signal (\d+)\)`)
+	holdCodeRegexp    = regexp.MustCompile(`^Code (-?\d+) Subcode (-?\d+)$`)
+)
+
+// Event is a single event read from a job event log, i.e. the file named by
+// the "log" submit command.
+//
+// Only the classic text format is understood. Fields that do not apply to the
+// event type are left at their zero values.
+type Event struct {
+	// Type is the event type.
+	Type EventType
+	// JobID is the job the event refers to. Cluster-level events (e.g.
+	// ClusterSubmit) have a negative Proc.
+	JobID JobID
+	// Subproc is the subprocess number, usually zero.
+	Subproc int64
+	// Time is the time the event was logged, in the local time zone.
+	// Legacy logs that omit the year are assumed to be in the current year.
+	Time time.Time
+	// Text is the remainder of the first line of the event, e.g. "Job
+	// terminated."
+	Text string
+	// Body holds the remaining lines of the event, with leading whitespace
+	// trimmed.
+	Body []string
+
+	// ReturnValue is the exit code of a job that terminated normally.
+	ReturnValue int
+	// TerminatedBySignal is true if the job was terminated by a signal.
+	TerminatedBySignal bool
+	// Signal is the signal that terminated the job.
+	Signal int
+	// Reason is the hold reason for held events, or the removal reason for
+	// aborted events.
+	Reason string
+	// HoldReasonCode and HoldReasonSubCode are the hold codes for held
+	// events.
+	HoldReasonCode    HoldReasonCode
+	HoldReasonSubCode HoldReasonSubCode
+}
+
+// EventLogReader reads events from a job event log.
+//
+// Next returns io.EOF once no more complete events are available. If the
+// underlying reader is a file that is still being written, Next can be called
+// again after more data has been appended; partially written events are
+// buffered until they are complete.
+type EventLogReader struct {
+	r       *bufio.Reader
+	partial string
+	lines   []string
+}
+
+// NewEventLogReader creates an EventLogReader reading from r.
+func NewEventLogReader(r io.Reader) *EventLogReader {
+	return &EventLogReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Next returns the next complete event in the log.
+func (e *EventLogReader) Next() (*Event, error) {
+	for {
+		line, err := e.r.ReadString('\n')
+		if err != nil {
+			// keep any partial line around until the rest of it is written
+			e.partial += line
+			return nil, err
+		}
+		line = strings.TrimRight(e.partial+line, "\r\n")
+		e.partial = ""
+		if line == eventTerminator {
+			lines := e.lines
+			e.lines = nil
+			if len(lines) == 0 {
+				continue
+			}
+			return parseEvent(lines)
+		}
+		e.lines = append(e.lines, line)
+	}
+}
+
+// parseEvent parses the lines of a single event, excluding the terminator.
+func parseEvent(lines []string) (*Event, error) {
+	m := eventHeaderRegexp.FindStringSubmatch(lines[0])
+	if m == nil {
+		return nil, fmt.Errorf("invalid event header: \"%s\"", lines[0])
+	}
+	ev := Event{
+		Text: strings.TrimSpace(m[6]),
+		Body: make([]string, 0, len(lines)-1),
+	}
+	typ, _ := strconv.Atoi(m[1])
+	ev.Type = EventType(typ)
+	ev.JobID.Cluster, _ = strconv.ParseInt(m[2], 10, 64)
+	ev.JobID.Proc, _ = strconv.ParseInt(m[3], 10, 64)
+	ev.Subproc, _ = strconv.ParseInt(m[4], 10, 64)
+	t, err := parseEventTime(m[5])
+	if err != nil {
+		return nil, fmt.Errorf("invalid event time: %w", err)
+	}
+	ev.Time = t
+	for _, l := range lines[1:] {
+		ev.Body = append(ev.Body, strings.TrimSpace(l))
+	}
+
+	switch ev.Type {
+	case JobTerminatedEvent, NodeTerminatedEvent:
+		for _, l := range ev.Body {
+			if m := normalTermRegexp.FindStringSubmatch(l); m != nil {
+				ev.ReturnValue, _ = strconv.Atoi(m[1])
+				break
+			}
+			if m := signalTermRegexp.FindStringSubmatch(l); m != nil {
+				ev.TerminatedBySignal = true
+				ev.Signal, _ = strconv.Atoi(m[1])
+				break
+			}
+		}
+	case JobHeldEvent:
+		for _, l := range ev.Body {
+			if m := holdCodeRegexp.FindStringSubmatch(l); m != nil {
+				code, _ := strconv.Atoi(m[1])
+				subcode, _ := strconv.Atoi(m[2])
+				ev.HoldReasonCode = HoldReasonCode(code)
+				ev.HoldReasonSubCode = HoldReasonSubCode(subcode)
+			} else if ev.Reason == "" {
+				ev.Reason = l
+			}
+		}
+	case JobAbortedEvent:
+		if len(ev.Body) > 0 {
+			ev.Reason = ev.Body[0]
+		}
+	}
+	return &ev, nil
+}
+
+// parseEventTime parses the event timestamp in either the ISO 8601 format
+// used by current versions or the legacy "MM/DD HH:MM:SS" format.
+func parseEventTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(eventLogISOTimeLayout, s, time.Local); err == nil {
+		return t, nil
+	}
+	// ISO times may carry fractional seconds or a UTC offset
+	if t, err := time.Parse("2006-01-02 15:04:05.999999999Z07:00", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(eventLogLegacyTimeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(time.Now().Year(), 0, 0), nil
+}
@@ -0,0 +1,109 @@
+package htcondor
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var eventLog = `000 (042.000.000) 2024-01-02 10:00:00 Job submitted from host: <10.0.0.1:9618?addrs=10.0.0.1-9618>
+...
+000 (042.001.000) 2024-01-02 10:00:00 Job submitted from host: <10.0.0.1:9618?addrs=10.0.0.1-9618>
+...
+001 (042.000.000) 2024-01-02 10:00:05 Job executing on host: <10.0.0.2:9618>
+...
+005 (042.000.000) 2024-01-02 10:01:00 Job terminated.
+	(1) Normal termination (return value 3)
+		Usr 0 00:00:00, Sys 0 00:00:00  -  Run Remote Usage
+		Usr 0 00:00:00, Sys 0 00:00:00  -  Run Local Usage
+	0  -  Run Bytes Sent By Job
+...
+012 (042.001.000) 2024-01-02 10:01:30 Job was held.
+	Error from slot1@worker: Failed to execute 'hello.sh'
+	Code 6 Subcode 2
+...
+013 (042.001.000) 2024-01-02 10:02:00 Job was released.
+	via condor_release (by user tester)
+...
+005 (042.001.000) 01/02 10:03:00 Job terminated.
+	(0) Abnormal termination (signal 9)
+	(0) No core file
+...
+009 (043.000.000) 2024-01-02 10:04:00 Job was aborted.
+	via condor_rm (by user tester)
+...
+`
+
+func TestEventLogReader(t *testing.T) {
+	r := NewEventLogReader(strings.NewReader(eventLog))
+	events := make([]*Event, 0)
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 8 {
+		t.Fatalf("expected 8 events, read %d", len(events))
+	}
+	if ev := events[3]; ev.Type != JobTerminatedEvent || ev.ReturnValue != 3 || ev.TerminatedBySignal {
+		t.Errorf("bad terminated event: %+v", ev)
+	}
+	if ev := events[4]; ev.JobID != (JobID{42, 1}) || ev.HoldReasonCode != 6 || ev.HoldReasonSubCode != 2 ||
+		ev.Reason != "Error from slot1@worker: Failed to execute 'hello.sh'" {
+		t.Errorf("bad held event: %+v", ev)
+	}
+	if ev := events[6]; !ev.TerminatedBySignal || ev.Signal != 9 || ev.Time.Month() != 1 || ev.Time.Day() != 2 {
+		t.Errorf("bad signal terminated event: %+v", ev)
+	}
+	if ev := events[7]; ev.Type != JobAbortedEvent || ev.Reason != "via condor_rm (by user tester)" {
+		t.Errorf("bad aborted event: %+v", ev)
+	}
+}
+
+func TestEventLogReader_partial(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	r := NewEventLogReader(&eofReader{pr})
+	go func() {
+		pw.Write([]byte("000 (001.000.000) 2024-01-02 10:00:00 Job sub"))
+		pw.Write([]byte("mitted from host: <10.0.0.1:9618>\n..."))
+		pw.Write([]byte("\n"))
+		pw.Close()
+	}()
+	var ev *Event
+	for ev == nil {
+		var err error
+		ev, err = r.Next()
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	}
+	if ev.Type != SubmitEvent || ev.Text != "Job submitted from host: <10.0.0.1:9618>" {
+		t.Errorf("bad submit event: %+v", ev)
+	}
+}
+
+func TestEventLogReader_bad(t *testing.T) {
+	r := NewEventLogReader(strings.NewReader("foo\n...\n"))
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+// eofReader returns io.EOF after each chunk read from the underlying pipe,
+// like a file that is still being written.
+type eofReader struct {
+	r io.Reader
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,171 @@
+package htcondor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Executor runs HTCondor command-line tools. The default, ExecExecutor, runs
+// them as local processes; other implementations can substitute fakes for
+// testing, run the tools remotely (e.g. over SSH), or replay recorded output.
+type Executor interface {
+	// Run runs the named command with the arguments and standard input (which
+	// may be nil), and returns its output and exit status. A non-zero exit
+	// status is not an error; an error is returned only if the command could
+	// not be run or was interrupted.
+	Run(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error)
+}
+
+// StreamExecutor is an Executor that can also start a command and stream its
+// standard output, as used by Command.Stream. Executors that don't implement
+// it have their output buffered.
+type StreamExecutor interface {
+	Executor
+	// Start starts the named command, which must be waited on with
+	// Process.Wait.
+	Start(ctx context.Context, name string, args []string, stdin io.Reader) (Process, error)
+}
+
+// Process is a command started by a StreamExecutor.
+type Process interface {
+	// Stdout returns the command's standard output, which must be read before
+	// calling Wait.
+	Stdout() io.Reader
+	// Wait waits for the command to exit and returns its standard error and
+	// exit status. Result.Stdout is not set.
+	Wait() (*Result, error)
+}
+
+// Result is the output and exit status of a command.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// ExitError is returned when a command exits with a non-zero status.
+type ExitError struct {
+	// Command is the command that was run.
+	Command string
+	// ExitCode is the exit status.
+	ExitCode int
+	// Stderr is the standard error output of the command.
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s exited with status %d: %s", e.Command, e.ExitCode, e.Stderr)
+	}
+	return fmt.Sprintf("%s exited with status %d", e.Command, e.ExitCode)
+}
+
+// ExecutorFunc adapts a function to an Executor.
+type ExecutorFunc func(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error)
+
+// Run calls f.
+func (f ExecutorFunc) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error) {
+	return f(ctx, name, args, stdin)
+}
+
+// DefaultExecutor is the executor used by commands that don't set one with
+// WithExecutor.
+var DefaultExecutor Executor = ExecExecutor{}
+
+// ExecExecutor runs commands as local processes with os/exec.
+type ExecExecutor struct{}
+
+// Run runs the command with exec.CommandContext.
+func (ExecExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	res := Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	return execResult(ctx, &res, err)
+}
+
+// Start starts the command with exec.CommandContext.
+func (ExecExecutor) Start(ctx context.Context, name string, args []string, stdin io.Reader) (Process, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	p := execProcess{ctx: ctx, cmd: cmd}
+	cmd.Stderr = &p.stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error opening command pipe: %w", err)
+	}
+	p.stdout = out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+type execProcess struct {
+	ctx    context.Context
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr bytes.Buffer
+}
+
+func (p *execProcess) Stdout() io.Reader {
+	return p.stdout
+}
+
+func (p *execProcess) Wait() (*Result, error) {
+	err := p.cmd.Wait()
+	return execResult(p.ctx, &Result{Stderr: p.stderr.Bytes()}, err)
+}
+
+// execResult sets the exit code of a result from the error returned by
+// exec.Cmd, returning an error only if the command didn't run to completion.
+func execResult(ctx context.Context, res *Result, err error) (*Result, error) {
+	if err == nil {
+		return res, nil
+	}
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		res.ExitCode = exitErr.ExitCode()
+		return res, nil
+	}
+	return res, err
+}
+
+// executor returns the command's executor.
+func (c *Command) executor() Executor {
+	if c.opts.runner != nil {
+		return c.opts.runner
+	}
+	return DefaultExecutor
+}
+
+// commandError returns a *CommandError if the command could not be run, i.e.
+// err is not nil, or exited with a non-zero status (wrapping an *ExitError).
+// It returns nil if the command succeeded.
+func (c *Command) commandError(res *Result, err error) error {
+	if res == nil {
+		res = &Result{}
+	}
+	if err == nil {
+		if res.ExitCode == 0 {
+			return nil
+		}
+		err = &ExitError{
+			Command:  c.Command,
+			ExitCode: res.ExitCode,
+			Stderr:   strings.TrimSpace(string(res.Stderr)),
+		}
+	}
+	return newCommandError(c.Command, res.Stderr, err)
+}
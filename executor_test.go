@@ -0,0 +1,108 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeExecutor records the commands it is asked to run and returns a fixed
+// result.
+type fakeExecutor struct {
+	result Result
+	calls  [][]string
+}
+
+func (f *fakeExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error) {
+	f.calls = append(f.calls, append([]string{name}, args...))
+	res := f.result
+	return &res, nil
+}
+
+func TestCommandExecutor(t *testing.T) {
+	fake := &fakeExecutor{result: Result{Stdout: []byte("Name = \"slot1@host\"\nCpus = 4\n\nName = \"slot2@host\"\nCpus = 2\n")}}
+	cmd := NewCommand("condor_status").WithPool("pool").WithConstraint("Cpus > 1").WithExecutor(fake)
+	ads, err := cmd.Copy().RunWithContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 2 {
+		t.Fatalf("expected 2 ads, got %d", len(ads))
+	}
+	if ads[1]["Name"].String() != "slot2@host" {
+		t.Errorf("unexpected ad %v", ads[1])
+	}
+	expected := []string{"condor_status", "-pool", "pool", "-constraint", "Cpus > 1", "-long"}
+	if len(fake.calls) != 1 || !reflect.DeepEqual(fake.calls[0], expected) {
+		t.Errorf("expected call %q, got %q", expected, fake.calls)
+	}
+
+	ads, err = stream(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 2 {
+		t.Errorf("expected 2 streamed ads, got %d", len(ads))
+	}
+}
+
+func TestCommandExecutorExitError(t *testing.T) {
+	fake := &fakeExecutor{result: Result{Stderr: []byte("Failed to connect\n"), ExitCode: 1}}
+	_, err := NewCommand("condor_q").WithExecutor(fake).RunWithContext(context.Background())
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected *ExitError, got %v", err)
+	}
+	if exitErr.ExitCode != 1 || exitErr.Stderr != "Failed to connect" {
+		t.Errorf("unexpected error %+v", exitErr)
+	}
+}
+
+func TestScheddExecutor(t *testing.T) {
+	fake := &fakeExecutor{result: Result{Stdout: []byte("Job 42.0 marked for removal\n")}}
+	results, err := NewSchedd("pool", "schedd").WithExecutor(fake).Remove(context.Background(), Jobs(JobID{42, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].OK {
+		t.Errorf("unexpected results %v", results)
+	}
+	expected := []string{"condor_rm", "-pool", "pool", "-name", "schedd", "42.0"}
+	if len(fake.calls) != 1 || !reflect.DeepEqual(fake.calls[0], expected) {
+		t.Errorf("expected call %q, got %q", expected, fake.calls)
+	}
+}
+
+func TestExecExecutor(t *testing.T) {
+	ctx := context.Background()
+	res, err := ExecExecutor{}.Run(ctx, "sh", []string{"-c", "cat; echo oops >&2; exit 3"}, strings.NewReader("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Stdout) != "hello\n" || string(res.Stderr) != "oops\n" || res.ExitCode != 3 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	p, err := ExecExecutor{}.Start(ctx, "sh", []string{"-c", "echo streamed; exit 2"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(p.Stdout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = p.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "streamed\n" || res.ExitCode != 2 {
+		t.Errorf("unexpected output %q, result %+v", out, res)
+	}
+
+	if _, err := (ExecExecutor{}).Run(ctx, "/nonexistent/condor_q", nil, nil); err == nil {
+		t.Error("expected error running nonexistent command")
+	}
+}
@@ -0,0 +1,143 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// DefaultSourcePoolAttribute is the attribute Federation adds to each ad with
+// the pool it came from, if Federation.SourceAttribute is not set.
+const DefaultSourcePoolAttribute = "SourcePool"
+
+// Federation runs commands against several pools and merges the results, e.g.
+// to query the schedds or jobs of pools that flock to each other.
+type Federation struct {
+	// Pools are the collectors of the pools to query. An empty string is the
+	// local pool.
+	Pools []string
+	// SourceAttribute is the string attribute added to each ad with the pool
+	// it came from.
+	SourceAttribute string
+	// Key returns the identity of an ad, to de-duplicate ads returned by more
+	// than one pool. Ads with an empty key are never de-duplicated. If nil,
+	// DefaultFederationKey is used.
+	Key func(classad.ClassAd) string
+}
+
+// NewFederation creates a federation of the given pools.
+func NewFederation(pools ...string) *Federation {
+	return &Federation{Pools: pools}
+}
+
+// DefaultFederationKey identifies jobs by GlobalJobId, and other ads by MyType
+// and Name.
+func DefaultFederationKey(ad classad.ClassAd) string {
+	if id := ad.EvalAttribute("GlobalJobId"); id.Type == classad.String {
+		return "job" + keySeparator + id.String()
+	}
+	name := ad.EvalAttribute("Name")
+	if name.Type != classad.String {
+		return ""
+	}
+	myType := ad.EvalAttribute("MyType")
+	if myType.Type != classad.String {
+		return keySeparator + name.String()
+	}
+	return myType.String() + keySeparator + name.String()
+}
+
+// FederationResult is the merged result of a federated query.
+type FederationResult struct {
+	// Ads are the merged ads, in pool order, each annotated with its source
+	// pool. Ads seen in more than one pool are kept from the first.
+	Ads []classad.ClassAd
+	// Errors are the errors from pools that could not be queried.
+	Errors []*PoolError
+}
+
+// PoolError is an error querying one pool of a federation.
+type PoolError struct {
+	Pool string
+	Err  error
+}
+
+func (e *PoolError) Error() string {
+	pool := e.Pool
+	if pool == "" {
+		pool = "local pool"
+	}
+	return fmt.Sprintf("error querying %s: %s", pool, e.Err)
+}
+
+func (e *PoolError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs a copy of cmd against each pool concurrently, with WithPool, and
+// merges the results. Pools that fail are reported in the result's Errors;
+// an error is only returned if every pool failed.
+func (f *Federation) Run(ctx context.Context, cmd *Command) (*FederationResult, error) {
+	if len(f.Pools) == 0 {
+		return nil, fmt.Errorf("federation has no pools")
+	}
+	ctx, span := tracer.Start(ctx, "Federation")
+	defer span.End()
+
+	ads := make([][]classad.ClassAd, len(f.Pools))
+	errs := make([]error, len(f.Pools))
+	var wg sync.WaitGroup
+	for i, pool := range f.Pools {
+		wg.Add(1)
+		go func(i int, pool string) {
+			defer wg.Done()
+			ads[i], errs[i] = cmd.Copy().WithPool(pool).RunWithContext(ctx)
+		}(i, pool)
+	}
+	wg.Wait()
+	return f.merge(ads, errs)
+}
+
+// merge merges the per-pool results.
+func (f *Federation) merge(ads [][]classad.ClassAd, errs []error) (*FederationResult, error) {
+	attr := f.SourceAttribute
+	if attr == "" {
+		attr = DefaultSourcePoolAttribute
+	}
+	key := f.Key
+	if key == nil {
+		key = DefaultFederationKey
+	}
+	res := FederationResult{
+		Ads:    make([]classad.ClassAd, 0),
+		Errors: make([]*PoolError, 0),
+	}
+	seen := make(map[string]bool)
+	for i, pool := range f.Pools {
+		if errs[i] != nil {
+			res.Errors = append(res.Errors, &PoolError{Pool: pool, Err: errs[i]})
+			continue
+		}
+		for _, ad := range ads[i] {
+			if k := key(ad); k != "" {
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+			}
+			ad[attr] = classad.Attribute{Type: classad.String, Value: pool}
+			res.Ads = append(res.Ads, ad)
+		}
+	}
+	if len(res.Errors) == len(f.Pools) {
+		all := make([]error, len(res.Errors))
+		for i, e := range res.Errors {
+			all[i] = e
+		}
+		return &res, errors.Join(all...)
+	}
+	return &res, nil
+}
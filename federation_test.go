@@ -0,0 +1,48 @@
+package htcondor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestFederationMerge(t *testing.T) {
+	f := NewFederation("pool-a", "pool-b", "pool-c")
+	ads := [][]classad.ClassAd{
+		{
+			{"MyType": {Type: classad.String, Value: "Scheduler"}, "Name": {Type: classad.String, Value: "schedd1"}},
+			{"GlobalJobId": {Type: classad.String, Value: "schedd1#1.0#0"}},
+		},
+		{
+			{"MyType": {Type: classad.String, Value: "Scheduler"}, "Name": {Type: classad.String, Value: "schedd1"}},
+			{"MyType": {Type: classad.String, Value: "Scheduler"}, "Name": {Type: classad.String, Value: "schedd2"}},
+			{"GlobalJobId": {Type: classad.String, Value: "schedd1#1.0#0"}},
+			{"Foo": {Type: classad.Integer, Value: int64(1)}},
+		},
+		nil,
+	}
+	errs := []error{nil, nil, errors.New("connection refused")}
+	res, err := f.merge(ads, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Ads) != 4 {
+		t.Fatalf("expected 4 ads, got %d: %+v", len(res.Ads), res.Ads)
+	}
+	expected := []string{"pool-a", "pool-a", "pool-b", "pool-b"}
+	for i, ad := range res.Ads {
+		if ad[DefaultSourcePoolAttribute].Value != expected[i] {
+			t.Errorf("ad %d: expected source %s, got %s", i, expected[i], ad[DefaultSourcePoolAttribute])
+		}
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Pool != "pool-c" {
+		t.Errorf("expected error for pool-c, got %v", res.Errors)
+	}
+
+	// every pool failing is an error
+	f = &Federation{Pools: []string{"pool-a"}, SourceAttribute: "Origin"}
+	if _, err := f.merge([][]classad.ClassAd{nil}, []error{errors.New("timeout")}); err == nil {
+		t.Error("expected error when all pools fail")
+	}
+}
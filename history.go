@@ -0,0 +1,331 @@
+package htcondor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// HistoryOptions configures Schedd.History.
+type HistoryOptions struct {
+	// Constraint is a ClassAd expression that job records must match.
+	Constraint string
+	// Attributes are the attributes to return. If empty, all attributes are
+	// returned. CompletionDate and GlobalJobId are always added, since they
+	// are needed for the cursor.
+	Attributes []string
+	// Match is the maximum number of records to return (-match), if greater
+	// than zero.
+	Match int
+	// ScanLimit is the maximum number of records to scan (-scanlimit), if
+	// greater than zero.
+	ScanLimit int
+	// Since stops reading, when reading backwards, at the given job ID or
+	// when the given expression becomes true (-since).
+	Since string
+	// CompletedSince returns only jobs that completed at or after the given
+	// time (-completedsince).
+	CompletedSince time.Time
+	// Forwards reads from the oldest record to the newest. By default
+	// records are read backwards from the newest.
+	Forwards bool
+	// File reads the given history file rather than the schedd's (-file).
+	File string
+	// Cursor resumes after the position returned by HistoryStream.Cursor of
+	// an earlier query, returning only records not seen before.
+	Cursor HistoryCursor
+}
+
+// HistoryCursor marks a position in the history, so a later query can resume
+// from it. It is identified by the global job ID of the last record seen,
+// along with its completion date (for reading backwards) and its offset in
+// the history (for reading forwards).
+type HistoryCursor struct {
+	CompletionDate int64
+	GlobalJobId    string
+	// Offset is the number of records matching the query up to and
+	// including this one, counting forwards from the oldest. It is only set
+	// when reading forwards.
+	Offset int
+}
+
+// IsZero returns true if the cursor is unset.
+func (c HistoryCursor) IsZero() bool {
+	return c == HistoryCursor{}
+}
+
+// HistoryCommand returns the condor_history Command for the options, e.g. to
+// run it with Run or Stream.
+func (s *Schedd) HistoryCommand(opts HistoryOptions) *Command {
+	cmd := s.Command("condor_history")
+	cmd.WithConstraint(opts.Constraint)
+	if len(opts.Attributes) > 0 {
+		for _, a := range opts.Attributes {
+			cmd.WithAttribute(a)
+		}
+		for _, a := range []string{"CompletionDate", "GlobalJobId"} {
+			if !containsFold(opts.Attributes, a) {
+				cmd.WithAttribute(a)
+			}
+		}
+	}
+	if match := opts.match(); match > 0 {
+		cmd.WithArg("-match").WithArg(strconv.Itoa(match))
+	}
+	if opts.ScanLimit > 0 {
+		cmd.WithArg("-scanlimit").WithArg(strconv.Itoa(opts.ScanLimit))
+	}
+	if since := opts.since(); since != "" {
+		cmd.WithArg("-since").WithArg(since)
+	}
+	if !opts.CompletedSince.IsZero() {
+		cmd.WithArg("-completedsince").WithArg(strconv.FormatInt(opts.CompletedSince.Unix(), 10))
+	}
+	if opts.Forwards {
+		cmd.WithArg("-forwards")
+	} else {
+		cmd.WithArg("-backwards")
+	}
+	if opts.File != "" {
+		cmd.WithArg("-file").WithArg(opts.File)
+	}
+	return cmd
+}
+
+// match returns the -match argument. condor_history can't start reading
+// forwards from a given record, so when resuming forwards from a cursor the
+// records up to the cursor are read again (and skipped by the stream), and
+// are not counted against Match.
+func (o HistoryOptions) match() int {
+	if o.Match > 0 && o.Forwards {
+		return o.Match + o.Cursor.Offset
+	}
+	return o.Match
+}
+
+// since returns the -since argument. When reading backwards from a cursor,
+// reading stops at the cursor's job, or at an older record if that job is no
+// longer in the history.
+func (o HistoryOptions) since() string {
+	if o.Cursor.IsZero() || o.Forwards {
+		return o.Since
+	}
+	// jobs removed before completing have a CompletionDate of 0, and may be
+	// newer than the cursor
+	c := fmt.Sprintf("(CompletionDate > 0 && CompletionDate < %d)", o.Cursor.CompletionDate)
+	if o.Cursor.GlobalJobId != "" {
+		id := classad.Attribute{Type: classad.String, Value: o.Cursor.GlobalJobId}
+		c = "GlobalJobId == " + id.Unparse() + " || " + c
+	}
+	if id, err := ParseJobID(o.Since); err == nil {
+		c += " || " + id.Constraint()
+	} else if o.Since != "" {
+		c += " || (" + o.Since + ")"
+	}
+	return c
+}
+
+// containsFold returns true if list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// History runs condor_history against the schedd and streams the matching job
+// records. The stream must be closed when done.
+//
+// To page through the history incrementally, read forwards with a Match
+// limit, and pass the Cursor of each stream to the next query. Since each
+// page re-reads the history up to the cursor, for large histories it is more
+// efficient to read backwards from the newest record with a cursor, which
+// stops at the cursor.
+func (s *Schedd) History(ctx context.Context, opts HistoryOptions) *HistoryStream {
+	ctx, cancel := context.WithCancel(ctx)
+	h := newHistoryStream(cancel, opts)
+	go s.HistoryCommand(opts).StreamWithContext(ctx, h.ads, h.errs)
+	return h
+}
+
+// newHistoryStream returns a stream for the records of a query with the
+// given options.
+func newHistoryStream(cancel context.CancelFunc, opts HistoryOptions) *HistoryStream {
+	h := HistoryStream{
+		ads:      make(chan classad.ClassAd),
+		errs:     make(chan error),
+		cancel:   cancel,
+		forwards: opts.Forwards,
+		start:    opts.Cursor,
+		cursor:   opts.Cursor,
+		skipping: opts.Forwards && (opts.Cursor.GlobalJobId != "" || opts.Cursor.Offset > 0),
+	}
+	if opts.Forwards {
+		h.limit = opts.Match
+	}
+	return &h
+}
+
+// HistoryStream is a stream of job records from condor_history.
+type HistoryStream struct {
+	ads      chan classad.ClassAd
+	errs     chan error
+	cancel   context.CancelFunc
+	forwards bool
+	start    HistoryCursor
+	cursor   HistoryCursor
+	seen     bool
+	// offset is the number of records read, when reading forwards.
+	offset int
+	// skipping is true while skipping records, when reading forwards, up to
+	// the start cursor.
+	skipping bool
+	// skipped are the records skipped so far, which are returned if the
+	// start cursor's job is not found, i.e. it has been rotated out of the
+	// history.
+	skipped []historyRecord
+	// limit is the number of records to return, if greater than zero, and
+	// returned the number returned so far.
+	limit    int
+	returned int
+}
+
+// historyRecord is a job record and its offset in the history.
+type historyRecord struct {
+	ad     classad.ClassAd
+	offset int
+}
+
+// Next returns the next job record, or io.EOF when there are no more. Errors
+// parsing the output are returned as they occur, and Next may be called again
+// to continue.
+func (h *HistoryStream) Next() (JobAd, error) {
+	for h.ads != nil || h.errs != nil || len(h.skipped) > 0 {
+		if h.limit > 0 && h.returned >= h.limit {
+			break
+		}
+		if !h.skipping && len(h.skipped) > 0 {
+			r := h.skipped[0]
+			h.skipped = h.skipped[1:]
+			h.advance(r)
+			return JobAd(r.ad), nil
+		}
+		select {
+		case ad, ok := <-h.ads:
+			if !ok {
+				h.ads = nil
+				// the start cursor's job wasn't found, so every record is
+				// newer than it
+				h.skipping = false
+				continue
+			}
+			h.offset++
+			r := historyRecord{ad, h.offset}
+			if h.skip(r) {
+				continue
+			}
+			h.advance(r)
+			return JobAd(ad), nil
+		case err, ok := <-h.errs:
+			if !ok {
+				h.errs = nil
+				continue
+			}
+			return nil, err
+		}
+	}
+	return nil, io.EOF
+}
+
+// ReadAll returns all the remaining job records.
+func (h *HistoryStream) ReadAll() ([]JobAd, error) {
+	jobs := make([]JobAd, 0)
+	for {
+		j, err := h.Next()
+		if err == io.EOF {
+			return jobs, nil
+		} else if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, j)
+	}
+}
+
+// Cursor returns the position to resume from to get only records newer than
+// those returned so far: the last record returned when reading forwards, or
+// the first when reading backwards. It is the starting cursor if no records
+// have been returned.
+func (h *HistoryStream) Cursor() HistoryCursor {
+	return h.cursor
+}
+
+// Close stops the query.
+func (h *HistoryStream) Close() error {
+	h.cancel()
+	for h.ads != nil || h.errs != nil {
+		select {
+		case _, ok := <-h.ads:
+			if !ok {
+				h.ads = nil
+			}
+		case _, ok := <-h.errs:
+			if !ok {
+				h.errs = nil
+			}
+		}
+	}
+	return nil
+}
+
+// skip returns true if the record was returned by the query the start cursor
+// came from: when reading forwards, the records up to and including the
+// cursor's job, or the first Offset records if it has no job ID; when
+// reading backwards, the cursor's job.
+func (h *HistoryStream) skip(r historyRecord) bool {
+	c := historyCursor(r.ad, 0)
+	if !h.forwards {
+		return h.start.GlobalJobId != "" && c.GlobalJobId == h.start.GlobalJobId
+	}
+	if !h.skipping {
+		return false
+	}
+	if h.start.GlobalJobId == "" {
+		h.skipping = r.offset < h.start.Offset
+		return true
+	}
+	if c.GlobalJobId == h.start.GlobalJobId {
+		h.skipping = false
+		h.skipped = nil
+		return true
+	}
+	h.skipped = append(h.skipped, r)
+	return true
+}
+
+// advance updates the cursor with a record being returned.
+func (h *HistoryStream) advance(r historyRecord) {
+	h.returned++
+	if h.forwards {
+		h.cursor = historyCursor(r.ad, r.offset)
+	} else if !h.seen {
+		h.cursor = historyCursor(r.ad, 0)
+	}
+	h.seen = true
+}
+
+// historyCursor returns the cursor for a job record at the given offset.
+func historyCursor(ad classad.ClassAd, offset int) HistoryCursor {
+	c := HistoryCursor{Offset: offset}
+	c.CompletionDate, _ = ad.EvalAttribute("CompletionDate").Int64()
+	if id := ad.EvalAttribute("GlobalJobId"); id.Type == classad.String {
+		c.GlobalJobId = id.String()
+	}
+	return c
+}
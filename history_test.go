@@ -0,0 +1,215 @@
+package htcondor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestHistoryCommand(t *testing.T) {
+	s := NewSchedd("", "myschedd")
+	tests := []struct {
+		opts     HistoryOptions
+		expected []string
+	}{
+		{
+			HistoryOptions{},
+			[]string{"-name", "myschedd", "-backwards", "-long"},
+		},
+		{
+			HistoryOptions{
+				Constraint:     `Owner == "alice"`,
+				Attributes:     []string{"Owner", "globaljobid"},
+				Match:          10,
+				ScanLimit:      1000,
+				Since:          "42.0",
+				CompletedSince: time.Unix(1700000000, 0),
+				File:           "/var/lib/condor/spool/history",
+			},
+			[]string{"-name", "myschedd", "-constraint", `Owner == "alice"`,
+				"-match", "10", "-scanlimit", "1000", "-since", "42.0",
+				"-completedsince", "1700000000", "-backwards", "-file", "/var/lib/condor/spool/history",
+				"-af:lrng", "Owner", "globaljobid", "CompletionDate"},
+		},
+		{
+			HistoryOptions{
+				Cursor: HistoryCursor{CompletionDate: 1700000000, GlobalJobId: "host#42.0#1699999000"},
+			},
+			[]string{"-name", "myschedd",
+				"-since", `GlobalJobId == "host#42.0#1699999000" || (CompletionDate > 0 && CompletionDate < 1700000000)`,
+				"-backwards", "-long"},
+		},
+		{
+			HistoryOptions{
+				Constraint: "JobUniverse == 5",
+				Forwards:   true,
+				Match:      100,
+				Cursor:     HistoryCursor{CompletionDate: 1700000000, GlobalJobId: "host#42.0#1699999000", Offset: 250},
+			},
+			[]string{"-name", "myschedd", "-constraint", "JobUniverse == 5",
+				"-match", "350", "-forwards", "-long"},
+		},
+	}
+	for _, tt := range tests {
+		args := s.HistoryCommand(tt.opts).MakeArgs()
+		if !reflect.DeepEqual(args, tt.expected) {
+			t.Errorf("expected %q, got %q", tt.expected, args)
+		}
+	}
+}
+
+func TestHistoryOptionsSince(t *testing.T) {
+	opts := HistoryOptions{
+		Since:  "42",
+		Cursor: HistoryCursor{CompletionDate: 1700000000},
+	}
+	expected := "(CompletionDate > 0 && CompletionDate < 1700000000) || ClusterId == 42"
+	if s := opts.since(); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+// historyStream returns a stream that returns the given ads.
+func historyStream(opts HistoryOptions, ads ...classad.ClassAd) *HistoryStream {
+	h := newHistoryStream(func() {}, opts)
+	go func() {
+		for _, ad := range ads {
+			h.ads <- ad
+		}
+		close(h.errs)
+		close(h.ads)
+	}()
+	return h
+}
+
+func historyAd(proc int, completion int64) classad.ClassAd {
+	return classad.ClassAd{
+		"ProcId":         {Type: classad.Integer, Value: int64(proc)},
+		"CompletionDate": {Type: classad.Integer, Value: completion},
+		"GlobalJobId":    {Type: classad.String, Value: fmt.Sprintf("host#1.%d#0", proc)},
+	}
+}
+
+// procIds returns the ProcId of each job.
+func procIds(jobs []JobAd) []int64 {
+	ids := make([]int64, len(jobs))
+	for i, j := range jobs {
+		ids[i], _ = classad.ClassAd(j).EvalAttribute("ProcId").Int64()
+	}
+	return ids
+}
+
+func TestHistoryStream(t *testing.T) {
+	// forwards, resuming from job 1.1 with another job completed at the
+	// same time before it, and one after
+	start := HistoryCursor{CompletionDate: 100, GlobalJobId: "host#1.1#0", Offset: 2}
+	h := historyStream(HistoryOptions{Forwards: true, Cursor: start}, historyAd(0, 100), historyAd(1, 100), historyAd(2, 100), historyAd(3, 101))
+	jobs, err := h.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := procIds(jobs); !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Fatalf("expected jobs 2 and 3, got %v", ids)
+	}
+	expected := HistoryCursor{CompletionDate: 101, GlobalJobId: "host#1.3#0", Offset: 4}
+	if c := h.Cursor(); c != expected {
+		t.Errorf("expected cursor %+v, got %+v", expected, c)
+	}
+	if _, err := h.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	// forwards, the cursor's job has been rotated out of the history, so
+	// every record is new
+	h = historyStream(HistoryOptions{Forwards: true, Cursor: start}, historyAd(2, 100), historyAd(3, 101))
+	jobs, err = h.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := procIds(jobs); !reflect.DeepEqual(ids, []int64{2, 3}) {
+		t.Errorf("expected jobs 2 and 3, got %v", ids)
+	}
+
+	// backwards, the cursor is the newest record
+	h = historyStream(HistoryOptions{}, historyAd(3, 101), historyAd(2, 100))
+	if _, err := h.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if c := h.Cursor(); c.GlobalJobId != "host#1.3#0" {
+		t.Errorf("expected cursor at job 1.3, got %+v", c)
+	}
+
+	// no records, cursor is unchanged
+	h = historyStream(HistoryOptions{Cursor: start})
+	if _, err := h.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if c := h.Cursor(); c != start {
+		t.Errorf("expected cursor %+v, got %+v", start, c)
+	}
+	h.Close()
+}
+
+// TestHistoryPaging pages forwards through a history, as condor_history
+// would return it for each query, in which jobs don't leave the queue in
+// order of CompletionDate, some were removed (CompletionDate 0), and more
+// jobs share a CompletionDate than fit on a page.
+func TestHistoryPaging(t *testing.T) {
+	history := []classad.ClassAd{
+		historyAd(0, 100),
+		historyAd(1, 100),
+		historyAd(2, 100),
+		historyAd(3, 0),
+		historyAd(4, 100),
+		historyAd(5, 90),
+		historyAd(6, 0),
+		historyAd(7, 110),
+	}
+	// query returns the records condor_history would return for opts
+	query := func(opts HistoryOptions) *HistoryStream {
+		records := history
+		if match := opts.match(); match > 0 && match < len(records) {
+			records = records[:match]
+		}
+		return historyStream(opts, records...)
+	}
+
+	opts := HistoryOptions{Forwards: true, Match: 2}
+	seen := make([]int64, 0)
+	for page := 0; page < 10; page++ {
+		h := query(opts)
+		jobs, err := h.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) > opts.Match {
+			t.Fatalf("page %d: expected at most %d jobs, got %d", page, opts.Match, len(jobs))
+		}
+		if len(jobs) == 0 {
+			break
+		}
+		seen = append(seen, procIds(jobs)...)
+		opts.Cursor = h.Cursor()
+	}
+	if expected := []int64{0, 1, 2, 3, 4, 5, 6, 7}; !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected jobs %v, got %v", expected, seen)
+	}
+}
+
+func TestCondorScheddHistory(t *testing.T) {
+	h := NewSchedd("", "").History(context.Background(), HistoryOptions{Match: 10})
+	defer h.Close()
+	jobs, err := h.ReadAll()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, j := range jobs {
+		t.Log(j.JobID())
+	}
+	t.Log(h.Cursor())
+}
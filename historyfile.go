@@ -0,0 +1,314 @@
+package htcondor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+const (
+	// historyBanner starts the line that follows each job ad in a history
+	// file.
+	historyBanner = "***"
+	// historyChunkSize is the size of the chunks read when reading a history
+	// file backwards.
+	historyChunkSize = 64 * 1024
+)
+
+// rotatedHistoryRegexp matches the suffix of rotated history files, e.g.
+// history.20240102T030405.
+var rotatedHistoryRegexp = regexp.MustCompile(`^\.\d{8}T\d{6}$`)
+
+// HistoryFileOptions configures a HistoryFileReader.
+type HistoryFileOptions struct {
+	// Constraint is a ClassAd expression that job ads must match.
+	Constraint string
+	// Match is the maximum number of job ads to return, if greater than zero.
+	Match int
+	// Forwards reads from the oldest record to the newest. By default, like
+	// condor_history, records are read backwards from the newest.
+	Forwards bool
+	// NoRotated reads only the named file, ignoring any rotated files.
+	NoRotated bool
+}
+
+// HistoryFileReader reads job ads directly from schedd history files (the
+// HISTORY file and its rotations), without running condor_history.
+//
+// History files are sequences of "long" format ClassAds, each followed by a
+// banner line beginning with "***". Attribute values are parsed with
+// classad.ParseAttribute, so expressions are preserved as such.
+type HistoryFileReader struct {
+	files      []string
+	opts       HistoryFileOptions
+	constraint *classad.Expr
+	matched    int
+	file       *os.File
+	records    historyRecordReader
+}
+
+// historyRecordReader returns the lines of the next record in a history file.
+type historyRecordReader interface {
+	next() ([]string, error)
+}
+
+// OpenHistoryFile opens the history file at path. Unless opts.NoRotated is
+// set, rotated files (path.YYYYMMDDTHHMMSS) are also read, in order.
+func OpenHistoryFile(path string, opts HistoryFileOptions) (*HistoryFileReader, error) {
+	h := HistoryFileReader{
+		files: []string{path},
+		opts:  opts,
+	}
+	if opts.Constraint != "" {
+		x, err := classad.ParseExpr(opts.Constraint)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing constraint: %w", err)
+		}
+		h.constraint = x
+	}
+	if !opts.NoRotated {
+		rotated, err := rotatedHistoryFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		// newest first
+		h.files = append(h.files, rotated...)
+	}
+	if opts.Forwards {
+		for i, j := 0, len(h.files)-1; i < j; i, j = i+1, j-1 {
+			h.files[i], h.files[j] = h.files[j], h.files[i]
+		}
+	}
+	return &h, nil
+}
+
+// rotatedHistoryFiles returns the rotations of the history file at path,
+// newest first.
+func rotatedHistoryFiles(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("error listing rotated history files: %w", err)
+	}
+	rotated := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if rotatedHistoryRegexp.MatchString(strings.TrimPrefix(m, path)) {
+			rotated = append(rotated, m)
+		}
+	}
+	// the timestamp suffixes sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+	return rotated, nil
+}
+
+// Next returns the next job ad matching the constraint, or io.EOF when there
+// are no more ads or the match limit has been reached.
+func (h *HistoryFileReader) Next() (classad.ClassAd, error) {
+	for {
+		if h.opts.Match > 0 && h.matched >= h.opts.Match {
+			return nil, io.EOF
+		}
+		if h.records == nil {
+			if len(h.files) == 0 {
+				return nil, io.EOF
+			}
+			if err := h.open(h.files[0]); err != nil {
+				return nil, err
+			}
+			h.files = h.files[1:]
+		}
+		lines, err := h.records.next()
+		if err == io.EOF {
+			h.closeFile()
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ad, err := parseHistoryRecord(lines)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", h.file.Name(), err)
+		}
+		if h.constraint != nil && !h.constraint.Matches(ad) {
+			continue
+		}
+		h.matched++
+		return ad, nil
+	}
+}
+
+// ReadAll reads all remaining matching job ads.
+func (h *HistoryFileReader) ReadAll() ([]classad.ClassAd, error) {
+	ads := make([]classad.ClassAd, 0)
+	for {
+		ad, err := h.Next()
+		if err == io.EOF {
+			return ads, nil
+		} else if err != nil {
+			return ads, err
+		}
+		ads = append(ads, ad)
+	}
+}
+
+// Close closes the file currently being read.
+func (h *HistoryFileReader) Close() error {
+	h.files = nil
+	return h.closeFile()
+}
+
+func (h *HistoryFileReader) open(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening history file: %w", err)
+	}
+	h.file = f
+	if h.opts.Forwards {
+		scanner := bufio.NewScanner(f)
+		buf := make([]byte, classad.ScanBufferSize)
+		scanner.Buffer(buf, classad.ScanBufferSize)
+		h.records = &forwardHistoryReader{scanner: scanner}
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening history file: %w", err)
+	}
+	h.records = &backwardHistoryReader{r: f, pos: fi.Size()}
+	return nil
+}
+
+func (h *HistoryFileReader) closeFile() error {
+	h.records = nil
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+// parseHistoryRecord parses the attribute lines of a history record.
+func parseHistoryRecord(lines []string) (classad.ClassAd, error) {
+	ad := make(classad.ClassAd, len(lines))
+	for _, l := range lines {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid classad attribute: \"%s\"", l)
+		}
+		ad[strings.TrimSpace(parts[0])] = classad.ParseAttribute(parts[1])
+	}
+	return ad, nil
+}
+
+// forwardHistoryReader reads history records from the start of a file.
+type forwardHistoryReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *forwardHistoryReader) next() ([]string, error) {
+	lines := make([]string, 0)
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if strings.HasPrefix(line, historyBanner) {
+			if len(lines) > 0 {
+				return lines, nil
+			}
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	// any lines after the last banner are a record still being written
+	return nil, io.EOF
+}
+
+// backwardHistoryReader reads history records from the end of a file, newest
+// first, like condor_history.
+type backwardHistoryReader struct {
+	r io.ReaderAt
+	// pos is the offset of the start of buf in the file
+	pos int64
+	// buf holds the part of the file before the lines already returned
+	buf []byte
+	// started is set once the last banner in the file has been seen
+	started bool
+}
+
+// prevLine returns the line before those already read.
+func (r *backwardHistoryReader) prevLine() (string, error) {
+	for {
+		if i := bytes.LastIndexByte(r.buf, '\n'); i >= 0 {
+			line := string(r.buf[i+1:])
+			r.buf = r.buf[:i]
+			return line, nil
+		}
+		if r.pos == 0 {
+			if r.buf == nil {
+				return "", io.EOF
+			}
+			line := string(r.buf)
+			r.buf = nil
+			return line, nil
+		}
+		n := int64(historyChunkSize)
+		if n > r.pos {
+			n = r.pos
+		}
+		chunk := make([]byte, n, n+int64(len(r.buf)))
+		if m, err := r.r.ReadAt(chunk, r.pos-n); err != nil && !(err == io.EOF && int64(m) == n) {
+			return "", err
+		}
+		r.pos -= n
+		r.buf = append(chunk, r.buf...)
+	}
+}
+
+func (r *backwardHistoryReader) next() ([]string, error) {
+	lines := make([]string, 0)
+	for {
+		line, err := r.prevLine()
+		if err == io.EOF {
+			if r.started && len(lines) > 0 {
+				return reverseLines(lines), nil
+			}
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, historyBanner) {
+			if !r.started {
+				// discard any record still being written after the last banner
+				r.started = true
+				lines = lines[:0]
+				continue
+			}
+			if len(lines) > 0 {
+				// this banner ends the previous record
+				return reverseLines(lines), nil
+			}
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, "\r"))
+		}
+	}
+}
+
+func reverseLines(lines []string) []string {
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
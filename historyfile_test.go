@@ -0,0 +1,112 @@
+package htcondor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHistory writes a history file with a record for each of the given
+// cluster IDs.
+func writeHistory(t *testing.T, path string, clusters ...int) {
+	var b strings.Builder
+	for _, c := range clusters {
+		fmt.Fprintf(&b, "ClusterId = %d\nProcId = 0\nOwner = \"user%d\"\nExitCode = %d\nLeaveJobInQueue = false\n", c, c%2, c%3)
+		fmt.Fprintf(&b, "*** Offset = %d ClusterId = %d ProcId = 0 Owner = \"user%d\" CompletionDate = %d\n", b.Len(), c, c%2, 1700000000+c)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func historyClusters(t *testing.T, path string, opts HistoryFileOptions) []int64 {
+	h, err := OpenHistoryFile(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ads, err := h.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters := make([]int64, len(ads))
+	for i, ad := range ads {
+		clusters[i] = ad["ClusterId"].Value.(int64)
+	}
+	return clusters
+}
+
+func TestHistoryFileReader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history")
+	writeHistory(t, path+".20240101T000000", 1, 2)
+	writeHistory(t, path+".20240201T000000", 3, 4, 5)
+	writeHistory(t, path, 6, 7)
+	// not a rotation
+	writeHistory(t, path+".bak", 99)
+
+	testCases := []struct {
+		description string
+		opts        HistoryFileOptions
+		expected    []int64
+	}{
+		{"backwards", HistoryFileOptions{}, []int64{7, 6, 5, 4, 3, 2, 1}},
+		{"forwards", HistoryFileOptions{Forwards: true}, []int64{1, 2, 3, 4, 5, 6, 7}},
+		{"no rotated", HistoryFileOptions{NoRotated: true}, []int64{7, 6}},
+		{"match", HistoryFileOptions{Match: 3}, []int64{7, 6, 5}},
+		{"constraint", HistoryFileOptions{Constraint: `Owner == "user1" && !LeaveJobInQueue`}, []int64{7, 5, 3, 1}},
+		{"constraint and match", HistoryFileOptions{Constraint: `ExitCode == 0`, Match: 1, Forwards: true}, []int64{3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			clusters := historyClusters(t, path, tc.opts)
+			if fmt.Sprint(clusters) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected clusters %v, got %v", tc.expected, clusters)
+			}
+		})
+	}
+}
+
+func TestHistoryFileReader_partial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	writeHistory(t, path, 1, 2)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// record still being written
+	f.WriteString("ClusterId = 3\nProcId = 0\n")
+	f.Close()
+	for _, forwards := range []bool{false, true} {
+		clusters := historyClusters(t, path, HistoryFileOptions{Forwards: forwards})
+		if len(clusters) != 2 {
+			t.Errorf("expected 2 records (forwards=%t), got %v", forwards, clusters)
+		}
+	}
+}
+
+func TestHistoryFileReader_largeBackwards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	clusters := make([]int, 5000)
+	for i := range clusters {
+		clusters[i] = i
+	}
+	writeHistory(t, path, clusters...)
+	read := historyClusters(t, path, HistoryFileOptions{})
+	if len(read) != len(clusters) {
+		t.Fatalf("expected %d records, got %d", len(clusters), len(read))
+	}
+	for i, c := range read {
+		if c != int64(len(clusters)-1-i) {
+			t.Fatalf("record %d: expected cluster %d, got %d", i, len(clusters)-1-i, c)
+		}
+	}
+}
+
+func TestHistoryFileReader_badConstraint(t *testing.T) {
+	if _, err := OpenHistoryFile("history", HistoryFileOptions{Constraint: "Owner =="}); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,522 @@
+package htcondortest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retzkek/htcondor-go"
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// Pool is an in-memory fake HTCondor pool, with schedds holding job ads and a
+// collector holding machine and other daemon ads. It is an htcondor.Executor
+// that understands the arguments the htcondor package passes to condor_q,
+// condor_status, condor_submit, condor_rm, condor_hold, condor_release,
+// condor_vacate_job, condor_suspend, condor_continue, condor_qedit and
+// condor_advertise, and
+// updates its state as HTCondor would, so that workflows can be tested
+// without an HTCondor installation:
+//
+//	pool := htcondortest.NewPool()
+//	pool.AddSchedd("schedd@example.com")
+//	pool.AddMachine(classad.ClassAd{"Name": ..., "Cpus": ...})
+//	schedd := htcondor.NewSchedd("", "").WithExecutor(pool)
+//	res, err := schedd.Submit(ctx, desc, htcondor.SubmitOptions{})
+//
+// Jobs don't run by themselves; use Schedd.SetJobStatus and Schedd.Update to
+// simulate the negotiator and startds. Unlike condor_q, queries return every
+// user's jobs by default. Other commands return an error, as if they were not
+// installed.
+type Pool struct {
+	// Address is the collector address. If set, commands with a different
+	// -pool argument fail as if the collector could not be contacted.
+	Address string
+	// User is the Owner of submitted jobs. Defaults to "user".
+	User string
+	// Now returns the current time, used for timestamps. Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	schedds []*Schedd
+	ads     []classad.ClassAd
+}
+
+// NewPool creates an empty fake pool. Add at least one schedd before
+// submitting jobs.
+func NewPool() *Pool {
+	return &Pool{
+		User: "user",
+		Now:  time.Now,
+	}
+}
+
+// AddSchedd adds a schedd with the given name to the pool. The first schedd
+// added is the default, used by commands without a -name argument.
+func (p *Pool) AddSchedd(name string) *Schedd {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := Schedd{pool: p, name: name, nextCluster: 1}
+	p.schedds = append(p.schedds, &s)
+	return &s
+}
+
+// Schedd returns the named schedd (case-insensitive), or the default schedd if
+// name is empty. It returns nil if there is no such schedd.
+func (p *Pool) Schedd(name string) *Schedd {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.schedd(name)
+}
+
+func (p *Pool) schedd(name string) *Schedd {
+	for _, s := range p.schedds {
+		if name == "" || strings.EqualFold(s.name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddMachine adds a startd (slot) ad to the collector. MyType is set to
+// "Machine" if it is missing.
+func (p *Pool) AddMachine(ad classad.ClassAd) {
+	ad = copyAd(ad)
+	if _, ok := ad.Lookup("MyType"); !ok {
+		ad["MyType"] = classad.Attribute{Type: classad.String, Value: "Machine"}
+	}
+	p.AddAd(ad)
+}
+
+// AddAd adds an ad to the collector, e.g. a negotiator, submitter or generic
+// ad. Its MyType attribute determines which condor_status queries return it.
+// Schedd ads are generated from the pool's schedds and need not be added.
+func (p *Pool) AddAd(ad classad.ClassAd) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ads = append(p.ads, copyAd(ad))
+}
+
+// Run runs a command against the fake pool.
+func (p *Pool) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*htcondor.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	command := filepath.Base(name)
+	handler, ok := commands[command]
+	if !ok {
+		return nil, fmt.Errorf("htcondortest: %s is not supported by the fake pool", command)
+	}
+	inv, err := parseArgs(args)
+	if err != nil {
+		return failure(command + ": " + err.Error()), nil
+	}
+	if p.Address != "" && inv.pool != "" && !strings.EqualFold(inv.pool, p.Address) {
+		return failure("Error: Couldn't contact the condor_collector on " + inv.pool + "."), nil
+	}
+	var in []byte
+	if stdin != nil {
+		if in, err = io.ReadAll(stdin); err != nil {
+			return nil, fmt.Errorf("error reading stdin: %w", err)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return handler(p, command, inv, in), nil
+}
+
+// commands are the handlers for the supported commands, which are called with
+// the pool locked.
+var commands = map[string]func(p *Pool, command string, inv *invocation, stdin []byte) *htcondor.Result{
+	"condor_q":          (*Pool).query,
+	"condor_status":     (*Pool).status,
+	"condor_submit":     (*Pool).submit,
+	"condor_rm":         (*Pool).act,
+	"condor_hold":       (*Pool).act,
+	"condor_release":    (*Pool).act,
+	"condor_vacate_job": (*Pool).act,
+	"condor_suspend":    (*Pool).act,
+	"condor_continue":   (*Pool).act,
+	"condor_qedit":      (*Pool).edit,
+	"condor_advertise":  (*Pool).advertise,
+}
+
+// targetSchedd returns the schedd selected by -name, or a failed result.
+func (p *Pool) targetSchedd(inv *invocation) (*Schedd, *htcondor.Result) {
+	s := p.schedd(inv.name)
+	if s == nil {
+		if inv.name == "" {
+			return nil, failure("Error: Can't find address of local schedd")
+		}
+		return nil, failure("Error: Can't find address for schedd " + inv.name)
+	}
+	return s, nil
+}
+
+// query handles condor_q.
+func (p *Pool) query(command string, inv *invocation, stdin []byte) *htcondor.Result {
+	s, res := p.targetSchedd(inv)
+	if res != nil {
+		return res
+	}
+	match, err := inv.selection()
+	if err != nil {
+		return failure("Error: " + err.Error())
+	}
+	ads := make([]classad.ClassAd, 0)
+	for _, ad := range s.jobs {
+		if match(ad) {
+			ads = append(ads, ad)
+		}
+	}
+	return inv.output(ads)
+}
+
+// adTypes maps condor_status ad type options to the MyType of the ads they
+// select.
+var adTypes = map[string]string{
+	"-startd":     "Machine",
+	"-schedd":     "Scheduler",
+	"-negotiator": "Negotiator",
+	"-master":     "DaemonMaster",
+	"-submitters": "Submitter",
+	"-accounting": "Accounting",
+	"-grid":       "Grid",
+	"-collector":  "Collector",
+}
+
+// status handles condor_status.
+func (p *Pool) status(command string, inv *invocation, stdin []byte) *htcondor.Result {
+	myType, generic, anyType := "Machine", false, false
+	for _, f := range inv.flags {
+		if t, ok := adTypes[f]; ok {
+			myType = t
+		}
+		switch f {
+		case "-generic":
+			generic = true
+		case "-any":
+			anyType = true
+		}
+	}
+	if inv.subsystem != "" {
+		myType = inv.subsystem
+	}
+	var match func(classad.ClassAd) bool
+	if inv.constraint != "" {
+		x, err := classad.ParseExpr(inv.constraint)
+		if err != nil {
+			return failure("Error: invalid constraint: " + err.Error())
+		}
+		match = x.Matches
+	}
+
+	all := make([]classad.ClassAd, 0, len(p.schedds)+len(p.ads))
+	for _, s := range p.schedds {
+		all = append(all, s.ad())
+	}
+	all = append(all, p.ads...)
+	ads := make([]classad.ClassAd, 0)
+	for _, ad := range all {
+		t := ad.EvalAttribute("MyType").String()
+		known := knownTypes[t]
+		switch {
+		case anyType:
+		case generic && inv.subsystem == "":
+			if known {
+				continue
+			}
+		case !strings.EqualFold(t, myType):
+			continue
+		}
+		if inv.name != "" && !strings.EqualFold(ad.EvalAttribute("Name").String(), inv.name) {
+			continue
+		}
+		if match != nil && !match(ad) {
+			continue
+		}
+		ads = append(ads, ad)
+	}
+	return inv.output(ads)
+}
+
+// advertise handles condor_advertise. Updates replace any ad with the same
+// MyType and Name; invalidations remove the ads of the query's TargetType that
+// match its Requirements.
+func (p *Pool) advertise(command string, inv *invocation, stdin []byte) *htcondor.Result {
+	if len(inv.positional) != 2 {
+		return failure("ERROR: expected an update command and a file")
+	}
+	update := htcondor.UpdateCommand(inv.positional[0])
+	in := io.Reader(bytes.NewReader(stdin))
+	if inv.positional[1] != "-" {
+		f, err := os.Open(inv.positional[1])
+		if err != nil {
+			return failure("ERROR: " + err.Error())
+		}
+		defer f.Close()
+		in = f
+	}
+	ads, err := readAds(in)
+	if err != nil {
+		return failure("ERROR: " + err.Error())
+	}
+	for _, ad := range ads {
+		if !update.IsInvalidate() {
+			p.ads = slices.DeleteFunc(p.ads, func(a classad.ClassAd) bool {
+				return sameAd(a, ad, "MyType") && sameAd(a, ad, "Name")
+			})
+			p.ads = append(p.ads, copyAd(ad))
+			continue
+		}
+		req, ok := ad.Lookup("Requirements")
+		if !ok {
+			return failure("ERROR: invalidation ad has no Requirements")
+		}
+		x, err := classad.ParseExpr(req.Unparse())
+		if err != nil {
+			return failure("ERROR: invalid Requirements: " + err.Error())
+		}
+		targetType := ad.EvalAttribute("TargetType").String()
+		p.ads = slices.DeleteFunc(p.ads, func(a classad.ClassAd) bool {
+			return (targetType == "" || strings.EqualFold(a.EvalAttribute("MyType").String(), targetType)) && x.Matches(a)
+		})
+	}
+	return &htcondor.Result{}
+}
+
+// readAds reads ads in "long" format, parsing the values with
+// classad.ParseAttribute so that expressions such as an invalidation's
+// Requirements are kept as expressions.
+func readAds(r io.Reader) ([]classad.ClassAd, error) {
+	ads := make([]classad.ClassAd, 0)
+	ad := make(classad.ClassAd)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if len(ad) > 0 {
+				ads = append(ads, ad)
+				ad = make(classad.ClassAd)
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid classad attribute: %q", line)
+		}
+		ad[strings.TrimSpace(name)] = classad.ParseAttribute(value)
+	}
+	if len(ad) > 0 {
+		ads = append(ads, ad)
+	}
+	return ads, scanner.Err()
+}
+
+// sameAd returns true if the named attribute of the ads is the same, ignoring
+// case.
+func sameAd(a, b classad.ClassAd, name string) bool {
+	return strings.EqualFold(a.EvalAttribute(name).String(), b.EvalAttribute(name).String())
+}
+
+// knownTypes are the MyTypes of the ads that aren't generic.
+var knownTypes = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, t := range adTypes {
+		m[t] = true
+	}
+	return m
+}()
+
+// invocation holds the parsed arguments of a command.
+type invocation struct {
+	pool       string
+	name       string
+	constraint string
+	limit      int
+	json       bool
+	attributes []string
+	subsystem  string
+	reason     string
+	batchName  string
+	// flags are the options without values, e.g. -terse or -startd.
+	flags []string
+	// positional are the other arguments, e.g. job IDs.
+	positional []string
+}
+
+// parseArgs parses the arguments of a command.
+func parseArgs(args []string) (*invocation, error) {
+	var inv invocation
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			inv.positional = append(inv.positional, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "-af") {
+			// attribute names run to the end of the arguments
+			inv.attributes = args[i+1:]
+			if len(inv.attributes) == 0 {
+				return nil, fmt.Errorf("%s requires at least one attribute", arg)
+			}
+			break
+		}
+		var value *string
+		switch arg {
+		case "-pool":
+			value = &inv.pool
+		case "-name":
+			value = &inv.name
+		case "-constraint":
+			value = &inv.constraint
+		case "-subsystem":
+			value = &inv.subsystem
+		case "-reason":
+			value = &inv.reason
+		case "-batch-name":
+			value = &inv.batchName
+		case "-limit":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires an argument", arg)
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid limit: %s", args[i])
+			}
+			inv.limit = n
+			continue
+		case "-json":
+			inv.json = true
+			continue
+		default:
+			inv.flags = append(inv.flags, arg)
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("%s requires an argument", arg)
+		}
+		i++
+		*value = args[i]
+	}
+	return &inv, nil
+}
+
+// hasFlag returns true if the option was given.
+func (inv *invocation) hasFlag(flag string) bool {
+	for _, f := range inv.flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// selection returns a function matching the jobs selected by the job IDs,
+// owners and constraint. Jobs must match one of the IDs or owners, if any,
+// and the constraint.
+func (inv *invocation) selection() (func(classad.ClassAd) bool, error) {
+	ids := make([]htcondor.JobID, 0)
+	owners := make([]string, 0)
+	for _, arg := range inv.positional {
+		if id, err := htcondor.ParseJobID(arg); err == nil {
+			ids = append(ids, id)
+		} else {
+			owners = append(owners, arg)
+		}
+	}
+	var constraint *classad.Expr
+	if inv.constraint != "" {
+		var err error
+		if constraint, err = classad.ParseExpr(inv.constraint); err != nil {
+			return nil, fmt.Errorf("invalid constraint: %w", err)
+		}
+	}
+	return func(ad classad.ClassAd) bool {
+		if constraint != nil && !constraint.Matches(ad) {
+			return false
+		}
+		if len(ids) == 0 && len(owners) == 0 {
+			return true
+		}
+		id := jobID(ad)
+		for _, i := range ids {
+			if i.Contains(id) {
+				return true
+			}
+		}
+		owner := ad.EvalAttribute("Owner").String()
+		for _, o := range owners {
+			if o == owner {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// output formats the ads as the command would print them: in long format, as
+// JSON with -json, or as "Name = value" lines for each attribute with
+// -af:lrng. The -limit argument is applied.
+func (inv *invocation) output(ads []classad.ClassAd) *htcondor.Result {
+	if inv.limit > 0 && len(ads) > inv.limit {
+		ads = ads[:inv.limit]
+	}
+	var b bytes.Buffer
+	switch {
+	case len(inv.attributes) > 0:
+		for i, ad := range ads {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			for _, name := range inv.attributes {
+				a, ok := ad.Lookup(name)
+				if !ok {
+					a = classad.Attribute{Type: classad.Undefined}
+				}
+				fmt.Fprintf(&b, "%s = %s\n", name, a.Unparse())
+			}
+		}
+	case inv.json:
+		if len(ads) > 0 {
+			out, err := json.MarshalIndent(ads, "", "  ")
+			if err != nil {
+				return failure("Error: " + err.Error())
+			}
+			b.Write(out)
+			b.WriteString("\n")
+		}
+	default:
+		for _, ad := range ads {
+			ad.WriteTo(&b)
+			b.WriteString("\n")
+		}
+	}
+	return &htcondor.Result{Stdout: b.Bytes()}
+}
+
+// failure returns the result of a command that failed with the message.
+func failure(msg string) *htcondor.Result {
+	return &htcondor.Result{Stderr: []byte(msg + "\n"), ExitCode: 1}
+}
+
+// copyAd returns a shallow copy of the ad.
+func copyAd(ad classad.ClassAd) classad.ClassAd {
+	c := make(classad.ClassAd, len(ad))
+	for k, v := range ad {
+		c[k] = v
+	}
+	return c
+}
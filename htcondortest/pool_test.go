@@ -0,0 +1,310 @@
+package htcondortest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retzkek/htcondor-go"
+	"github.com/retzkek/htcondor-go/classad"
+	"github.com/retzkek/htcondor-go/submit"
+)
+
+func newTestPool() *Pool {
+	pool := NewPool()
+	pool.Now = func() time.Time { return time.Unix(1700000000, 0) }
+	pool.AddSchedd("schedd1@example.com")
+	pool.AddSchedd("schedd2@example.com")
+	pool.AddMachine(classad.ClassAd{
+		"Name":  classad.Attribute{Type: classad.String, Value: "slot1@worker1"},
+		"Cpus":  classad.Attribute{Type: classad.Integer, Value: int64(8)},
+		"State": classad.Attribute{Type: classad.String, Value: "Unclaimed"},
+	})
+	pool.AddMachine(classad.ClassAd{
+		"Name":  classad.Attribute{Type: classad.String, Value: "slot1@worker2"},
+		"Cpus":  classad.Attribute{Type: classad.Integer, Value: int64(2)},
+		"State": classad.Attribute{Type: classad.String, Value: "Claimed"},
+	})
+	return pool
+}
+
+func jobStatuses(t *testing.T, jobs []htcondor.JobAd) []htcondor.JobStatus {
+	t.Helper()
+	statuses := make([]htcondor.JobStatus, len(jobs))
+	for i, j := range jobs {
+		s, err := j.Status()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses[i] = s
+	}
+	return statuses
+}
+
+func TestPoolJobLifecycle(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool()
+	schedd := htcondor.NewSchedd("", "schedd1@example.com").WithExecutor(pool)
+
+	desc := submit.New().
+		Set("executable", "/bin/sleep").
+		Set("arguments", "60").
+		Set("request_memory", "1024").
+		SetAttribute("Experiment", `"nova"`).
+		Queue(submit.QueueN(3))
+	res, err := schedd.Submit(ctx, desc, htcondor.SubmitOptions{BatchName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Cluster != 1 || res.Procs() != 3 {
+		t.Fatalf("unexpected submit result %+v", res)
+	}
+
+	jobs, err := schedd.Query(ctx, htcondor.JobsMatching(`Experiment == "nova" && RequestMemory > 512`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(jobs))
+	}
+	if cmd := classad.ClassAd(jobs[0]).EvalAttribute("Cmd").String(); cmd != "/bin/sleep" {
+		t.Errorf("expected Cmd /bin/sleep, got %s", cmd)
+	}
+	if batch := classad.ClassAd(jobs[0]).EvalAttribute("JobBatchName").String(); batch != "test" {
+		t.Errorf("expected JobBatchName test, got %s", batch)
+	}
+
+	if _, err := schedd.Hold(ctx, htcondor.Jobs(htcondor.JobID{Cluster: 1, Proc: 1}), "testing"); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = schedd.Query(ctx, htcondor.Jobs(htcondor.ClusterID(1)), "ClusterId", "ProcId", "JobStatus", "HoldReason")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := jobStatuses(t, jobs)
+	if statuses[0] != htcondor.JobIdle || statuses[1] != htcondor.JobHeld {
+		t.Errorf("unexpected statuses %v", statuses)
+	}
+	if reason := classad.ClassAd(jobs[1]).EvalAttribute("HoldReason").String(); reason != "testing" {
+		t.Errorf("expected hold reason, got %s", reason)
+	}
+
+	// releasing a job that isn't held fails
+	_, err = schedd.Release(ctx, htcondor.Jobs(htcondor.JobID{Cluster: 1, Proc: 0}))
+	var actionErr *htcondor.JobActionError
+	if !errors.As(err, &actionErr) || len(actionErr.Failed) != 1 || actionErr.Failed[0].JobID.Proc != 0 {
+		t.Errorf("expected release of 1.0 to fail, got %v", err)
+	}
+
+	n, err := schedd.Edit(ctx, htcondor.Jobs(htcondor.ClusterID(1)), "RequestMemory", classad.Attribute{Type: classad.Integer, Value: int64(2048)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 jobs edited, got %d", n)
+	}
+	if _, err := schedd.Edit(ctx, htcondor.Jobs(htcondor.ClusterID(1)), "ClusterId", classad.Attribute{Type: classad.Integer, Value: int64(2)}); err == nil {
+		t.Error("expected error editing ClusterId")
+	}
+
+	if err := pool.Schedd("schedd1@example.com").SetJobStatus(htcondor.JobID{Cluster: 1, Proc: 2}, htcondor.JobRunning); err != nil {
+		t.Fatal(err)
+	}
+	scheddAds, err := htcondor.NewCollector("").WithExecutor(pool).Schedds(ctx, `Name == "schedd1@example.com"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheddAds) != 1 {
+		t.Fatalf("expected 1 schedd ad, got %d", len(scheddAds))
+	}
+	if n, _ := scheddAds[0].TotalRunningJobs(); n != 1 {
+		t.Errorf("expected 1 running job, got %d", n)
+	}
+	if n, _ := scheddAds[0].TotalHeldJobs(); n != 1 {
+		t.Errorf("expected 1 held job, got %d", n)
+	}
+
+	if _, err := schedd.Remove(ctx, htcondor.JobsMatching("RequestMemory == 2048")); err != nil {
+		t.Fatal(err)
+	}
+	// actions on whole clusters report the cluster
+	if _, err := schedd.Submit(ctx, submit.New().Set("executable", "/bin/true").Queue(submit.QueueN(2)), htcondor.SubmitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	results, err := schedd.Remove(ctx, htcondor.Jobs(htcondor.ClusterID(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].OK || results[0].JobID != htcondor.ClusterID(2) {
+		t.Errorf("unexpected results removing cluster %+v", results)
+	}
+	if jobs, err := schedd.Query(ctx, htcondor.JobSelection{}); err != nil || len(jobs) != 0 {
+		t.Errorf("expected empty queue, got %d jobs (%v)", len(jobs), err)
+	}
+	if history := pool.Schedd("schedd1@example.com").History(); len(history) != 5 {
+		t.Errorf("expected 5 jobs in history, got %d", len(history))
+	}
+	if _, err := schedd.Remove(ctx, htcondor.Jobs(htcondor.ClusterID(1))); err == nil {
+		t.Error("expected error removing jobs no longer in queue")
+	}
+}
+
+func TestPoolSubmitErrors(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool()
+	desc := submit.New().Set("executable", "/bin/true").Queue(submit.QueueFromFile([]string{"foo"}, "/nonexistent/items.txt"))
+	_, err := htcondor.NewSchedd("", "").WithExecutor(pool).Submit(ctx, desc, htcondor.SubmitOptions{})
+	var submitErr *htcondor.SubmitError
+	if !errors.As(err, &submitErr) {
+		t.Errorf("expected *SubmitError, got %v", err)
+	}
+	_, err = htcondor.NewSchedd("", "nosuchschedd").WithExecutor(pool).Query(ctx, htcondor.JobSelection{})
+	var exitErr *htcondor.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Stderr != "Error: Can't find address for schedd nosuchschedd" {
+		t.Errorf("expected unknown schedd error, got %v", err)
+	}
+}
+
+func TestPoolSubmitFile(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool()
+	f, err := submit.Parse(strings.NewReader("executable = /bin/echo\narguments = $(name)\nqueue name in [1:] (a, b, c)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedd := htcondor.NewSchedd("", "").WithExecutor(pool)
+	res, err := schedd.Submit(ctx, f, htcondor.SubmitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Procs() != 2 {
+		t.Fatalf("expected 2 jobs, got %d", res.Procs())
+	}
+	jobs, err := schedd.Query(ctx, htcondor.JobSelection{}, "Args")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || classad.ClassAd(jobs[0]).EvalAttribute("Args").String() != "b" {
+		t.Errorf("unexpected jobs %v", jobs)
+	}
+}
+
+func TestPoolCollector(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool()
+	pool.Address = "cm.example.com"
+	pool.AddAd(classad.ClassAd{
+		"MyType": classad.Attribute{Type: classad.String, Value: "Widget"},
+		"Name":   classad.Attribute{Type: classad.String, Value: "widget1"},
+	})
+	collector := htcondor.NewCollector("cm.example.com").WithExecutor(pool)
+
+	startds, err := collector.Startds(ctx, "Cpus >= 4", "Name", "Cpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(startds) != 1 || startds[0].Name() != "slot1@worker1" {
+		t.Errorf("unexpected startds %v", startds)
+	}
+	if len(startds[0]) != 2 {
+		t.Errorf("expected only the requested attributes, got %v", startds[0])
+	}
+	generic, err := collector.Generic(ctx, "Widget", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generic) != 1 || generic[0].Name() != "widget1" {
+		t.Errorf("unexpected generic ads %v", generic)
+	}
+	all, err := collector.Any(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Errorf("expected 5 ads, got %d", len(all))
+	}
+	if _, err := htcondor.NewCollector("other.example.com").WithExecutor(pool).Schedds(ctx, ""); err == nil {
+		t.Error("expected error querying another pool")
+	}
+
+	res, err := pool.Run(ctx, "condor_status", []string{"-json", "-limit", "1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ads []map[string]any
+	if err := json.Unmarshal(res.Stdout, &ads); err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || ads[0]["Name"] != "slot1@worker1" {
+		t.Errorf("unexpected JSON output %s", res.Stdout)
+	}
+}
+
+func TestPoolAdvertise(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool()
+	collector := htcondor.NewCollector("").WithExecutor(pool)
+	widget := func(name string, size int64) classad.ClassAd {
+		return classad.ClassAd{
+			"MyType": classad.Attribute{Type: classad.String, Value: "Widget"},
+			"Name":   classad.Attribute{Type: classad.String, Value: name},
+			"Size":   classad.Attribute{Type: classad.Integer, Value: size},
+		}
+	}
+	ads := []classad.ClassAd{widget("widget1", 1), widget("widget2", 2)}
+	if err := collector.Advertise(ctx, htcondor.UpdateAdGeneric, ads, htcondor.AdvertiseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// updates replace the ad with the same name
+	if err := collector.Advertise(ctx, htcondor.UpdateAdGeneric, []classad.ClassAd{widget("widget1", 3)}, htcondor.AdvertiseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	generic, err := collector.Generic(ctx, "Widget", "", "Name", "Size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(map[string]int64)
+	for _, ad := range generic {
+		sizes[ad.Name()], _ = ad["Size"].Int64()
+	}
+	if len(sizes) != 2 || sizes["widget1"] != 3 || sizes["widget2"] != 2 {
+		t.Errorf("unexpected advertised ads %v", generic)
+	}
+
+	if err := collector.Invalidate(ctx, htcondor.InvalidateAdsGeneric, "Widget", "widget1", htcondor.AdvertiseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	generic, err = collector.Generic(ctx, "Widget", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generic) != 1 || generic[0].Name() != "widget2" {
+		t.Errorf("expected only widget2 after invalidation, got %v", generic)
+	}
+}
+
+func TestPoolQueryJobs(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestPool()
+	for _, name := range []string{"schedd1@example.com", "schedd2@example.com"} {
+		desc := submit.New().Set("executable", "/bin/true").Queue(submit.QueueN(2))
+		if _, err := htcondor.NewSchedd("", name).WithExecutor(pool).Submit(ctx, desc, htcondor.SubmitOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream, err := htcondor.NewCollector("").WithExecutor(pool).QueryJobs(ctx, htcondor.PoolQueryOptions{Constraint: "ProcId == 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	jobs, errs := stream.ReadAll()
+	if len(errs) > 0 {
+		t.Fatal(errs[0])
+	}
+	if len(jobs) != 2 {
+		t.Errorf("expected 2 jobs, got %d", len(jobs))
+	}
+}
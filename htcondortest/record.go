@@ -0,0 +1,199 @@
+// Package htcondortest provides executors for testing code that uses the
+// htcondor package without an HTCondor installation.
+//
+// A Recorder runs commands with a real executor and saves each invocation to a
+// fixture file; a Replayer serves the fixtures back. Tests typically use Open,
+// which records when the HTCONDOR_RECORD environment variable is set (e.g. on a
+// submit node) and replays otherwise (e.g. in CI):
+//
+//	func TestJobs(t *testing.T) {
+//	    e, err := htcondortest.Open("testdata/jobs")
+//	    if err != nil {
+//	        t.Fatal(err)
+//	    }
+//	    jobs, err := htcondor.NewSchedd("", "").WithExecutor(e).Query(ctx, htcondor.JobsMatching("true"))
+//	    ...
+//	}
+//
+// Pool is an in-memory fake pool, for tests that change the state of jobs or
+// need ads that would be tedious to record.
+package htcondortest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/retzkek/htcondor-go"
+)
+
+// RecordEnv is the environment variable that makes Open record fixtures rather
+// than replay them.
+const RecordEnv = "HTCONDOR_RECORD"
+
+// ErrNoFixture is returned by Replayer when no fixture matches a command.
+var ErrNoFixture = errors.New("no fixture for command")
+
+// Fixture is a recorded command invocation.
+type Fixture struct {
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	Stdin    string   `json:"stdin,omitempty"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+}
+
+// matches returns true if the fixture was recorded for the command.
+func (f *Fixture) matches(name string, args []string, stdin []byte) bool {
+	return f.Command == name && slices.Equal(f.Args, args) && f.Stdin == string(stdin)
+}
+
+// Result returns the recorded output of the command.
+func (f *Fixture) Result() *htcondor.Result {
+	return &htcondor.Result{
+		Stdout:   []byte(f.Stdout),
+		Stderr:   []byte(f.Stderr),
+		ExitCode: f.ExitCode,
+	}
+}
+
+// fixtureName returns the file name of the fixture for a command, which is
+// the command name and a hash of its arguments and input.
+func fixtureName(name string, args []string, stdin []byte) string {
+	h := sha256.New()
+	for _, a := range args {
+		h.Write([]byte(a))
+		h.Write([]byte{0})
+	}
+	h.Write(stdin)
+	return filepath.Base(name) + "-" + hex.EncodeToString(h.Sum(nil))[:16] + ".json"
+}
+
+// Open returns a Recorder for dir, using htcondor.DefaultExecutor, if the
+// HTCONDOR_RECORD environment variable is set to a non-empty value, and a
+// Replayer for the fixtures in dir otherwise.
+func Open(dir string) (htcondor.Executor, error) {
+	if os.Getenv(RecordEnv) != "" {
+		return NewRecorder(dir, htcondor.DefaultExecutor), nil
+	}
+	return NewReplayer(dir)
+}
+
+// Recorder is an executor that runs commands with another executor and writes
+// each invocation to a fixture file in Dir. A command run again with the same
+// arguments and input overwrites its fixture.
+//
+// Commands that can't be run are not recorded. Arguments that change between
+// runs, e.g. constraints that include the current time, will keep the
+// fixtures from matching when replayed.
+type Recorder struct {
+	Dir      string
+	Executor htcondor.Executor
+	mu       sync.Mutex
+}
+
+// NewRecorder creates a recorder that runs commands with e and records them
+// in dir, which is created if needed.
+func NewRecorder(dir string, e htcondor.Executor) *Recorder {
+	return &Recorder{Dir: dir, Executor: e}
+}
+
+// Run runs the command and records it.
+func (r *Recorder) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*htcondor.Result, error) {
+	var in []byte
+	if stdin != nil {
+		var err error
+		if in, err = io.ReadAll(stdin); err != nil {
+			return nil, fmt.Errorf("error reading stdin: %w", err)
+		}
+		stdin = bytes.NewReader(in)
+	}
+	res, err := r.Executor.Run(ctx, name, args, stdin)
+	if err != nil {
+		return res, err
+	}
+	f := Fixture{
+		Command:  name,
+		Args:     args,
+		Stdin:    string(in),
+		Stdout:   string(res.Stdout),
+		Stderr:   string(res.Stderr),
+		ExitCode: res.ExitCode,
+	}
+	if err := r.write(fixtureName(name, args, in), &f); err != nil {
+		return res, fmt.Errorf("error recording fixture: %w", err)
+	}
+	return res, nil
+}
+
+func (r *Recorder) write(file string, f *Fixture) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.Dir, file), append(b, '\n'), 0o644)
+}
+
+// Replayer is an executor that serves recorded fixtures. Commands are matched
+// on their name, arguments and input; a command with no fixture returns an
+// error matching ErrNoFixture.
+type Replayer struct {
+	Fixtures []*Fixture
+}
+
+// NewReplayer loads the fixtures (*.json files) in dir.
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	r := Replayer{Fixtures: make([]*Fixture, 0, len(files))}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading fixture: %w", err)
+		}
+		var f Fixture
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("error decoding fixture %s: %w", file, err)
+		}
+		r.Fixtures = append(r.Fixtures, &f)
+	}
+	return &r, nil
+}
+
+// Run returns the result recorded for the command.
+func (r *Replayer) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*htcondor.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var in []byte
+	if stdin != nil {
+		var err error
+		if in, err = io.ReadAll(stdin); err != nil {
+			return nil, fmt.Errorf("error reading stdin: %w", err)
+		}
+	}
+	for _, f := range r.Fixtures {
+		if f.matches(name, args, in) {
+			return f.Result(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoFixture, name, strings.Join(args, " "))
+}
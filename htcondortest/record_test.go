@@ -0,0 +1,102 @@
+package htcondortest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/retzkek/htcondor-go"
+)
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	real := htcondor.ExecutorFunc(func(ctx context.Context, name string, args []string, stdin io.Reader) (*htcondor.Result, error) {
+		if stdin != nil {
+			return &htcondor.Result{Stderr: []byte("ERROR: bad submit file\n"), ExitCode: 1}, nil
+		}
+		return &htcondor.Result{Stdout: []byte("Name = \"" + args[len(args)-1] + "\"\n")}, nil
+	})
+
+	rec := NewRecorder(dir, real)
+	if _, err := rec.Run(ctx, "condor_q", []string{"-long", "alice"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Run(ctx, "condor_q", []string{"-long", "bob"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Run(ctx, "condor_submit", []string{"-"}, strings.NewReader("bad\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Fixtures) != 3 {
+		t.Fatalf("expected 3 fixtures, got %d", len(rep.Fixtures))
+	}
+	res, err := rep.Run(ctx, "condor_q", []string{"-long", "bob"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Stdout) != "Name = \"bob\"\n" {
+		t.Errorf("unexpected stdout %q", res.Stdout)
+	}
+	res, err = rep.Run(ctx, "condor_submit", []string{"-"}, strings.NewReader("bad\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 1 || string(res.Stderr) != "ERROR: bad submit file\n" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if _, err := rep.Run(ctx, "condor_q", []string{"-long", "carol"}, nil); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected ErrNoFixture, got %v", err)
+	}
+}
+
+func TestRecordReplayCommand(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	real := htcondor.ExecutorFunc(func(ctx context.Context, name string, args []string, stdin io.Reader) (*htcondor.Result, error) {
+		return &htcondor.Result{Stdout: []byte("Name = \"slot1@host\"\n\nName = \"slot2@host\"\n")}, nil
+	})
+	cmd := htcondor.NewCommand("condor_status").WithPool("pool").WithConstraint("Cpus > 1")
+	if _, err := cmd.Copy().WithExecutor(NewRecorder(dir, real)).RunWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ads, err := cmd.Copy().WithExecutor(rep).RunWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 2 || ads[1]["Name"].String() != "slot2@host" {
+		t.Errorf("unexpected ads %v", ads)
+	}
+	if _, err := cmd.Copy().WithLimit(1).WithExecutor(rep).RunWithContext(ctx); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected ErrNoFixture, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(RecordEnv, "")
+	e, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.(*Replayer); !ok {
+		t.Errorf("expected *Replayer, got %T", e)
+	}
+	t.Setenv(RecordEnv, "1")
+	if e, _ = Open(dir); e.(*Recorder).Executor != htcondor.DefaultExecutor {
+		t.Errorf("expected recorder using the default executor")
+	}
+}
@@ -0,0 +1,472 @@
+package htcondortest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/retzkek/htcondor-go"
+	"github.com/retzkek/htcondor-go/classad"
+	"github.com/retzkek/htcondor-go/submit"
+)
+
+// Schedd is a schedd in a fake pool, holding the job queue.
+type Schedd struct {
+	pool        *Pool
+	name        string
+	nextCluster int64
+	jobs        []classad.ClassAd
+	history     []classad.ClassAd
+}
+
+// Name returns the name of the schedd.
+func (s *Schedd) Name() string {
+	return s.name
+}
+
+// Jobs returns copies of the ads of the jobs in the queue.
+func (s *Schedd) Jobs() []classad.ClassAd {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	jobs := make([]classad.ClassAd, len(s.jobs))
+	for i, ad := range s.jobs {
+		jobs[i] = copyAd(ad)
+	}
+	return jobs
+}
+
+// Job returns a copy of the ad of a job in the queue.
+func (s *Schedd) Job(id htcondor.JobID) (classad.ClassAd, bool) {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	if ad := s.job(id); ad != nil {
+		return copyAd(ad), true
+	}
+	return nil, false
+}
+
+// History returns copies of the ads of the jobs that have left the queue,
+// i.e. were removed or completed, oldest first.
+func (s *Schedd) History() []classad.ClassAd {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	jobs := make([]classad.ClassAd, len(s.history))
+	for i, ad := range s.history {
+		jobs[i] = copyAd(ad)
+	}
+	return jobs
+}
+
+// SetJobStatus changes the status of a job, e.g. to simulate it starting to
+// run or completing. Jobs that are JobRemoved or JobCompleted leave the queue.
+func (s *Schedd) SetJobStatus(id htcondor.JobID, status htcondor.JobStatus) error {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	ad := s.job(id)
+	if ad == nil {
+		return fmt.Errorf("job %s not found", id)
+	}
+	s.setStatus(ad, status)
+	if status == htcondor.JobCompleted {
+		ad["CompletionDate"] = intAttr(s.pool.Now().Unix())
+	}
+	s.flush()
+	return nil
+}
+
+// Update sets attributes of a job, e.g. RemoteHost when it starts running.
+func (s *Schedd) Update(id htcondor.JobID, attrs classad.ClassAd) error {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	ad := s.job(id)
+	if ad == nil {
+		return fmt.Errorf("job %s not found", id)
+	}
+	for k, v := range attrs {
+		ad[k] = v
+	}
+	return nil
+}
+
+// job returns the ad of a job in the queue, or nil.
+func (s *Schedd) job(id htcondor.JobID) classad.ClassAd {
+	for _, ad := range s.jobs {
+		if jobID(ad) == id {
+			return ad
+		}
+	}
+	return nil
+}
+
+// setStatus changes the status of a job.
+func (s *Schedd) setStatus(ad classad.ClassAd, status htcondor.JobStatus) {
+	if old, ok := ad.Lookup("JobStatus"); ok {
+		ad["LastJobStatus"] = old
+	}
+	ad["JobStatus"] = intAttr(int64(status))
+	ad["EnteredCurrentStatus"] = intAttr(s.pool.Now().Unix())
+}
+
+// flush moves jobs that are JobRemoved or JobCompleted to the history.
+func (s *Schedd) flush() {
+	s.jobs = slices.DeleteFunc(s.jobs, func(ad classad.ClassAd) bool {
+		if jobStatus(ad).IsTerminal() {
+			s.history = append(s.history, ad)
+			return true
+		}
+		return false
+	})
+}
+
+// ad returns the schedd's collector ad.
+func (s *Schedd) ad() classad.ClassAd {
+	counts := make(map[htcondor.JobStatus]int64)
+	for _, ad := range s.jobs {
+		counts[jobStatus(ad)]++
+	}
+	machine := s.name
+	if _, host, ok := strings.Cut(s.name, "@"); ok {
+		machine = host
+	}
+	return classad.ClassAd{
+		"MyType":           stringAttr("Scheduler"),
+		"Name":             stringAttr(s.name),
+		"Machine":          stringAttr(machine),
+		"MyAddress":        stringAttr("<127.0.0.1:9618>"),
+		"ScheddIpAddr":     stringAttr("<127.0.0.1:9618>"),
+		"TotalJobAds":      intAttr(int64(len(s.jobs))),
+		"TotalIdleJobs":    intAttr(counts[htcondor.JobIdle]),
+		"TotalRunningJobs": intAttr(counts[htcondor.JobRunning]),
+		"TotalHeldJobs":    intAttr(counts[htcondor.JobHeld]),
+	}
+}
+
+// submit handles condor_submit.
+func (p *Pool) submit(command string, inv *invocation, stdin []byte) *htcondor.Result {
+	s, res := p.targetSchedd(inv)
+	if res != nil {
+		return res
+	}
+	if len(inv.positional) != 1 {
+		return failure("ERROR: expected a single submit file")
+	}
+	var f *submit.File
+	var err error
+	if inv.positional[0] == "-" {
+		f, err = submit.Parse(bytes.NewReader(stdin))
+	} else {
+		f, err = submit.ParseFile(inv.positional[0])
+	}
+	if err != nil {
+		return failure("ERROR: " + err.Error())
+	}
+	jobs, err := f.Expand(submit.ExpandOptions{Cluster: int(s.nextCluster), Getenv: os.Getenv})
+	if err != nil {
+		return failure("ERROR: " + err.Error())
+	}
+	if len(jobs) == 0 {
+		return failure("ERROR: no jobs queued")
+	}
+	cluster := s.nextCluster
+	s.nextCluster++
+	now := p.Now().Unix()
+	for _, j := range jobs {
+		ad := s.jobAd(j, cluster, now)
+		if inv.batchName != "" {
+			ad["JobBatchName"] = stringAttr(inv.batchName)
+		}
+		s.jobs = append(s.jobs, ad)
+	}
+	first, last := jobs[0].Proc, jobs[len(jobs)-1].Proc
+	if inv.hasFlag("-terse") {
+		return &htcondor.Result{Stdout: fmt.Appendf(nil, "%d.%d - %d.%d\n", cluster, first, cluster, last)}
+	}
+	return &htcondor.Result{Stdout: fmt.Appendf(nil, "Submitting job(s)%s\n%d job(s) submitted to cluster %d.\n",
+		strings.Repeat(".", len(jobs)), len(jobs), cluster)}
+}
+
+// submitAttributes maps submit commands to the job attributes they set, and
+// whether the value is a string.
+var submitAttributes = map[string]struct {
+	name     string
+	isString bool
+}{
+	"executable":            {"Cmd", true},
+	"arguments":             {"Args", true},
+	"environment":           {"Env", true},
+	"input":                 {"In", true},
+	"output":                {"Out", true},
+	"error":                 {"Err", true},
+	"log":                   {"UserLog", true},
+	"initialdir":            {"Iwd", true},
+	"accounting_group":      {"AcctGroup", true},
+	"accounting_group_user": {"AcctGroupUser", true},
+	"batch_name":            {"JobBatchName", true},
+	"request_cpus":          {"RequestCpus", false},
+	"request_memory":        {"RequestMemory", false},
+	"request_disk":          {"RequestDisk", false},
+	"request_gpus":          {"RequestGpus", false},
+	"requirements":          {"Requirements", false},
+	"rank":                  {"Rank", false},
+	"priority":              {"JobPrio", false},
+	"leave_in_queue":        {"LeaveJobInQueue", false},
+}
+
+// universes maps universe names to JobUniverse values.
+var universes = map[string]int64{
+	"vanilla":   5,
+	"docker":    5,
+	"container": 5,
+	"scheduler": 7,
+	"grid":      9,
+	"java":      10,
+	"parallel":  11,
+	"local":     12,
+	"vm":        13,
+}
+
+// jobAd builds the ad of a submitted job.
+func (s *Schedd) jobAd(j *submit.Job, cluster, now int64) classad.ClassAd {
+	ad := classad.ClassAd{
+		"MyType":               stringAttr("Job"),
+		"TargetType":           stringAttr("Machine"),
+		"ClusterId":            intAttr(cluster),
+		"ProcId":               intAttr(int64(j.Proc)),
+		"GlobalJobId":          stringAttr(fmt.Sprintf("%s#%d.%d#%d", s.name, cluster, j.Proc, now)),
+		"Owner":                stringAttr(s.pool.User),
+		"QDate":                intAttr(now),
+		"EnteredCurrentStatus": intAttr(now),
+		"JobStatus":            intAttr(int64(htcondor.JobIdle)),
+		"JobUniverse":          intAttr(universes["vanilla"]),
+		"RequestCpus":          intAttr(1),
+	}
+	for _, c := range j.Commands {
+		key := strings.ToLower(c.Key)
+		if a, ok := submitAttributes[key]; ok {
+			if a.isString {
+				ad[a.name] = stringAttr(c.Value)
+			} else {
+				ad[a.name] = classad.ParseAttribute(c.Value)
+			}
+		}
+		switch key {
+		case "universe":
+			if u, ok := universes[strings.ToLower(c.Value)]; ok {
+				ad["JobUniverse"] = intAttr(u)
+			}
+		case "hold":
+			if b, err := classad.ParseAttribute(c.Value).Bool(); err == nil && b {
+				ad["JobStatus"] = intAttr(int64(htcondor.JobHeld))
+				ad["HoldReason"] = stringAttr("submitted on hold at user's request")
+				ad["HoldReasonCode"] = intAttr(15)
+			}
+		}
+	}
+	for name, value := range j.Attributes() {
+		ad[name] = classad.ParseAttribute(value)
+	}
+	return ad
+}
+
+// jobAction describes how a job management tool changes jobs.
+type jobAction struct {
+	// verb is used in failure messages, e.g. "Couldn't find/remove all jobs".
+	verb string
+	// done is the message reported for each job that was acted on.
+	done string
+	// apply changes the job, or returns why it can't.
+	apply func(s *Schedd, inv *invocation, ad classad.ClassAd) (bool, string)
+}
+
+var jobActions = map[string]jobAction{
+	"condor_rm": {"remove", "marked for removal", func(s *Schedd, inv *invocation, ad classad.ClassAd) (bool, string) {
+		s.setStatus(ad, htcondor.JobRemoved)
+		return true, ""
+	}},
+	"condor_hold": {"hold", "held", func(s *Schedd, inv *invocation, ad classad.ClassAd) (bool, string) {
+		if jobStatus(ad) == htcondor.JobHeld {
+			return false, "already held"
+		}
+		reason := inv.reason
+		if reason == "" {
+			reason = "via condor_hold (by user " + s.pool.User + ")"
+		}
+		s.setStatus(ad, htcondor.JobHeld)
+		ad["HoldReason"] = stringAttr(reason)
+		ad["HoldReasonCode"] = intAttr(1)
+		return true, ""
+	}},
+	"condor_release": {"release", "released", func(s *Schedd, inv *invocation, ad classad.ClassAd) (bool, string) {
+		if jobStatus(ad) != htcondor.JobHeld {
+			return false, "not held to be released"
+		}
+		s.setStatus(ad, htcondor.JobIdle)
+		if r, ok := ad["HoldReason"]; ok {
+			ad["LastHoldReason"] = r
+		}
+		delete(ad, "HoldReason")
+		delete(ad, "HoldReasonCode")
+		return true, ""
+	}},
+	"condor_vacate_job": {"vacate", "vacated", statusChange(htcondor.JobRunning, htcondor.JobIdle, "not running to be vacated")},
+	"condor_suspend":    {"suspend", "suspended", statusChange(htcondor.JobRunning, htcondor.JobSuspended, "not running to be suspended")},
+	"condor_continue":   {"continue", "continued", statusChange(htcondor.JobSuspended, htcondor.JobRunning, "not suspended to be continued")},
+}
+
+// statusChange returns a job action that changes jobs with status from to
+// status to.
+func statusChange(from, to htcondor.JobStatus, failure string) func(s *Schedd, inv *invocation, ad classad.ClassAd) (bool, string) {
+	return func(s *Schedd, inv *invocation, ad classad.ClassAd) (bool, string) {
+		if jobStatus(ad) != from {
+			return false, failure
+		}
+		s.setStatus(ad, to)
+		return true, ""
+	}
+}
+
+// act handles condor_rm and the other job management tools, reporting the
+// result for each job, cluster or constraint as they do.
+func (p *Pool) act(command string, inv *invocation, stdin []byte) *htcondor.Result {
+	action := jobActions[command]
+	s, res := p.targetSchedd(inv)
+	if res != nil {
+		return res
+	}
+	var out bytes.Buffer
+	failed := false
+	report := func(ok bool, format string, args ...any) {
+		fmt.Fprintf(&out, format+"\n", args...)
+		failed = failed || !ok
+	}
+	constraints := make([]string, 0)
+	if inv.constraint != "" {
+		constraints = append(constraints, inv.constraint)
+	}
+	if inv.hasFlag("-all") {
+		constraints = append(constraints, "true")
+	}
+	for _, arg := range inv.positional {
+		id, err := htcondor.ParseJobID(arg)
+		if err != nil {
+			// an owner
+			constraints = append(constraints, fmt.Sprintf("Owner == %s", classad.Attribute{Type: classad.String, Value: arg}.Unparse()))
+			continue
+		}
+		if !id.IsCluster() {
+			ad := s.job(id)
+			if ad == nil {
+				report(false, "Job %s not found", id)
+			} else if ok, msg := action.apply(s, inv, ad); ok {
+				report(true, "Job %s %s", id, action.done)
+			} else {
+				report(false, "Job %s %s", id, msg)
+			}
+			continue
+		}
+		n, ok := 0, true
+		for _, ad := range s.jobs {
+			if id.Contains(jobID(ad)) {
+				n++
+				done, _ := action.apply(s, inv, ad)
+				ok = ok && done
+			}
+		}
+		if n > 0 && ok {
+			report(true, "All jobs in cluster %d have been %s", id.Cluster, action.done)
+		} else {
+			report(false, "Couldn't find/%s all jobs in cluster %d.", action.verb, id.Cluster)
+		}
+	}
+	for _, c := range constraints {
+		x, err := classad.ParseExpr(c)
+		if err != nil {
+			return failure("Error: invalid constraint: " + err.Error())
+		}
+		n, ok := 0, true
+		for _, ad := range s.jobs {
+			if x.Matches(ad) {
+				n++
+				done, _ := action.apply(s, inv, ad)
+				ok = ok && done
+			}
+		}
+		if n > 0 && ok {
+			report(true, "All jobs matching constraint (%s) have been %s", c, action.done)
+		} else {
+			report(false, "Couldn't find/%s all jobs matching constraint (%s)", action.verb, c)
+		}
+	}
+	s.flush()
+	res = &htcondor.Result{Stdout: out.Bytes()}
+	if failed {
+		res.ExitCode = 1
+	}
+	return res
+}
+
+// protectedAttributes can't be changed with condor_qedit.
+var protectedAttributes = []string{"ClusterId", "ProcId", "MyType", "TargetType", "Owner", "GlobalJobId"}
+
+// edit handles condor_qedit.
+func (p *Pool) edit(command string, inv *invocation, stdin []byte) *htcondor.Result {
+	s, res := p.targetSchedd(inv)
+	if res != nil {
+		return res
+	}
+	args := inv.positional
+	if inv.constraint == "" {
+		if len(args) != 3 {
+			return failure("Usage: condor_qedit {cluster | cluster.proc | owner | -constraint constraint} attribute-name attribute-value")
+		}
+		inv.positional, args = args[:1], args[1:]
+	} else {
+		inv.positional = nil
+	}
+	if len(args) != 2 {
+		return failure("Usage: condor_qedit {cluster | cluster.proc | owner | -constraint constraint} attribute-name attribute-value")
+	}
+	name, value := args[0], classad.ParseAttribute(args[1])
+	for _, a := range protectedAttributes {
+		if strings.EqualFold(a, name) {
+			return failure("Update of attribute \"" + name + "\" is not allowed.")
+		}
+	}
+	match, err := inv.selection()
+	if err != nil {
+		return failure("Error: " + err.Error())
+	}
+	n := 0
+	for _, ad := range s.jobs {
+		if match(ad) {
+			ad[name] = value
+			n++
+		}
+	}
+	if n == 0 {
+		return failure("Failed to set attribute \"" + name + "\": no matching jobs")
+	}
+	return &htcondor.Result{Stdout: fmt.Appendf(nil, "Set attribute \"%s\" for %d matching jobs.\n", name, n)}
+}
+
+// jobID returns the ID of a job ad.
+func jobID(ad classad.ClassAd) htcondor.JobID {
+	id, _ := htcondor.JobAd(ad).JobID()
+	return id
+}
+
+// jobStatus returns the status of a job ad.
+func jobStatus(ad classad.ClassAd) htcondor.JobStatus {
+	status, _ := htcondor.JobStatusFromAttribute(ad.EvalAttribute("JobStatus"))
+	return status
+}
+
+func intAttr(v int64) classad.Attribute {
+	return classad.Attribute{Type: classad.Integer, Value: v}
+}
+
+func stringAttr(v string) classad.Attribute {
+	return classad.Attribute{Type: classad.String, Value: v}
+}
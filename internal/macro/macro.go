@@ -0,0 +1,68 @@
+// Package macro scans the $(NAME) macro references shared by the HTCondor
+// configuration and submit description languages.
+package macro
+
+// MaxDepth limits nested macro expansion, to guard against self-referencing
+// macros.
+const MaxDepth = 32
+
+// Ref is a macro reference in a string: $(NAME), a function such as
+// $ENV(NAME), or a match-time substitution $$(NAME).
+type Ref struct {
+	// Func is the name of the function, e.g. "ENV", empty for $(NAME), or
+	// "$" for $$(NAME).
+	Func string
+	// Start is the index of the leading "$".
+	Start int
+	// ArgStart is the index of the start of the argument, after the opening
+	// parenthesis.
+	ArgStart int
+	// End is the index of the closing parenthesis, or -1 if the reference
+	// is unterminated.
+	End int
+}
+
+// Arg returns the argument of the reference in s, which must be terminated.
+func (r Ref) Arg(s string) string {
+	return s[r.ArgStart:r.End]
+}
+
+// Next finds the next macro reference in s at or after i, returning false if
+// there is none. A "$" that doesn't start a reference is not one.
+func Next(s string, i int) (Ref, bool) {
+	for ; i < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		if i+2 < len(s) && s[i+1] == '$' && s[i+2] == '(' {
+			return Ref{Func: "$", Start: i, ArgStart: i + 3, End: matchParen(s, i+2)}, true
+		}
+		j := i + 1
+		for j < len(s) && (s[j] >= 'A' && s[j] <= 'Z' || s[j] == '_') {
+			j++
+		}
+		if j >= len(s) || s[j] != '(' {
+			continue
+		}
+		return Ref{Func: s[i+1 : j], Start: i, ArgStart: j + 1, End: matchParen(s, j)}, true
+	}
+	return Ref{}, false
+}
+
+// matchParen returns the index of the parenthesis closing the one at s[open],
+// or -1 if it isn't closed.
+func matchParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,35 @@
+package macro
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNext(t *testing.T) {
+	s := "a $ b $(NAME:x) $ENV(HOME) $$(OpSys) $lower(x) $(A$(B)) $(OPEN"
+	expected := []Ref{
+		{Func: "", Start: 6, ArgStart: 8, End: 14},
+		{Func: "ENV", Start: 16, ArgStart: 21, End: 25},
+		{Func: "$", Start: 27, ArgStart: 30, End: 35},
+		{Func: "", Start: 47, ArgStart: 49, End: 54},
+		{Func: "", Start: 56, ArgStart: 58, End: -1},
+	}
+	refs := make([]Ref, 0)
+	for i := 0; ; {
+		ref, ok := Next(s, i)
+		if !ok {
+			break
+		}
+		refs = append(refs, ref)
+		if ref.End < 0 {
+			break
+		}
+		i = ref.End + 1
+	}
+	if !reflect.DeepEqual(refs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, refs)
+	}
+	if arg := refs[3].Arg(s); arg != "A$(B)" {
+		t.Errorf("expected nested argument, got %q", arg)
+	}
+}
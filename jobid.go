@@ -0,0 +1,156 @@
+package htcondor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// JobID identifies an HTCondor job by its cluster and process IDs. A negative
+// Proc refers to every job in the cluster.
+type JobID struct {
+	Cluster int64
+	Proc    int64
+}
+
+// ParseJobID parses a job ID in "ClusterId.ProcId" form, or a cluster ID on its
+// own, which refers to every job in the cluster.
+func ParseJobID(s string) (JobID, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ".", 2)
+	cluster, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || cluster < 0 {
+		return JobID{}, fmt.Errorf("invalid job ID: \"%s\"", s)
+	}
+	id := JobID{Cluster: cluster, Proc: -1}
+	if len(parts) == 2 {
+		proc, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || proc < 0 {
+			return JobID{}, fmt.Errorf("invalid job ID: \"%s\"", s)
+		}
+		id.Proc = proc
+	}
+	return id, nil
+}
+
+// ClusterID returns a job ID that refers to every job in the cluster.
+func ClusterID(cluster int64) JobID {
+	return JobID{Cluster: cluster, Proc: -1}
+}
+
+// String returns the job ID in the usual "ClusterId.ProcId" form, or just the
+// cluster ID if the job ID refers to the entire cluster.
+func (j JobID) String() string {
+	if j.Proc < 0 {
+		return fmt.Sprintf("%d", j.Cluster)
+	}
+	return fmt.Sprintf("%d.%d", j.Cluster, j.Proc)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (j JobID) MarshalText() ([]byte, error) {
+	return []byte(j.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (j *JobID) UnmarshalText(text []byte) error {
+	id, err := ParseJobID(string(text))
+	if err != nil {
+		return err
+	}
+	*j = id
+	return nil
+}
+
+// IsCluster returns true if the job ID refers to every job in the cluster.
+func (j JobID) IsCluster() bool {
+	return j.Proc < 0
+}
+
+// Contains returns true if id is the same job, or j refers to the entire
+// cluster that id belongs to.
+func (j JobID) Contains(id JobID) bool {
+	return j.Cluster == id.Cluster && (j.IsCluster() || j.Proc == id.Proc)
+}
+
+// Constraint returns a ClassAd constraint matching the job, or every job in the
+// cluster.
+func (j JobID) Constraint() string {
+	if j.IsCluster() {
+		return fmt.Sprintf("ClusterId == %d", j.Cluster)
+	}
+	return fmt.Sprintf("ClusterId == %d && ProcId == %d", j.Cluster, j.Proc)
+}
+
+// JobIDsConstraint returns a ClassAd constraint matching any of the jobs.
+// Consecutive procs in the same cluster are collapsed into ranges, e.g.
+//
+//	(ClusterId == 42 && ProcId >= 0 && ProcId <= 9) || (ClusterId == 43)
+//
+// It returns "false" if no job IDs are given.
+func JobIDsConstraint(ids ...JobID) string {
+	if len(ids) == 0 {
+		return "false"
+	}
+	sorted := make([]JobID, len(ids))
+	copy(sorted, ids)
+	sort.Slice(sorted, func(i, k int) bool {
+		if sorted[i].Cluster != sorted[k].Cluster {
+			return sorted[i].Cluster < sorted[k].Cluster
+		}
+		return sorted[i].Proc < sorted[k].Proc
+	})
+	terms := make([]string, 0, len(sorted))
+	for i := 0; i < len(sorted); {
+		id := sorted[i]
+		if id.IsCluster() {
+			terms = append(terms, "("+id.Constraint()+")")
+			// the whole cluster covers any procs listed separately
+			for i < len(sorted) && sorted[i].Cluster == id.Cluster {
+				i++
+			}
+			continue
+		}
+		last := id.Proc
+		i++
+		for i < len(sorted) && sorted[i].Cluster == id.Cluster && sorted[i].Proc <= last+1 {
+			last = sorted[i].Proc
+			i++
+		}
+		if last == id.Proc {
+			terms = append(terms, "("+id.Constraint()+")")
+		} else {
+			terms = append(terms, fmt.Sprintf("(ClusterId == %d && ProcId >= %d && ProcId <= %d)", id.Cluster, id.Proc, last))
+		}
+	}
+	return strings.Join(terms, " || ")
+}
+
+// JobIDArgs returns the job IDs as command-line arguments for tools like
+// condor_q and condor_rm.
+func JobIDArgs(ids ...JobID) []string {
+	args := make([]string, len(ids))
+	for i, id := range ids {
+		args[i] = id.String()
+	}
+	return args
+}
+
+// JobAd is a job ClassAd, as returned by condor_q or condor_history, with
+// accessors for common job attributes.
+type JobAd classad.ClassAd
+
+// JobID returns the job ID from the ClusterId and ProcId attributes.
+func (j JobAd) JobID() (JobID, error) {
+	cluster, err := classad.ClassAd(j).EvalAttribute("ClusterId").Int64()
+	if err != nil {
+		return JobID{}, fmt.Errorf("invalid ClusterId: %w", err)
+	}
+	proc, err := classad.ClassAd(j).EvalAttribute("ProcId").Int64()
+	if err != nil {
+		return JobID{}, fmt.Errorf("invalid ProcId: %w", err)
+	}
+	return JobID{Cluster: cluster, Proc: proc}, nil
+}
@@ -0,0 +1,103 @@
+package htcondor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestParseJobID(t *testing.T) {
+	testCases := []struct {
+		s        string
+		expected JobID
+		ok       bool
+	}{
+		{"42.3", JobID{42, 3}, true},
+		{" 42 ", JobID{42, -1}, true},
+		{"42.", JobID{}, false},
+		{"42.-1", JobID{}, false},
+		{"-1.0", JobID{}, false},
+		{"foo", JobID{}, false},
+	}
+	for _, tc := range testCases {
+		id, err := ParseJobID(tc.s)
+		if (err == nil) != tc.ok {
+			t.Errorf("ParseJobID(%q): unexpected error %v", tc.s, err)
+		}
+		if id != tc.expected {
+			t.Errorf("ParseJobID(%q): expected %v, got %v", tc.s, tc.expected, id)
+		}
+	}
+}
+
+func TestJobIDText(t *testing.T) {
+	ids := []JobID{{42, 3}, ClusterID(43)}
+	b, err := json.Marshal(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `["42.3","43"]` {
+		t.Errorf("unexpected JSON %s", b)
+	}
+	var ids2 []JobID
+	if err := json.Unmarshal(b, &ids2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, ids2) {
+		t.Errorf("expected %v, got %v", ids, ids2)
+	}
+	m := map[JobID]int{{42, 3}: 1}
+	if b, err := json.Marshal(m); err != nil || string(b) != `{"42.3":1}` {
+		t.Errorf("unexpected JSON %s (%v)", b, err)
+	}
+}
+
+func TestJobIDContains(t *testing.T) {
+	if !ClusterID(42).Contains(JobID{42, 7}) {
+		t.Error("expected cluster to contain job")
+	}
+	if (JobID{42, 1}).Contains(JobID{42, 7}) {
+		t.Error("expected job not to contain other job")
+	}
+}
+
+func TestJobIDsConstraint(t *testing.T) {
+	testCases := []struct {
+		ids      []JobID
+		expected string
+	}{
+		{nil, "false"},
+		{[]JobID{{42, 3}}, "(ClusterId == 42 && ProcId == 3)"},
+		{[]JobID{{42, 2}, {42, 0}, {42, 1}, {42, 1}, {42, 5}, ClusterID(43), {43, 1}},
+			"(ClusterId == 42 && ProcId >= 0 && ProcId <= 2) || (ClusterId == 42 && ProcId == 5) || (ClusterId == 43)"},
+	}
+	for _, tc := range testCases {
+		if c := JobIDsConstraint(tc.ids...); c != tc.expected {
+			t.Errorf("expected constraint %q, got %q", tc.expected, c)
+		}
+		if _, err := classad.ParseExpr(JobIDsConstraint(tc.ids...)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestJobIDArgs(t *testing.T) {
+	if args := JobIDArgs(JobID{42, 3}, ClusterID(43)); !reflect.DeepEqual(args, []string{"42.3", "43"}) {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestJobAdJobID(t *testing.T) {
+	ad := JobAd{
+		"ClusterId": classad.AttributeFromString("42"),
+		"ProcId":    classad.AttributeFromString("3"),
+	}
+	if id, err := ad.JobID(); err != nil || id != (JobID{42, 3}) {
+		t.Errorf("expected 42.3, got %v (%v)", id, err)
+	}
+	if _, err := (JobAd{}).JobID(); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,293 @@
+package htcondor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// Job queue log operation codes.
+const (
+	logOpNewClassAd                  = 101
+	logOpDestroyClassAd              = 102
+	logOpSetAttribute                = 103
+	logOpDeleteAttribute             = 104
+	logOpBeginTransaction            = 105
+	logOpEndTransaction              = 106
+	logOpLogHistoricalSequenceNumber = 107
+)
+
+// JobQueueLogHeaderKey is the key of the job queue header ad.
+const JobQueueLogHeaderKey = "0.0"
+
+// transactionTimeAttributes are the attributes whose values are used to
+// estimate when a transaction was committed, since the job queue log does not
+// record it.
+var transactionTimeAttributes = []string{
+	"QDate",
+	"EnteredCurrentStatus",
+	"JobCurrentStartDate",
+	"JobStartDate",
+	"CompletionDate",
+	"LastJobLeaseRenewal",
+	"LastSuspensionTime",
+	"JobFinishedHookDone",
+}
+
+// JobQueueLogOptions configures ReadJobQueueLog.
+type JobQueueLogOptions struct {
+	// StopAfterTransaction stops the replay once this many transactions have
+	// been committed, if greater than zero.
+	StopAfterTransaction int
+	// StopAt stops the replay before the first transaction that was committed
+	// after this time, if not zero. See JobQueueLog.Time for how transaction
+	// times are determined.
+	StopAt time.Time
+}
+
+// JobQueueLog is the state of a schedd job queue, reconstructed by replaying
+// its transaction log (job_queue.log).
+type JobQueueLog struct {
+	// Ads are the ClassAds in the queue, keyed by their log key: "0.0" for
+	// the header ad, "0<cluster>.-1" for cluster ads, and "<cluster>.<proc>"
+	// for job ads. Values are parsed with classad.ParseAttribute.
+	Ads map[string]classad.ClassAd
+	// Transactions is the number of transactions that were committed.
+	Transactions int
+	// SkippedOps is the number of operations with unknown codes, e.g. added
+	// by a newer schedd, that were skipped.
+	SkippedOps int
+	// SequenceNumber is the historical sequence number of the log, which is
+	// incremented each time the schedd rotates it.
+	SequenceNumber int64
+	// Time is the estimated time of the last committed transaction.
+	//
+	// The log does not timestamp transactions, so this is the latest of the
+	// log creation time and any timestamp attributes (QDate,
+	// EnteredCurrentStatus, etc.) set so far.
+	Time time.Time
+}
+
+// logOp is a single job queue log operation.
+type logOp struct {
+	op    int
+	key   string
+	name  string
+	value string
+}
+
+// ReadJobQueueLog replays the job queue log read from r. Operations are
+// applied as their transaction is committed; an incomplete transaction at the
+// end of the log is discarded, as the schedd would. Operations this package
+// doesn't know are skipped and counted in SkippedOps.
+func ReadJobQueueLog(r io.Reader, opts JobQueueLogOptions) (*JobQueueLog, error) {
+	q := JobQueueLog{
+		Ads: make(map[string]classad.ClassAd),
+	}
+	scanner := bufio.NewScanner(r)
+	buf := make([]byte, classad.ScanBufferSize)
+	scanner.Buffer(buf, classad.ScanBufferSize)
+	var tx []logOp
+	inTx := false
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		op, err := parseLogOp(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		switch op.op {
+		case logOpBeginTransaction:
+			if inTx {
+				return nil, fmt.Errorf("line %d: nested transaction", lineNum)
+			}
+			inTx = true
+			tx = tx[:0]
+		case logOpEndTransaction:
+			if !inTx {
+				return nil, fmt.Errorf("line %d: end of transaction without beginning", lineNum)
+			}
+			inTx = false
+			if !q.commit(tx, opts) {
+				return &q, nil
+			}
+		case logOpLogHistoricalSequenceNumber:
+			fields := strings.Fields(op.value)
+			if len(fields) > 0 {
+				q.SequenceNumber, _ = strconv.ParseInt(fields[0], 10, 64)
+			}
+			if len(fields) > 1 {
+				if ts, err := strconv.ParseInt(fields[len(fields)-1], 10, 64); err == nil {
+					q.updateTime(ts)
+				}
+			}
+		case logOpNewClassAd, logOpDestroyClassAd, logOpSetAttribute, logOpDeleteAttribute:
+			if inTx {
+				tx = append(tx, op)
+			} else if !q.commit([]logOp{op}, opts) {
+				return &q, nil
+			}
+		default:
+			q.SkippedOps++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// parseLogOp parses a single line of the log.
+func parseLogOp(line string) (logOp, error) {
+	fields := strings.SplitN(line, " ", 2)
+	code, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return logOp{}, fmt.Errorf("invalid operation: \"%s\"", line)
+	}
+	op := logOp{op: code}
+	rest := ""
+	if len(fields) > 1 {
+		rest = fields[1]
+	}
+	switch code {
+	case logOpNewClassAd, logOpDestroyClassAd:
+		parts := strings.Fields(rest)
+		if len(parts) < 1 {
+			return op, fmt.Errorf("missing key: \"%s\"", line)
+		}
+		op.key = parts[0]
+	case logOpSetAttribute:
+		parts := strings.SplitN(rest, " ", 3)
+		if len(parts) < 3 {
+			return op, fmt.Errorf("invalid SetAttribute: \"%s\"", line)
+		}
+		op.key, op.name, op.value = parts[0], parts[1], parts[2]
+	case logOpDeleteAttribute:
+		parts := strings.Fields(rest)
+		if len(parts) < 2 {
+			return op, fmt.Errorf("invalid DeleteAttribute: \"%s\"", line)
+		}
+		op.key, op.name = parts[0], parts[1]
+	case logOpBeginTransaction, logOpEndTransaction:
+	default:
+		op.value = rest
+	}
+	return op, nil
+}
+
+// commit applies the operations of a transaction, returning false without
+// applying them if the replay should stop first.
+func (q *JobQueueLog) commit(ops []logOp, opts JobQueueLogOptions) bool {
+	if opts.StopAfterTransaction > 0 && q.Transactions >= opts.StopAfterTransaction {
+		return false
+	}
+	if !opts.StopAt.IsZero() {
+		if ts := transactionTime(ops); ts > 0 && time.Unix(ts, 0).After(opts.StopAt) {
+			return false
+		}
+	}
+	for _, op := range ops {
+		switch op.op {
+		case logOpNewClassAd:
+			q.Ads[op.key] = make(classad.ClassAd)
+		case logOpDestroyClassAd:
+			delete(q.Ads, op.key)
+		case logOpSetAttribute:
+			ad, ok := q.Ads[op.key]
+			if !ok {
+				ad = make(classad.ClassAd)
+				q.Ads[op.key] = ad
+			}
+			ad[op.name] = classad.ParseAttribute(op.value)
+		case logOpDeleteAttribute:
+			if ad, ok := q.Ads[op.key]; ok {
+				delete(ad, op.name)
+			}
+		}
+	}
+	q.updateTime(transactionTime(ops))
+	q.Transactions++
+	return true
+}
+
+func (q *JobQueueLog) updateTime(ts int64) {
+	if t := time.Unix(ts, 0); ts > 0 && t.After(q.Time) {
+		q.Time = t
+	}
+}
+
+// transactionTime returns the latest timestamp attribute set in the
+// transaction, or zero if there is none.
+func transactionTime(ops []logOp) int64 {
+	var ts int64
+	for _, op := range ops {
+		if op.op != logOpSetAttribute {
+			continue
+		}
+		for _, name := range transactionTimeAttributes {
+			if !strings.EqualFold(op.name, name) {
+				continue
+			}
+			if v, err := strconv.ParseInt(strings.TrimSpace(op.value), 10, 64); err == nil && v > ts {
+				ts = v
+			}
+		}
+	}
+	return ts
+}
+
+// parseLogKey parses a job queue log key into a job ID. Cluster ad keys
+// ("0<cluster>.-1") have a negative Proc.
+func parseLogKey(key string) (JobID, error) {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 {
+		return JobID{}, fmt.Errorf("invalid job queue key: \"%s\"", key)
+	}
+	cluster, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return JobID{}, fmt.Errorf("invalid job queue key: \"%s\"", key)
+	}
+	proc, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return JobID{}, fmt.Errorf("invalid job queue key: \"%s\"", key)
+	}
+	return JobID{Cluster: cluster, Proc: proc}, nil
+}
+
+// Jobs returns the job ads in the queue, keyed by job ID. Attributes of the
+// cluster ad that are not overridden by the job ad are merged in, as the
+// schedd does when chaining job ads to their cluster ad.
+func (q *JobQueueLog) Jobs() map[JobID]classad.ClassAd {
+	clusters := make(map[int64]classad.ClassAd)
+	for key, ad := range q.Ads {
+		id, err := parseLogKey(key)
+		if err == nil && id.Proc < 0 {
+			clusters[id.Cluster] = ad
+		}
+	}
+	jobs := make(map[JobID]classad.ClassAd)
+	for key, ad := range q.Ads {
+		id, err := parseLogKey(key)
+		if err != nil || id.Proc < 0 || id.Cluster <= 0 {
+			continue
+		}
+		job := make(classad.ClassAd, len(ad)+len(clusters[id.Cluster]))
+		for k, v := range clusters[id.Cluster] {
+			job[k] = v
+		}
+		for k, v := range ad {
+			job[k] = v
+		}
+		jobs[id] = job
+	}
+	return jobs
+}
@@ -0,0 +1,135 @@
+package htcondor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var jobQueueLog = `107 3 CreationTimestamp 1700000000
+105
+101 0.0 Job Machine
+103 0.0 NextClusterNum 43
+106
+105
+101 042.-1 Job Machine
+103 042.-1 Owner "alice"
+103 042.-1 Cmd "/bin/hello.sh"
+103 042.-1 RequestMemory 1024
+103 042.-1 Requirements (TARGET.Memory >= RequestMemory)
+103 042.-1 QDate 1700000100
+101 42.0 Job Machine
+103 42.0 ProcId 0
+103 42.0 JobStatus 1
+101 42.1 Job Machine
+103 42.1 ProcId 1
+103 42.1 JobStatus 1
+103 42.1 RequestMemory 2048
+106
+105
+103 42.0 JobStatus 2
+103 42.0 EnteredCurrentStatus 1700000200
+104 42.1 RequestMemory
+106
+105
+103 42.0 JobStatus 4
+103 42.0 EnteredCurrentStatus 1700000300
+102 42.1
+106
+105
+103 42.0 JobStatus 3
+`
+
+func TestReadJobQueueLog(t *testing.T) {
+	q, err := ReadJobQueueLog(strings.NewReader(jobQueueLog), JobQueueLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Transactions != 4 {
+		t.Errorf("expected 4 transactions, got %d", q.Transactions)
+	}
+	if q.SequenceNumber != 3 {
+		t.Errorf("expected sequence number 3, got %d", q.SequenceNumber)
+	}
+	if q.Time.Unix() != 1700000300 {
+		t.Errorf("expected time 1700000300, got %d", q.Time.Unix())
+	}
+	jobs := q.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d", len(jobs))
+	}
+	job := jobs[JobID{42, 0}]
+	// the incomplete final transaction must not be applied
+	if v := job["JobStatus"].Value; v != int64(4) {
+		t.Errorf("expected JobStatus 4, got %v", v)
+	}
+	if v := job["Owner"].Value; v != "alice" {
+		t.Errorf("expected Owner alice from cluster ad, got %v", v)
+	}
+	if a := job["Requirements"]; a.String() != "(TARGET.Memory >= RequestMemory)" {
+		t.Errorf("expected Requirements expression, got %#v", a)
+	}
+}
+
+func TestReadJobQueueLog_stop(t *testing.T) {
+	testCases := []struct {
+		description string
+		opts        JobQueueLogOptions
+		jobs        int
+		status      int64
+		memory      int64
+	}{
+		{"after transaction", JobQueueLogOptions{StopAfterTransaction: 2}, 2, 1, 2048},
+		{"at time", JobQueueLogOptions{StopAt: time.Unix(1700000250, 0)}, 2, 2, 1024},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			q, err := ReadJobQueueLog(strings.NewReader(jobQueueLog), tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jobs := q.Jobs()
+			if len(jobs) != tc.jobs {
+				t.Fatalf("expected %d jobs, got %d", tc.jobs, len(jobs))
+			}
+			if v := jobs[JobID{42, 0}]["JobStatus"].Value; v != tc.status {
+				t.Errorf("expected JobStatus %d, got %v", tc.status, v)
+			}
+			if v := jobs[JobID{42, 1}]["RequestMemory"].Value; v != tc.memory {
+				t.Errorf("expected RequestMemory %d, got %v", tc.memory, v)
+			}
+		})
+	}
+}
+
+func TestReadJobQueueLog_bad(t *testing.T) {
+	for _, s := range []string{"foo\n", "103 1.0 Foo\n", "106\n", "105\n105\n"} {
+		if _, err := ReadJobQueueLog(strings.NewReader(s), JobQueueLogOptions{}); err == nil {
+			t.Errorf("expected error reading %q", s)
+		}
+	}
+}
+
+func TestReadJobQueueLog_unknownOps(t *testing.T) {
+	// operations from newer schedds are skipped, in and out of transactions
+	log := `105
+101 1.0 Job Machine
+999 1.0 something new
+103 1.0 JobStatus 1
+106
+998
+`
+	q, err := ReadJobQueueLog(strings.NewReader(log), JobQueueLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.SkippedOps != 2 {
+		t.Errorf("expected 2 skipped operations, got %d", q.SkippedOps)
+	}
+	if q.Transactions != 1 {
+		t.Errorf("expected 1 transaction, got %d", q.Transactions)
+	}
+	if v := q.Ads["1.0"]["JobStatus"].Value; v != int64(1) {
+		t.Errorf("expected JobStatus 1, got %v", v)
+	}
+}
@@ -0,0 +1,287 @@
+package htcondor
+
+import (
+	"fmt"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// JobStatus is the status of a job, from the JobStatus and LastJobStatus
+// attributes.
+type JobStatus int
+
+// Job status values.
+const (
+	JobIdle               JobStatus = 1
+	JobRunning            JobStatus = 2
+	JobRemoved            JobStatus = 3
+	JobCompleted          JobStatus = 4
+	JobHeld               JobStatus = 5
+	JobTransferringOutput JobStatus = 6
+	JobSuspended          JobStatus = 7
+)
+
+var jobStatusNames = map[JobStatus]string{
+	JobIdle:               "Idle",
+	JobRunning:            "Running",
+	JobRemoved:            "Removed",
+	JobCompleted:          "Completed",
+	JobHeld:               "Held",
+	JobTransferringOutput: "TransferringOutput",
+	JobSuspended:          "Suspended",
+}
+
+// String returns the name of the job status.
+func (s JobStatus) String() string {
+	if name, ok := jobStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("JobStatus(%d)", int(s))
+}
+
+// IsTerminal returns true if the job has left the queue, or will once it is
+// no longer held there (e.g. by LeaveJobInQueue).
+func (s JobStatus) IsTerminal() bool {
+	return s == JobRemoved || s == JobCompleted
+}
+
+// IsRunning returns true if the job is running on an execute slot, including
+// while its output is being transferred back.
+func (s JobStatus) IsRunning() bool {
+	return s == JobRunning || s == JobTransferringOutput
+}
+
+// JobStatusFromAttribute decodes a JobStatus or LastJobStatus attribute.
+func JobStatusFromAttribute(a classad.Attribute) (JobStatus, error) {
+	v, err := a.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid job status: %w", err)
+	}
+	return JobStatus(v), nil
+}
+
+// HoldReasonCode is the reason a job was held, from the HoldReasonCode
+// attribute.
+type HoldReasonCode int
+
+// Hold reason codes.
+const (
+	HoldUserRequest                   HoldReasonCode = 1
+	HoldGlobusGramError               HoldReasonCode = 2
+	HoldJobPolicy                     HoldReasonCode = 3
+	HoldCorruptedCredential           HoldReasonCode = 4
+	HoldJobPolicyUndefined            HoldReasonCode = 5
+	HoldFailedToCreateProcess         HoldReasonCode = 6
+	HoldUnableToOpenOutput            HoldReasonCode = 7
+	HoldUnableToOpenInput             HoldReasonCode = 8
+	HoldUnableToOpenOutputStream      HoldReasonCode = 9
+	HoldUnableToOpenInputStream       HoldReasonCode = 10
+	HoldInvalidTransferAck            HoldReasonCode = 11
+	HoldTransferOutputError           HoldReasonCode = 12
+	HoldTransferInputError            HoldReasonCode = 13
+	HoldIwdError                      HoldReasonCode = 14
+	HoldSubmittedOnHold               HoldReasonCode = 15
+	HoldSpoolingInput                 HoldReasonCode = 16
+	HoldJobShadowMismatch             HoldReasonCode = 17
+	HoldInvalidTransferGoAhead        HoldReasonCode = 18
+	HoldHookPrepareJobFailure         HoldReasonCode = 19
+	HoldMissedDeferredExecutionTime   HoldReasonCode = 20
+	HoldStartdHeldJob                 HoldReasonCode = 21
+	HoldUnableToInitUserLog           HoldReasonCode = 22
+	HoldFailedToAccessUserAccount     HoldReasonCode = 23
+	HoldNoCompatibleShadow            HoldReasonCode = 24
+	HoldInvalidCronSettings           HoldReasonCode = 25
+	HoldSystemPolicy                  HoldReasonCode = 26
+	HoldSystemPolicyUndefined         HoldReasonCode = 27
+	HoldGlexecChownSandboxToUser      HoldReasonCode = 28
+	HoldPrivsepChownSandboxToUser     HoldReasonCode = 29
+	HoldGlexecChownSandboxToCondor    HoldReasonCode = 30
+	HoldPrivsepChownSandboxToCondor   HoldReasonCode = 31
+	HoldMaxTransferInputSizeExceeded  HoldReasonCode = 32
+	HoldMaxTransferOutputSizeExceeded HoldReasonCode = 33
+	HoldJobOutOfResources             HoldReasonCode = 34
+	HoldInvalidDockerImage            HoldReasonCode = 35
+	HoldFailedToCheckpoint            HoldReasonCode = 36
+	HoldEC2UserError                  HoldReasonCode = 37
+	HoldEC2InternalError              HoldReasonCode = 38
+	HoldEC2AdminError                 HoldReasonCode = 39
+	HoldEC2ConnectionProblem          HoldReasonCode = 40
+	HoldEC2ServerError                HoldReasonCode = 41
+	HoldEC2InstancePotentiallyLost    HoldReasonCode = 42
+	HoldPreScriptFailed               HoldReasonCode = 43
+	HoldPostScriptFailed              HoldReasonCode = 44
+	HoldSingularityTestFailed         HoldReasonCode = 45
+	HoldJobDurationExceeded           HoldReasonCode = 46
+	HoldJobExecuteExceeded            HoldReasonCode = 47
+	HoldHookShadowPrepareJobFailure   HoldReasonCode = 48
+)
+
+var holdReasonCodeNames = map[HoldReasonCode]string{
+	HoldUserRequest:                   "UserRequest",
+	HoldGlobusGramError:               "GlobusGramError",
+	HoldJobPolicy:                     "JobPolicy",
+	HoldCorruptedCredential:           "CorruptedCredential",
+	HoldJobPolicyUndefined:            "JobPolicyUndefined",
+	HoldFailedToCreateProcess:         "FailedToCreateProcess",
+	HoldUnableToOpenOutput:            "UnableToOpenOutput",
+	HoldUnableToOpenInput:             "UnableToOpenInput",
+	HoldUnableToOpenOutputStream:      "UnableToOpenOutputStream",
+	HoldUnableToOpenInputStream:       "UnableToOpenInputStream",
+	HoldInvalidTransferAck:            "InvalidTransferAck",
+	HoldTransferOutputError:           "TransferOutputError",
+	HoldTransferInputError:            "TransferInputError",
+	HoldIwdError:                      "IwdError",
+	HoldSubmittedOnHold:               "SubmittedOnHold",
+	HoldSpoolingInput:                 "SpoolingInput",
+	HoldJobShadowMismatch:             "JobShadowMismatch",
+	HoldInvalidTransferGoAhead:        "InvalidTransferGoAhead",
+	HoldHookPrepareJobFailure:         "HookPrepareJobFailure",
+	HoldMissedDeferredExecutionTime:   "MissedDeferredExecutionTime",
+	HoldStartdHeldJob:                 "StartdHeldJob",
+	HoldUnableToInitUserLog:           "UnableToInitUserLog",
+	HoldFailedToAccessUserAccount:     "FailedToAccessUserAccount",
+	HoldNoCompatibleShadow:            "NoCompatibleShadow",
+	HoldInvalidCronSettings:           "InvalidCronSettings",
+	HoldSystemPolicy:                  "SystemPolicy",
+	HoldSystemPolicyUndefined:         "SystemPolicyUndefined",
+	HoldGlexecChownSandboxToUser:      "GlexecChownSandboxToUser",
+	HoldPrivsepChownSandboxToUser:     "PrivsepChownSandboxToUser",
+	HoldGlexecChownSandboxToCondor:    "GlexecChownSandboxToCondor",
+	HoldPrivsepChownSandboxToCondor:   "PrivsepChownSandboxToCondor",
+	HoldMaxTransferInputSizeExceeded:  "MaxTransferInputSizeExceeded",
+	HoldMaxTransferOutputSizeExceeded: "MaxTransferOutputSizeExceeded",
+	HoldJobOutOfResources:             "JobOutOfResources",
+	HoldInvalidDockerImage:            "InvalidDockerImage",
+	HoldFailedToCheckpoint:            "FailedToCheckpoint",
+	HoldEC2UserError:                  "EC2UserError",
+	HoldEC2InternalError:              "EC2InternalError",
+	HoldEC2AdminError:                 "EC2AdminError",
+	HoldEC2ConnectionProblem:          "EC2ConnectionProblem",
+	HoldEC2ServerError:                "EC2ServerError",
+	HoldEC2InstancePotentiallyLost:    "EC2InstancePotentiallyLost",
+	HoldPreScriptFailed:               "PreScriptFailed",
+	HoldPostScriptFailed:              "PostScriptFailed",
+	HoldSingularityTestFailed:         "SingularityTestFailed",
+	HoldJobDurationExceeded:           "JobDurationExceeded",
+	HoldJobExecuteExceeded:            "JobExecuteExceeded",
+	HoldHookShadowPrepareJobFailure:   "HookShadowPrepareJobFailure",
+}
+
+// String returns the name of the hold reason code.
+func (c HoldReasonCode) String() string {
+	if name, ok := holdReasonCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("HoldReasonCode(%d)", int(c))
+}
+
+// IsUserHold returns true if the job was held by a user (or submitted on
+// hold), rather than by HTCondor or a policy.
+func (c HoldReasonCode) IsUserHold() bool {
+	return c == HoldUserRequest || c == HoldSubmittedOnHold
+}
+
+// HoldReasonCodeFromAttribute decodes a HoldReasonCode attribute.
+func HoldReasonCodeFromAttribute(a classad.Attribute) (HoldReasonCode, error) {
+	v, err := a.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid hold reason code: %w", err)
+	}
+	return HoldReasonCode(v), nil
+}
+
+// HoldReasonSubCode further qualifies a HoldReasonCode. Its meaning depends on
+// the code: for file and process errors it is usually the errno, for
+// JobPolicy holds it is set by the policy expression.
+type HoldReasonSubCode int
+
+// String returns the sub code as a number, since its meaning depends on the
+// hold reason code.
+func (c HoldReasonSubCode) String() string {
+	return fmt.Sprintf("%d", int(c))
+}
+
+// HoldReasonSubCodeFromAttribute decodes a HoldReasonSubCode attribute.
+func HoldReasonSubCodeFromAttribute(a classad.Attribute) (HoldReasonSubCode, error) {
+	v, err := a.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid hold reason subcode: %w", err)
+	}
+	return HoldReasonSubCode(v), nil
+}
+
+// JobUniverse is the universe a job runs in, from the JobUniverse attribute.
+// The docker and container universes are vanilla universe jobs.
+type JobUniverse int
+
+// Job universes.
+const (
+	StandardUniverse  JobUniverse = 1
+	VanillaUniverse   JobUniverse = 5
+	SchedulerUniverse JobUniverse = 7
+	MPIUniverse       JobUniverse = 8
+	GridUniverse      JobUniverse = 9
+	JavaUniverse      JobUniverse = 10
+	ParallelUniverse  JobUniverse = 11
+	LocalUniverse     JobUniverse = 12
+	VMUniverse        JobUniverse = 13
+)
+
+var jobUniverseNames = map[JobUniverse]string{
+	StandardUniverse:  "Standard",
+	VanillaUniverse:   "Vanilla",
+	SchedulerUniverse: "Scheduler",
+	MPIUniverse:       "MPI",
+	GridUniverse:      "Grid",
+	JavaUniverse:      "Java",
+	ParallelUniverse:  "Parallel",
+	LocalUniverse:     "Local",
+	VMUniverse:        "VM",
+}
+
+// String returns the name of the universe.
+func (u JobUniverse) String() string {
+	if name, ok := jobUniverseNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("JobUniverse(%d)", int(u))
+}
+
+// JobUniverseFromAttribute decodes a JobUniverse attribute.
+func JobUniverseFromAttribute(a classad.Attribute) (JobUniverse, error) {
+	v, err := a.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("invalid job universe: %w", err)
+	}
+	return JobUniverse(v), nil
+}
+
+// Status returns the job status.
+func (j JobAd) Status() (JobStatus, error) {
+	return JobStatusFromAttribute(classad.ClassAd(j).EvalAttribute("JobStatus"))
+}
+
+// LastStatus returns the status the job had before its current status.
+func (j JobAd) LastStatus() (JobStatus, error) {
+	return JobStatusFromAttribute(classad.ClassAd(j).EvalAttribute("LastJobStatus"))
+}
+
+// HoldReasonCode returns the reason the job was held.
+func (j JobAd) HoldReasonCode() (HoldReasonCode, error) {
+	return HoldReasonCodeFromAttribute(classad.ClassAd(j).EvalAttribute("HoldReasonCode"))
+}
+
+// HoldReasonSubCode returns the hold reason subcode.
+func (j JobAd) HoldReasonSubCode() (HoldReasonSubCode, error) {
+	return HoldReasonSubCodeFromAttribute(classad.ClassAd(j).EvalAttribute("HoldReasonSubCode"))
+}
+
+// Universe returns the job universe.
+func (j JobAd) Universe() (JobUniverse, error) {
+	return JobUniverseFromAttribute(classad.ClassAd(j).EvalAttribute("JobUniverse"))
+}
+
+// ExitBySignal returns true if the job was terminated by a signal.
+func (j JobAd) ExitBySignal() (bool, error) {
+	return classad.ClassAd(j).EvalAttribute("ExitBySignal").Bool()
+}
@@ -0,0 +1,69 @@
+package htcondor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestJobStatus(t *testing.T) {
+	if s := JobHeld.String(); s != "Held" {
+		t.Errorf("expected Held, got %s", s)
+	}
+	if s := JobStatus(42).String(); s != "JobStatus(42)" {
+		t.Errorf("expected JobStatus(42), got %s", s)
+	}
+	if !JobCompleted.IsTerminal() || !JobRemoved.IsTerminal() || JobHeld.IsTerminal() {
+		t.Error("unexpected IsTerminal")
+	}
+	if !JobRunning.IsRunning() || !JobTransferringOutput.IsRunning() || JobIdle.IsRunning() {
+		t.Error("unexpected IsRunning")
+	}
+}
+
+func TestHoldReasonCode(t *testing.T) {
+	if s := HoldTransferInputError.String(); s != "TransferInputError" {
+		t.Errorf("expected TransferInputError, got %s", s)
+	}
+	if !HoldUserRequest.IsUserHold() || HoldJobPolicy.IsUserHold() {
+		t.Error("unexpected IsUserHold")
+	}
+}
+
+func TestJobAdEnums(t *testing.T) {
+	ads, err := classad.ReadClassAds(strings.NewReader(`ClusterId = 42
+ProcId = 0
+JobStatus = 5
+LastJobStatus = 2
+HoldReasonCode = 13
+HoldReasonSubCode = 2
+JobUniverse = 5
+ExitBySignal = false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad := JobAd(ads[0])
+	if s, err := ad.Status(); err != nil || s != JobHeld {
+		t.Errorf("expected Held, got %s (%v)", s, err)
+	}
+	if s, err := ad.LastStatus(); err != nil || s != JobRunning {
+		t.Errorf("expected Running, got %s (%v)", s, err)
+	}
+	if c, err := ad.HoldReasonCode(); err != nil || c != HoldTransferInputError {
+		t.Errorf("expected TransferInputError, got %s (%v)", c, err)
+	}
+	if c, err := ad.HoldReasonSubCode(); err != nil || c != 2 {
+		t.Errorf("expected subcode 2, got %s (%v)", c, err)
+	}
+	if u, err := ad.Universe(); err != nil || u != VanillaUniverse {
+		t.Errorf("expected Vanilla, got %s (%v)", u, err)
+	}
+	if b, err := ad.ExitBySignal(); err != nil || b {
+		t.Errorf("expected false, got %t (%v)", b, err)
+	}
+	if _, err := (JobAd{}).Status(); err == nil {
+		t.Error("expected error for missing JobStatus")
+	}
+}
@@ -0,0 +1,200 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQueueTimeout is returned when a command waited longer than the
+	// QueueTimeout of the concurrency limits to run.
+	ErrQueueTimeout = errors.New("timed out waiting to run command")
+	// ErrQueueFull is returned, without waiting, when MaxQueue commands are
+	// already waiting to run.
+	ErrQueueFull = errors.New("too many commands waiting to run")
+)
+
+// ConcurrencyLimits limit the number of HTCondor tools run at once by the
+// process, so that a burst of requests doesn't overwhelm the local machine or
+// the daemons. Commands over a limit wait in a queue until they can run, the
+// queue times out, or their context is done. Zero values mean no limit.
+type ConcurrencyLimits struct {
+	// Global limits the number of commands running at once.
+	Global int
+	// PerPool limits the number of commands running at once against each
+	// pool, with the empty pool being the local pool.
+	PerPool int
+	// PerSchedd limits the number of schedd commands (condor_q,
+	// condor_history, condor_submit, condor_rm, etc.) running at once
+	// against each schedd.
+	PerSchedd int
+	// Pools overrides PerPool for the named pools.
+	Pools map[string]int
+	// Schedds overrides PerSchedd for the named schedds.
+	Schedds map[string]int
+	// QueueTimeout limits how long a command waits to run before failing
+	// with ErrQueueTimeout.
+	QueueTimeout time.Duration
+	// MaxQueue limits the number of commands waiting to run. Commands beyond
+	// it fail with ErrQueueFull.
+	MaxQueue int
+}
+
+// SetConcurrencyLimits sets the limits for every command run by the process,
+// whether with Run, Stream or on a cache miss. Commands already running or
+// waiting are subject to the previous limits. Commands are not limited until
+// this is called.
+func SetConcurrencyLimits(limits ConcurrencyLimits) {
+	if limits.Global <= 0 && limits.PerPool <= 0 && limits.PerSchedd <= 0 && len(limits.Pools) == 0 && len(limits.Schedds) == 0 {
+		activeLimiter.Store(nil)
+		return
+	}
+	l := &limiter{
+		limits:  limits,
+		pools:   make(map[string]chan struct{}),
+		schedds: make(map[string]chan struct{}),
+	}
+	if limits.Global > 0 {
+		l.global = make(chan struct{}, limits.Global)
+	}
+	activeLimiter.Store(l)
+}
+
+// scheddCommands are the tools that talk to a schedd, which are subject to
+// the per-schedd limits.
+var scheddCommands = map[string]bool{
+	"condor_q":          true,
+	"condor_history":    true,
+	"condor_submit":     true,
+	"condor_rm":         true,
+	"condor_hold":       true,
+	"condor_release":    true,
+	"condor_qedit":      true,
+	"condor_vacate_job": true,
+	"condor_suspend":    true,
+	"condor_continue":   true,
+}
+
+var activeLimiter atomic.Pointer[limiter]
+
+// limiter holds the semaphores for a set of concurrency limits.
+type limiter struct {
+	limits  ConcurrencyLimits
+	global  chan struct{}
+	mu      sync.Mutex
+	pools   map[string]chan struct{}
+	schedds map[string]chan struct{}
+	waiting int
+}
+
+// semaphore returns the semaphore in sems for key, creating it with size n,
+// or nil if n is not positive.
+func (l *limiter) semaphore(sems map[string]chan struct{}, key string, n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := sems[key]
+	if !ok {
+		sem = make(chan struct{}, n)
+		sems[key] = sem
+	}
+	return sem
+}
+
+// limit returns the limit for name from overrides, or def.
+func limit(overrides map[string]int, name string, def int) int {
+	if n, ok := overrides[name]; ok {
+		return n
+	}
+	return def
+}
+
+// acquire waits until the command may run under the limits, returning a
+// function to call when it is done.
+func (l *limiter) acquire(ctx context.Context, c *Command) (func(), error) {
+	pool := c.poolName()
+	var sems []chan struct{}
+	// acquire the most specific limit first, so that commands waiting on a
+	// busy schedd don't hold up commands against other schedds
+	if scheddCommands[c.Command] {
+		name := c.argValue("-name", c.Name)
+		sems = append(sems, l.semaphore(l.schedds, pool+keySeparator+name, limit(l.limits.Schedds, name, l.limits.PerSchedd)))
+	}
+	sems = append(sems, l.semaphore(l.pools, pool, limit(l.limits.Pools, pool, l.limits.PerPool)), l.global)
+
+	var held []chan struct{}
+	release := func() {
+		for _, sem := range held {
+			<-sem
+		}
+	}
+	start := time.Now()
+	queued := false
+	var timeout <-chan time.Time
+	defer func() {
+		if queued {
+			l.mu.Lock()
+			l.waiting--
+			l.mu.Unlock()
+			CommandQueueDepth.Dec()
+		}
+		CommandQueueWait.WithLabelValues(c.Command).Observe(time.Since(start).Seconds())
+	}()
+	for _, sem := range sems {
+		if sem == nil {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+			held = append(held, sem)
+			continue
+		default:
+		}
+		if !queued {
+			l.mu.Lock()
+			full := l.limits.MaxQueue > 0 && l.waiting >= l.limits.MaxQueue
+			if !full {
+				l.waiting++
+			}
+			l.mu.Unlock()
+			if full {
+				release()
+				return nil, fmt.Errorf("%s: %w", c.Command, ErrQueueFull)
+			}
+			queued = true
+			CommandQueueDepth.Inc()
+			if l.limits.QueueTimeout > 0 {
+				t := time.NewTimer(l.limits.QueueTimeout)
+				defer t.Stop()
+				timeout = t.C
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+			held = append(held, sem)
+		case <-timeout:
+			release()
+			return nil, fmt.Errorf("%s: %w", c.Command, ErrQueueTimeout)
+		case <-ctx.Done():
+			release()
+			return nil, fmt.Errorf("%s: waiting to run: %w", c.Command, ctx.Err())
+		}
+	}
+	return release, nil
+}
+
+// acquire waits until the command may run under the process's concurrency
+// limits, returning a function to call when it is done.
+func (c *Command) acquire(ctx context.Context) (func(), error) {
+	l := activeLimiter.Load()
+	if l == nil {
+		return func() {}, nil
+	}
+	return l.acquire(ctx, c)
+}
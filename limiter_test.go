@@ -0,0 +1,179 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// blockingExecutor blocks each command until unblock is closed or its
+// context is done, recording the number of commands run and the most running
+// at once, in total and of each command. The name of each command is sent on
+// started when it starts running.
+type blockingExecutor struct {
+	unblock chan struct{}
+	started chan string
+	stdout  []byte
+	exit    int
+	mu      sync.Mutex
+	calls   int
+	running map[string]int
+	total   int
+	max     int
+	maxEach map[string]int
+}
+
+func newBlockingExecutor() *blockingExecutor {
+	return &blockingExecutor{
+		unblock: make(chan struct{}),
+		started: make(chan string, 100),
+		running: make(map[string]int),
+		maxEach: make(map[string]int),
+	}
+}
+
+func (b *blockingExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error) {
+	b.mu.Lock()
+	b.calls++
+	b.total++
+	b.running[name]++
+	b.max = max(b.max, b.total)
+	b.maxEach[name] = max(b.maxEach[name], b.running[name])
+	b.mu.Unlock()
+	b.started <- name
+	defer func() {
+		b.mu.Lock()
+		b.total--
+		b.running[name]--
+		b.mu.Unlock()
+	}()
+	select {
+	case <-b.unblock:
+		return &Result{Stdout: b.stdout, ExitCode: b.exit}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// waitFor waits until cond is true, failing the test if it takes
+// unreasonably long.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// queueDepth waits until n commands are waiting to run.
+func queueDepth(t *testing.T, n int) {
+	t.Helper()
+	waitFor(t, "queued commands", func() bool {
+		var m dto.Metric
+		if err := CommandQueueDepth.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetGauge().GetValue() == float64(n)
+	})
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	t.Cleanup(func() { SetConcurrencyLimits(ConcurrencyLimits{}) })
+	ctx := context.Background()
+	SetConcurrencyLimits(ConcurrencyLimits{Global: 3, PerSchedd: 1})
+
+	blocking := newBlockingExecutor()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := NewCommand("condor_status").WithExecutor(blocking).RunWithContext(ctx)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := NewSchedd("", "schedd1").WithExecutor(blocking).Query(ctx, JobSelection{})
+			errs <- err
+		}()
+	}
+	// one condor_q and two condor_status run, and the rest wait
+	for i := 0; i < 3; i++ {
+		<-blocking.started
+	}
+	queueDepth(t, 5)
+	close(blocking.unblock)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if blocking.calls != 8 {
+		t.Errorf("expected 8 commands run, got %d", blocking.calls)
+	}
+	if blocking.max != 3 {
+		t.Errorf("expected at most 3 commands running at once, got %d", blocking.max)
+	}
+	if n := blocking.maxEach["condor_q"]; n != 1 {
+		t.Errorf("expected at most 1 condor_q running at once, got %d", n)
+	}
+}
+
+func TestConcurrencyLimitsQueue(t *testing.T) {
+	t.Cleanup(func() { SetConcurrencyLimits(ConcurrencyLimits{}) })
+	ctx := context.Background()
+	SetConcurrencyLimits(ConcurrencyLimits{
+		Pools:    map[string]int{"busy.example.com": 1},
+		MaxQueue: 1,
+	})
+	busy := NewCommand("condor_status").WithPool("busy.example.com")
+
+	blocking := newBlockingExecutor()
+	defer close(blocking.unblock)
+	go busy.Copy().WithExecutor(blocking).RunWithContext(ctx)
+	<-blocking.started
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	queued := make(chan error)
+	go func() {
+		_, err := busy.Copy().WithExecutor(blocking).RunWithContext(waitCtx)
+		queued <- err
+	}()
+	queueDepth(t, 1)
+	if _, err := busy.Copy().WithExecutor(blocking).RunWithContext(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	cancel()
+	if err := <-queued; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// other pools are not limited
+	fake := &fakeExecutor{}
+	if _, err := NewCommand("condor_status").WithPool("idle.example.com").WithExecutor(fake).RunWithContext(ctx); err != nil {
+		t.Error(err)
+	}
+
+	SetConcurrencyLimits(ConcurrencyLimits{
+		Pools:        map[string]int{"busy.example.com": 1},
+		QueueTimeout: time.Millisecond,
+	})
+	blocking2 := newBlockingExecutor()
+	defer close(blocking2.unblock)
+	go busy.Copy().WithExecutor(blocking2).RunWithContext(ctx)
+	<-blocking2.started
+	if _, err := busy.Copy().WithExecutor(blocking2).RunWithContext(ctx); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("expected ErrQueueTimeout, got %v", err)
+	}
+}
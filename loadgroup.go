@@ -0,0 +1,86 @@
+package htcondor
+
+import (
+	"context"
+	"sync"
+)
+
+// loadGroup loads each key once at a time, sharing the value with every
+// caller that asks for the key while it is loading. Loads run independently
+// of any one caller's context: a caller that is cancelled returns its
+// context's error while the others keep waiting, and the load is only
+// cancelled once every caller has gone. The zero value is ready to use.
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// loadCall is a load running on behalf of one or more callers.
+type loadCall struct {
+	done    chan struct{}
+	value   []byte
+	err     error
+	callers int
+	cancel  context.CancelFunc
+}
+
+// do returns the value loaded for key by load, or by the load of key already
+// running, and whether it was shared with another caller.
+func (g *loadGroup) do(ctx context.Context, key string, load func(context.Context) ([]byte, error)) ([]byte, bool, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	call, shared := g.calls[key]
+	if shared {
+		call.callers++
+		g.mu.Unlock()
+	} else {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &loadCall{done: make(chan struct{}), callers: 1, cancel: cancel}
+		g.calls[key] = call
+		g.mu.Unlock()
+		go func() {
+			defer cancel()
+			call.value, call.err = load(loadCtx)
+			g.forget(key, call)
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+		return call.value, shared, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.callers--
+		last := call.callers == 0
+		g.mu.Unlock()
+		if last {
+			// nobody is waiting any more, so stop the load and don't let
+			// later callers join it
+			call.cancel()
+			g.forget(key, call)
+		}
+		return nil, shared, ctx.Err()
+	}
+}
+
+// forget removes call from the group, unless it has been replaced.
+func (g *loadGroup) forget(key string, call *loadCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
+
+// waiting returns the number of callers waiting for key to load.
+func (g *loadGroup) waiting(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call.callers
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package htcondor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+)
+
+// LRUCache is an in-process Cache, evicting the least recently used values
+// when full. Concurrent misses for the same key are loaded once, and the load
+// isn't cancelled while any of their callers is waiting.
+type LRUCache struct {
+	maxBytes int64
+	mu       sync.Mutex
+	lru      *lru.Cache
+	bytes    int64
+	loads    loadGroup
+}
+
+// lruEntry is a value in an LRUCache.
+type lruEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache returns an LRUCache holding up to maxBytes of values.
+func NewLRUCache(maxBytes int64) *LRUCache {
+	c := &LRUCache{
+		maxBytes: maxBytes,
+		lru:      lru.New(0),
+	}
+	c.lru.OnEvicted = func(key lru.Key, value interface{}) {
+		c.bytes -= int64(len(key.(string)) + len(value.(lruEntry).value))
+	}
+	return c
+}
+
+// Get returns the value for key, calling load on a miss.
+func (c *LRUCache) Get(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+	value, _, err := c.loads.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return value, c.Set(ctx, key, value, ttl)
+	})
+	return value, err
+}
+
+// get returns the value for key if it is cached and not expired.
+func (c *LRUCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.lru.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := v.(lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.lru.Remove(key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set caches value for key, evicting the least recently used values if the
+// cache is full. Values larger than the cache are not cached.
+func (c *LRUCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	size := int64(len(key) + len(value))
+	if size > c.maxBytes {
+		return nil
+	}
+	entry := lruEntry{value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Remove(key)
+	c.lru.Add(key, entry)
+	c.bytes += size
+	for c.bytes > c.maxBytes {
+		c.lru.RemoveOldest()
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package htcondor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPoolQueryConcurrency is the number of schedds queried at once by
+// Collector.QueryJobs if PoolQueryOptions.Concurrency is not set.
+const DefaultPoolQueryConcurrency = 8
+
+// PoolQueryOptions configures Collector.QueryJobs.
+type PoolQueryOptions struct {
+	// Constraint is a ClassAd expression that jobs must match.
+	Constraint string
+	// Attributes are the job attributes to return. If empty, all attributes
+	// are returned.
+	Attributes []string
+	// ScheddConstraint is a ClassAd expression that schedd ads must match
+	// for the schedd to be queried.
+	ScheddConstraint string
+	// Concurrency is the maximum number of schedds queried at once.
+	Concurrency int
+	// ScheddTimeout is the maximum time to query each schedd, if non-zero.
+	ScheddTimeout time.Duration
+}
+
+// PoolJob is a job ad from a pool-wide query, tagged with the schedd it came
+// from.
+type PoolJob struct {
+	Schedd ScheddAd
+	Job    JobAd
+}
+
+// ScheddError is an error querying one schedd in a pool-wide query.
+type ScheddError struct {
+	// Schedd is the name of the schedd.
+	Schedd string
+	Err    error
+}
+
+func (e *ScheddError) Error() string {
+	return fmt.Sprintf("error querying schedd %s: %s", e.Schedd, e.Err)
+}
+
+func (e *ScheddError) Unwrap() error {
+	return e.Err
+}
+
+// QueryJobs runs condor_q against every schedd in the pool, as found by a
+// collector query, and merges the results into a single stream. Schedds are
+// queried concurrently, busiest first. A schedd that fails or times out is
+// reported as a *ScheddError by the stream, without stopping the others.
+//
+// The stream must be closed when done.
+func (c *Collector) QueryJobs(ctx context.Context, opts PoolQueryOptions) (*PoolJobStream, error) {
+	schedds, err := c.Schedds(ctx, opts.ScheddConstraint, "Name", "ScheddIpAddr", "TotalRunningJobs")
+	if err != nil {
+		return nil, fmt.Errorf("error querying schedds: %w", err)
+	}
+	pool := c.cmd.Pool
+	return queryPool(ctx, schedds, opts, func(ctx context.Context, s ScheddAd) ([]JobAd, error) {
+		sd := s.Schedd(pool)
+		sd.cmd.opts = c.cmd.opts
+		return sd.Query(ctx, JobsMatching(opts.Constraint), opts.Attributes...)
+	}), nil
+}
+
+// scheddQueryFunc queries the jobs of one schedd.
+type scheddQueryFunc func(ctx context.Context, schedd ScheddAd) ([]JobAd, error)
+
+// queryPool runs query against each schedd with bounded concurrency.
+func queryPool(ctx context.Context, schedds []ScheddAd, opts PoolQueryOptions, query scheddQueryFunc) *PoolJobStream {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultPoolQueryConcurrency
+	}
+	schedds = append([]ScheddAd(nil), schedds...)
+	sort.SliceStable(schedds, func(i, j int) bool {
+		ri, _ := schedds[i].TotalRunningJobs()
+		rj, _ := schedds[j].TotalRunningJobs()
+		return ri > rj
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := PoolJobStream{
+		results: make(chan poolResult),
+		cancel:  cancel,
+		errors:  make([]*ScheddError, 0),
+	}
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, s := range schedds {
+		wg.Add(1)
+		go func(s ScheddAd) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				p.send(ctx, poolResult{err: &ScheddError{Schedd: s.Name(), Err: ctx.Err()}})
+				return
+			}
+			qctx := ctx
+			if opts.ScheddTimeout > 0 {
+				var qcancel context.CancelFunc
+				qctx, qcancel = context.WithTimeout(ctx, opts.ScheddTimeout)
+				defer qcancel()
+			}
+			jobs, err := query(qctx, s)
+			if err == nil && qctx.Err() != nil {
+				err = qctx.Err()
+			}
+			if err != nil {
+				p.send(ctx, poolResult{err: &ScheddError{Schedd: s.Name(), Err: err}})
+				return
+			}
+			for _, j := range jobs {
+				if !p.send(ctx, poolResult{job: PoolJob{Schedd: s, Job: j}}) {
+					return
+				}
+			}
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		close(p.results)
+	}()
+	return &p
+}
+
+type poolResult struct {
+	job PoolJob
+	err *ScheddError
+}
+
+// PoolJobStream is the merged stream of jobs from a pool-wide query.
+type PoolJobStream struct {
+	results chan poolResult
+	cancel  context.CancelFunc
+	errors  []*ScheddError
+	done    bool
+}
+
+// send sends a result, returning false if the stream was closed.
+func (p *PoolJobStream) send(ctx context.Context, r poolResult) bool {
+	select {
+	case p.results <- r:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Next returns the next job, or io.EOF when every schedd has been queried. A
+// schedd that could not be queried is returned as a *ScheddError, and Next may
+// be called again to continue with the other schedds.
+func (p *PoolJobStream) Next() (PoolJob, error) {
+	if p.done {
+		return PoolJob{}, io.EOF
+	}
+	r, ok := <-p.results
+	if !ok {
+		p.done = true
+		return PoolJob{}, io.EOF
+	}
+	if r.err != nil {
+		p.errors = append(p.errors, r.err)
+		return PoolJob{}, r.err
+	}
+	return r.job, nil
+}
+
+// ReadAll returns all the remaining jobs, along with the errors for any schedds
+// that could not be queried.
+func (p *PoolJobStream) ReadAll() ([]PoolJob, []*ScheddError) {
+	jobs := make([]PoolJob, 0)
+	for {
+		j, err := p.Next()
+		if err == io.EOF {
+			return jobs, p.Errors()
+		} else if err != nil {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+}
+
+// Errors returns the schedd errors returned so far.
+func (p *PoolJobStream) Errors() []*ScheddError {
+	return p.errors
+}
+
+// Close stops any queries still running.
+func (p *PoolJobStream) Close() error {
+	p.cancel()
+	if !p.done {
+		for range p.results {
+		}
+		p.done = true
+	}
+	return nil
+}
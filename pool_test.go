@@ -0,0 +1,115 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func scheddAds(names ...string) []ScheddAd {
+	ads := make([]ScheddAd, len(names))
+	for i, n := range names {
+		ads[i] = ScheddAd{
+			"Name":             {Type: classad.String, Value: n},
+			"TotalRunningJobs": {Type: classad.Integer, Value: int64(i)},
+		}
+	}
+	return ads
+}
+
+func TestQueryPool(t *testing.T) {
+	var running, maxRunning int32
+	query := func(ctx context.Context, s ScheddAd) ([]JobAd, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		switch s.Name() {
+		case "broken":
+			return nil, errors.New("connection refused")
+		case "slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		time.Sleep(10 * time.Millisecond)
+		return []JobAd{
+			{"ClusterId": {Type: classad.Integer, Value: int64(1)}, "ProcId": {Type: classad.Integer, Value: int64(0)}},
+			{"ClusterId": {Type: classad.Integer, Value: int64(1)}, "ProcId": {Type: classad.Integer, Value: int64(1)}},
+		}, nil
+	}
+	schedds := scheddAds("a", "b", "broken", "c", "slow", "d")
+	p := queryPool(context.Background(), schedds, PoolQueryOptions{
+		Concurrency:   2,
+		ScheddTimeout: 50 * time.Millisecond,
+	}, query)
+	defer p.Close()
+	jobs, errs := p.ReadAll()
+	if len(jobs) != 8 {
+		t.Errorf("expected 8 jobs, got %d", len(jobs))
+	}
+	names := make([]string, 0)
+	for _, j := range jobs {
+		if j.Job == nil {
+			t.Error("missing job ad")
+		}
+		names = append(names, j.Schedd.Name())
+	}
+	sort.Strings(names)
+	if names[0] != "a" || names[7] != "d" {
+		t.Errorf("unexpected schedds %v", names)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	failed := map[string]error{}
+	for _, e := range errs {
+		failed[e.Schedd] = e
+	}
+	if !errors.Is(failed["slow"], context.DeadlineExceeded) {
+		t.Errorf("expected timeout for slow schedd, got %v", failed["slow"])
+	}
+	if failed["broken"] == nil {
+		t.Error("expected error for broken schedd")
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 concurrent queries, got %d", maxRunning)
+	}
+}
+
+func TestQueryPool_close(t *testing.T) {
+	query := func(ctx context.Context, s ScheddAd) ([]JobAd, error) {
+		return []JobAd{{}, {}, {}}, nil
+	}
+	p := queryPool(context.Background(), scheddAds("a", "b", "c"), PoolQueryOptions{}, query)
+	if _, err := p.Next(); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+}
+
+func TestCondorCollectorQueryJobs(t *testing.T) {
+	p, err := NewCollector("").QueryJobs(context.Background(), PoolQueryOptions{
+		Attributes:    []string{"ClusterId", "ProcId"},
+		ScheddTimeout: 10 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	jobs, errs := p.ReadAll()
+	for _, e := range errs {
+		t.Error(e)
+	}
+	for _, j := range jobs {
+		t.Log(j.Schedd.Name(), j.Job)
+	}
+}
@@ -0,0 +1,152 @@
+package htcondor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// DefaultRetryableErrors are the errors retried by a RetryPolicy that doesn't
+// set Retryable: transient failures to reach the daemon.
+var DefaultRetryableErrors = []error{ErrDaemonUnreachable, ErrTimeout}
+
+// DefaultRetryPolicy makes up to three attempts, one second and then two
+// seconds apart (give or take 20%).
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// RetryPolicy configures retrying failed commands, with exponential backoff
+// between attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times to run the command,
+	// including the first. Values less than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to one
+	// second.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the delay between attempts. Zero means no limit.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay increases by after each retry.
+	// Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction of it, e.g. 0.2
+	// for ±20%, so that clients don't retry in lockstep.
+	Jitter float64
+	// Retryable are the errors that are retried, matched with errors.Is.
+	// Defaults to DefaultRetryableErrors.
+	Retryable []error
+
+	// exclude are errors that are never retried, even if they match
+	// Retryable.
+	exclude []error
+}
+
+// WithRetry sets the policy for retrying the command when it fails, e.g.
+// WithRetry(DefaultRetryPolicy). Commands are only retried while the context
+// is not done. Stream does not retry, since output may already have been
+// sent, and Schedd.Submit does not retry, since a submission that timed out
+// may have succeeded. Likewise the job actions (Schedd.Remove, Hold, Edit,
+// etc.) do not retry timeouts.
+func (c *Command) WithRetry(policy RetryPolicy) *Command {
+	c.opts.retry = &policy
+	return c
+}
+
+// shouldRetry returns true if the command should be retried after the given
+// attempt (counting from 1) failed with err.
+func (p *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	for _, target := range p.exclude {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryableErrors
+	}
+	for _, target := range retryable {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns a copy of the policy that doesn't retry errors matching
+// errs, or nil if p is nil.
+func (p *RetryPolicy) without(errs ...error) *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	q := *p
+	q.exclude = append(slices.Clip(p.exclude), errs...)
+	return &q
+}
+
+// backoff returns the delay before the given retry (counting from 1).
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff)
+	if d <= 0 {
+		d = float64(time.Second)
+	}
+	m := p.Multiplier
+	if m <= 0 {
+		m = 2
+	}
+	d *= math.Pow(m, float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// execute runs the command with the given arguments using its executor,
+// retrying according to its retry policy. If the command fails, a
+// *CommandError is returned along with the result.
+func (c *Command) execute(ctx context.Context, args []string, stdin io.Reader) (*Result, error) {
+	retry := c.opts.retry
+	if retry == nil || retry.MaxAttempts < 2 {
+		return c.executeOnce(ctx, args, stdin)
+	}
+	var input []byte
+	if stdin != nil {
+		var err error
+		if input, err = io.ReadAll(stdin); err != nil {
+			return &Result{}, fmt.Errorf("error reading stdin: %w", err)
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		if input != nil {
+			stdin = bytes.NewReader(input)
+		}
+		res, err := c.executeOnce(ctx, args, stdin)
+		if err == nil || ctx.Err() != nil || !retry.shouldRetry(attempt, err) {
+			return res, err
+		}
+		CommandRetries.WithLabelValues(c.Command).Inc()
+		t := time.NewTimer(retry.backoff(attempt))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return res, err
+		}
+	}
+}
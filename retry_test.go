@@ -0,0 +1,192 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retzkek/htcondor-go/classad"
+	"github.com/retzkek/htcondor-go/submit"
+)
+
+// flakyExecutor fails with the given stderr until it has been run failures
+// times, then succeeds.
+type flakyExecutor struct {
+	failures int
+	stderr   string
+	calls    int
+	stdin    []string
+}
+
+func (f *flakyExecutor) Run(ctx context.Context, name string, args []string, stdin io.Reader) (*Result, error) {
+	f.calls++
+	if stdin != nil {
+		in, _ := io.ReadAll(stdin)
+		f.stdin = append(f.stdin, string(in))
+	}
+	if f.calls <= f.failures {
+		return &Result{Stderr: []byte(f.stderr), ExitCode: 1}, nil
+	}
+	return &Result{Stdout: []byte("Name = \"slot1@host\"\n")}, nil
+}
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	Jitter:         0.5,
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	unreachable := "CEDAR:6001:Failed to connect to <10.0.0.1:9618>"
+
+	flaky := &flakyExecutor{failures: 2, stderr: unreachable}
+	ads, err := NewCommand("condor_status").WithExecutor(flaky).WithRetry(testRetryPolicy).RunWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || flaky.calls != 3 {
+		t.Errorf("expected success on third attempt, got %d ads after %d calls", len(ads), flaky.calls)
+	}
+
+	flaky = &flakyExecutor{failures: 3, stderr: unreachable}
+	_, err = NewCommand("condor_status").WithExecutor(flaky).WithRetry(testRetryPolicy).RunWithContext(ctx)
+	if !errors.Is(err, ErrDaemonUnreachable) || flaky.calls != 3 {
+		t.Errorf("expected failure after 3 attempts, got %v after %d calls", err, flaky.calls)
+	}
+
+	flaky = &flakyExecutor{failures: 1, stderr: "Error: Parse error of constraint expression"}
+	_, err = NewCommand("condor_status").WithExecutor(flaky).WithRetry(testRetryPolicy).RunWithContext(ctx)
+	if !errors.Is(err, ErrConstraintParse) || flaky.calls != 1 {
+		t.Errorf("expected no retry of constraint error, got %v after %d calls", err, flaky.calls)
+	}
+
+	// stdin is sent again on each attempt
+	flaky = &flakyExecutor{failures: 1, stderr: unreachable}
+	cmd := NewCommand("condor_advertise").WithExecutor(flaky).WithRetry(testRetryPolicy)
+	if _, _, err := cmd.runOutput(ctx, nil, strings.NewReader("MyType = \"Test\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(flaky.stdin) != 2 || flaky.stdin[1] != "MyType = \"Test\"\n" {
+		t.Errorf("expected stdin on both attempts, got %q", flaky.stdin)
+	}
+
+	// submissions are not retried
+	flaky = &flakyExecutor{failures: 1, stderr: unreachable}
+	schedd := NewSchedd("", "")
+	schedd.cmd.WithExecutor(flaky).WithRetry(testRetryPolicy)
+	if _, err := schedd.Submit(ctx, submit.New().Set("executable", "/bin/true").Queue(submit.QueueN(1)), SubmitOptions{}); err == nil || flaky.calls != 1 {
+		t.Errorf("expected submit to fail without retrying, got %v after %d calls", err, flaky.calls)
+	}
+	// job actions retry unreachable schedds, but not timeouts
+	actions := map[string]func(*Schedd) error{
+		"hold": func(s *Schedd) error {
+			_, err := s.Hold(ctx, Jobs(JobID{Cluster: 1, Proc: 0}), "")
+			return err
+		},
+		"edit": func(s *Schedd) error {
+			_, err := s.Edit(ctx, Jobs(JobID{Cluster: 1, Proc: 0}), "Foo", classad.Attribute{Type: classad.Integer, Value: int64(1)})
+			return err
+		},
+	}
+	for name, action := range actions {
+		for stderr, calls := range map[string]int{unreachable: 2, "SECMAN:2007:Timed out waiting for response": 1} {
+			flaky = &flakyExecutor{failures: 1, stderr: stderr}
+			schedd := NewSchedd("", "")
+			schedd.cmd.WithExecutor(flaky).WithRetry(testRetryPolicy)
+			action(schedd)
+			if flaky.calls != calls {
+				t.Errorf("%s %q: expected %d calls, got %d", name, stderr, calls, flaky.calls)
+			}
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if d := p.backoff(i + 1); d != e {
+			t.Errorf("retry %d: expected %s, got %s", i+1, e, d)
+		}
+	}
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("backoff %s outside jitter range", d)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	pool := "breaker-test.example.com"
+	policy := CircuitBreakerPolicy{Threshold: 2, Cooldown: 20 * time.Millisecond}
+	flaky := &flakyExecutor{failures: 3, stderr: "Error: Couldn't contact the condor_collector on " + pool + "."}
+	cmd := NewCommand("condor_status").WithPool(pool).WithExecutor(flaky).WithCircuitBreaker(policy)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cmd.RunWithContext(ctx); !errors.Is(err, ErrDaemonUnreachable) {
+			t.Fatalf("expected ErrDaemonUnreachable, got %v", err)
+		}
+	}
+	if s := PoolCircuitState(pool); s != CircuitOpen {
+		t.Fatalf("expected open breaker, got %s", s)
+	}
+	if _, err := cmd.RunWithContext(ctx); !errors.Is(err, ErrCircuitOpen) || flaky.calls != 2 {
+		t.Errorf("expected ErrCircuitOpen without running, got %v after %d calls", err, flaky.calls)
+	}
+	// other pools are unaffected
+	if s := PoolCircuitState("other.example.com"); s != CircuitClosed {
+		t.Errorf("expected closed breaker for other pool, got %s", s)
+	}
+
+	// a failed probe opens the breaker again
+	time.Sleep(policy.Cooldown)
+	if _, err := cmd.RunWithContext(ctx); !errors.Is(err, ErrDaemonUnreachable) || flaky.calls != 3 {
+		t.Errorf("expected failed probe, got %v after %d calls", err, flaky.calls)
+	}
+	if s := PoolCircuitState(pool); s != CircuitOpen {
+		t.Fatalf("expected open breaker after failed probe, got %s", s)
+	}
+
+	// a successful probe closes it
+	time.Sleep(policy.Cooldown)
+	if _, err := cmd.RunWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := PoolCircuitState(pool); s != CircuitClosed {
+		t.Errorf("expected closed breaker after successful probe, got %s", s)
+	}
+	// unreachable schedds open their own breakers, not the pool's
+	pool = "breaker-schedds.example.com"
+	t.Cleanup(func() {
+		breakersMu.Lock()
+		defer breakersMu.Unlock()
+		delete(breakers, scheddBreakerKey(pool, "dead"))
+	})
+	dead := &flakyExecutor{failures: 3, stderr: "CEDAR:6001:Failed to connect to <10.0.0.2:9618>"}
+	deadSchedd := NewSchedd(pool, "dead").WithExecutor(dead)
+	deadSchedd.cmd.WithCircuitBreaker(policy)
+	for i := 0; i < 3; i++ {
+		deadSchedd.Query(ctx, JobSelection{})
+	}
+	if dead.calls != 2 {
+		t.Errorf("expected the dead schedd's breaker to open after 2 calls, got %d", dead.calls)
+	}
+	if s := ScheddCircuitState(pool, "dead"); s != CircuitOpen {
+		t.Errorf("expected open breaker for dead schedd, got %s", s)
+	}
+	if s := PoolCircuitState(pool); s != CircuitClosed {
+		t.Errorf("expected closed breaker for pool, got %s", s)
+	}
+	alive := &flakyExecutor{}
+	schedd := NewSchedd(pool, "alive").WithExecutor(alive)
+	schedd.cmd.WithCircuitBreaker(policy)
+	if _, err := schedd.Query(ctx, JobSelection{}); err != nil {
+		t.Errorf("expected query of live schedd to succeed, got %v", err)
+	}
+}
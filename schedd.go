@@ -0,0 +1,344 @@
+package htcondor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+// Schedd is a client for a schedd, for querying and acting on its jobs with
+// the HTCondor command-line tools (condor_q, condor_rm, condor_hold, etc.).
+type Schedd struct {
+	// cmd holds the -pool and -name arguments shared by all commands.
+	cmd *Command
+}
+
+// NewSchedd creates a client for the named schedd in pool. Either may be
+// empty to use the local pool or schedd.
+func NewSchedd(pool, name string) *Schedd {
+	return &Schedd{
+		cmd: NewCommand("").WithPool(pool).WithName(name),
+	}
+}
+
+// Command returns a new Command for the schedd, e.g. to run condor_q with
+// options not covered by Query.
+func (s *Schedd) Command(command string) *Command {
+	c := s.cmd.Copy()
+	c.Command = command
+	return c
+}
+
+// WithExecutor sets the executor used to run the schedd's commands, e.g. a
+// fake for testing.
+func (s *Schedd) WithExecutor(e Executor) *Schedd {
+	s.cmd.WithExecutor(e)
+	return s
+}
+
+// JobSelection selects the jobs a Schedd method applies to, by ID, by
+// constraint, or both (in which case jobs must match both).
+type JobSelection struct {
+	IDs        []JobID
+	Constraint string
+}
+
+// Jobs selects jobs by ID. Cluster IDs select every job in the cluster.
+func Jobs(ids ...JobID) JobSelection {
+	return JobSelection{IDs: ids}
+}
+
+// JobsMatching selects jobs matching a constraint.
+func JobsMatching(constraint string) JobSelection {
+	return JobSelection{Constraint: constraint}
+}
+
+// IsEmpty returns true if no jobs are selected.
+func (j JobSelection) IsEmpty() bool {
+	return len(j.IDs) == 0 && j.Constraint == ""
+}
+
+// args returns the arguments selecting the jobs. Job IDs are passed as
+// arguments on their own; combined with a constraint they are converted to
+// a single constraint, since the tools don't accept both.
+func (j JobSelection) args() []string {
+	switch {
+	case len(j.IDs) > 0 && j.Constraint != "":
+		return []string{"-constraint", "(" + JobIDsConstraint(j.IDs...) + ") && (" + j.Constraint + ")"}
+	case j.Constraint != "":
+		return []string{"-constraint", j.Constraint}
+	}
+	return JobIDArgs(j.IDs...)
+}
+
+// editArgs returns the arguments selecting the jobs for condor_qedit, which
+// only accepts a single job or cluster ID, so multiple IDs are converted to a
+// constraint.
+func (j JobSelection) editArgs() []string {
+	if len(j.IDs) > 1 && j.Constraint == "" {
+		return []string{"-constraint", JobIDsConstraint(j.IDs...)}
+	}
+	return j.args()
+}
+
+// Query returns the ads of the selected jobs, or of every job condor_q shows
+// by default if the selection is empty. If attributes are given, only those
+// attributes are returned.
+func (s *Schedd) Query(ctx context.Context, jobs JobSelection, attributes ...string) ([]JobAd, error) {
+	cmd := s.Command("condor_q")
+	cmd.Args = jobs.args()
+	for _, a := range attributes {
+		cmd.WithAttribute(a)
+	}
+	ads, err := cmd.RunWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	jobAds := make([]JobAd, len(ads))
+	for i, ad := range ads {
+		jobAds[i] = JobAd(ad)
+	}
+	return jobAds, nil
+}
+
+// jobAction describes a job management tool and how it reports success.
+type jobAction struct {
+	command string
+	// done is the message reported for each job that was acted on, e.g.
+	// "marked for removal".
+	done string
+}
+
+var (
+	removeAction   = jobAction{"condor_rm", "marked for removal"}
+	holdAction     = jobAction{"condor_hold", "held"}
+	releaseAction  = jobAction{"condor_release", "released"}
+	vacateAction   = jobAction{"condor_vacate_job", "vacated"}
+	suspendAction  = jobAction{"condor_suspend", "suspended"}
+	continueAction = jobAction{"condor_continue", "continued"}
+)
+
+// Remove removes the selected jobs from the queue.
+func (s *Schedd) Remove(ctx context.Context, jobs JobSelection) ([]JobActionResult, error) {
+	return s.act(ctx, removeAction, jobs)
+}
+
+// Hold puts the selected jobs on hold. The reason is optional.
+func (s *Schedd) Hold(ctx context.Context, jobs JobSelection, reason string) ([]JobActionResult, error) {
+	if reason != "" {
+		return s.act(ctx, holdAction, jobs, "-reason", reason)
+	}
+	return s.act(ctx, holdAction, jobs)
+}
+
+// Release releases the selected jobs from hold.
+func (s *Schedd) Release(ctx context.Context, jobs JobSelection) ([]JobActionResult, error) {
+	return s.act(ctx, releaseAction, jobs)
+}
+
+// Vacate evicts the selected jobs from the machines they are running on,
+// returning them to idle.
+func (s *Schedd) Vacate(ctx context.Context, jobs JobSelection) ([]JobActionResult, error) {
+	return s.act(ctx, vacateAction, jobs)
+}
+
+// Suspend suspends the selected running jobs.
+func (s *Schedd) Suspend(ctx context.Context, jobs JobSelection) ([]JobActionResult, error) {
+	return s.act(ctx, suspendAction, jobs)
+}
+
+// Continue resumes the selected suspended jobs.
+func (s *Schedd) Continue(ctx context.Context, jobs JobSelection) ([]JobActionResult, error) {
+	return s.act(ctx, continueAction, jobs)
+}
+
+// Edit sets an attribute of the selected jobs with condor_qedit, and returns
+// the number of jobs modified. The value is written in ClassAd syntax, so
+// String values are quoted and Expression values are not.
+func (s *Schedd) Edit(ctx context.Context, jobs JobSelection, name string, value classad.Attribute) (int, error) {
+	if jobs.IsEmpty() {
+		return 0, fmt.Errorf("condor_qedit: no jobs selected")
+	}
+	if name == "" || strings.ContainsAny(name, " \t=") {
+		return 0, fmt.Errorf("condor_qedit: invalid attribute name \"%s\"", name)
+	}
+	cmd := s.Command("condor_qedit")
+	// an edit that timed out may have been applied, and the jobs it selected
+	// may have changed since
+	cmd.opts.retry = cmd.opts.retry.without(ErrTimeout)
+	args := append(cmd.targetArgs(), jobs.editArgs()...)
+	args = append(args, name, value.Unparse())
+	stdout, stderr, err := cmd.runOutput(ctx, args, nil)
+	n := parseQeditOutput(stdout)
+	if err != nil {
+		return n, &JobActionError{
+			Command: "condor_qedit",
+			Stderr:  strings.TrimSpace(string(stderr)),
+			Err:     err,
+		}
+	}
+	return n, nil
+}
+
+// act runs a job management tool against the selected jobs and parses the
+// per-job results from its output.
+func (s *Schedd) act(ctx context.Context, action jobAction, jobs JobSelection, extraArgs ...string) ([]JobActionResult, error) {
+	if jobs.IsEmpty() {
+		return nil, fmt.Errorf("%s: no jobs selected", action.command)
+	}
+	cmd := s.Command(action.command)
+	// an action that timed out may have been applied, and repeating it
+	// would fail, e.g. holding a job that is already held
+	cmd.opts.retry = cmd.opts.retry.without(ErrTimeout)
+	args := append(cmd.targetArgs(), extraArgs...)
+	args = append(args, jobs.args()...)
+	stdout, stderr, err := cmd.runOutput(ctx, args, nil)
+	results := parseJobActionOutput(action, append(stdout, stderr...))
+	failed := make([]JobActionResult, 0)
+	for _, r := range results {
+		if !r.OK {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 || err != nil {
+		return results, &JobActionError{
+			Command: action.command,
+			Failed:  failed,
+			Stderr:  strings.TrimSpace(string(stderr)),
+			Err:     err,
+		}
+	}
+	return results, nil
+}
+
+// JobActionResult is the outcome of a job management action for one job,
+// cluster or constraint, as reported by the tool.
+type JobActionResult struct {
+	// JobID is the job, or the cluster if Proc is negative. It is zero for
+	// results that apply to a constraint.
+	JobID JobID
+	// Constraint is set for results that apply to a constraint.
+	Constraint string
+	// OK is true if the action succeeded.
+	OK bool
+	// Message is the message reported by the tool.
+	Message string
+}
+
+// JobActionError is returned when a job management tool fails for some or all
+// of the selected jobs.
+type JobActionError struct {
+	// Command is the tool that was run, e.g. condor_rm.
+	Command string
+	// Failed are the results for the jobs the action failed for.
+	Failed []JobActionResult
+	// Stderr is the standard error output of the tool.
+	Stderr string
+	// Err is the error from running the tool, if it exited with an error.
+	Err error
+}
+
+func (e *JobActionError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, r := range e.Failed {
+		msgs = append(msgs, r.Message)
+	}
+	if len(msgs) == 0 && e.Stderr != "" {
+		msgs = append(msgs, e.Stderr)
+	}
+	if len(msgs) == 0 && e.Err != nil {
+		msgs = append(msgs, e.Err.Error())
+	}
+	return fmt.Sprintf("%s failed: %s", e.Command, strings.Join(msgs, "; "))
+}
+
+func (e *JobActionError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	jobResultRegexp            = regexp.MustCompile(`^Job (\d+)\.(\d+) (.*)$`)
+	clusterResultRegexp        = regexp.MustCompile(`^(?:All jobs in c|C)luster (\d+) (.*)$`)
+	constraintResultRegexp     = regexp.MustCompile(`^All jobs (?:matching|matched by) constraint \((.*)\) (.*)$`)
+	clusterFailureRegexp       = regexp.MustCompile(`^Couldn't find/\S+ all jobs in cluster (\d+)`)
+	constraintFailureRegexp    = regexp.MustCompile(`^Couldn't find/\S+ all jobs matching constraint \((.*)\)`)
+	resultMessagePrefixRegexp  = regexp.MustCompile(`^(?:has been|have been|was|were) `)
+	resultMessageTrailerRegexp = regexp.MustCompile(`\.$`)
+	qeditResultRegexp          = regexp.MustCompile(`^Set attribute "?[^" ]+"?(?: for (\d+) matching jobs?)?`)
+)
+
+// parseJobActionOutput parses the per-job messages printed by condor_rm and
+// friends, e.g. "Job 42.0 marked for removal" or "Job 42.1 not found".
+func parseJobActionOutput(action jobAction, out []byte) []JobActionResult {
+	results := make([]JobActionResult, 0)
+	done := func(msg string) bool {
+		msg = resultMessagePrefixRegexp.ReplaceAllString(msg, "")
+		msg = resultMessageTrailerRegexp.ReplaceAllString(msg, "")
+		return msg == action.done
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := jobResultRegexp.FindStringSubmatch(line); m != nil {
+			cluster, _ := strconv.ParseInt(m[1], 10, 64)
+			proc, _ := strconv.ParseInt(m[2], 10, 64)
+			results = append(results, JobActionResult{
+				JobID:   JobID{Cluster: cluster, Proc: proc},
+				OK:      done(m[3]),
+				Message: line,
+			})
+		} else if m := clusterResultRegexp.FindStringSubmatch(line); m != nil {
+			cluster, _ := strconv.ParseInt(m[1], 10, 64)
+			results = append(results, JobActionResult{
+				JobID:   ClusterID(cluster),
+				OK:      done(m[2]),
+				Message: line,
+			})
+		} else if m := clusterFailureRegexp.FindStringSubmatch(line); m != nil {
+			cluster, _ := strconv.ParseInt(m[1], 10, 64)
+			results = append(results, JobActionResult{
+				JobID:   ClusterID(cluster),
+				Message: line,
+			})
+		} else if m := constraintFailureRegexp.FindStringSubmatch(line); m != nil {
+			results = append(results, JobActionResult{
+				Constraint: m[1],
+				Message:    line,
+			})
+		} else if m := constraintResultRegexp.FindStringSubmatch(line); m != nil {
+			results = append(results, JobActionResult{
+				Constraint: m[1],
+				OK:         done(m[2]),
+				Message:    line,
+			})
+		}
+	}
+	return results
+}
+
+// parseQeditOutput returns the number of jobs condor_qedit reports modifying,
+// e.g. "Set attribute "RequestMemory" for 3 matching jobs." Older versions
+// print a line per job without a count.
+func parseQeditOutput(out []byte) int {
+	n := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := qeditResultRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		if m[1] == "" {
+			n++
+			continue
+		}
+		c, _ := strconv.Atoi(m[1])
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,138 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/classad"
+)
+
+func TestJobSelectionArgs(t *testing.T) {
+	testCases := []struct {
+		jobs     JobSelection
+		expected []string
+	}{
+		{Jobs(JobID{42, 0}, ClusterID(43)), []string{"42.0", "43"}},
+		{JobsMatching(`Owner == "alice"`), []string{"-constraint", `Owner == "alice"`}},
+		{JobSelection{IDs: []JobID{{42, 0}}, Constraint: "JobStatus == 5"},
+			[]string{"-constraint", "((ClusterId == 42 && ProcId == 0)) && (JobStatus == 5)"}},
+	}
+	for _, tc := range testCases {
+		if args := tc.jobs.args(); !reflect.DeepEqual(args, tc.expected) {
+			t.Errorf("expected %q, got %q", tc.expected, args)
+		}
+	}
+}
+
+func TestParseJobActionOutput(t *testing.T) {
+	out := `Job 42.0 marked for removal
+Job 42.1 not found
+Cluster 43 has been marked for removal.
+All jobs in cluster 45 have been marked for removal
+Couldn't find/remove all jobs in cluster 44.
+All jobs matching constraint (Owner == "alice") have been marked for removal
+Couldn't find/remove all jobs matching constraint (Owner == "bob")
+`
+	results := parseJobActionOutput(removeAction, []byte(out))
+	expected := []JobActionResult{
+		{JobID: JobID{42, 0}, OK: true, Message: "Job 42.0 marked for removal"},
+		{JobID: JobID{42, 1}, Message: "Job 42.1 not found"},
+		{JobID: ClusterID(43), OK: true, Message: "Cluster 43 has been marked for removal."},
+		{JobID: ClusterID(45), OK: true, Message: "All jobs in cluster 45 have been marked for removal"},
+		{JobID: ClusterID(44), Message: "Couldn't find/remove all jobs in cluster 44."},
+		{Constraint: `Owner == "alice"`, OK: true, Message: `All jobs matching constraint (Owner == "alice") have been marked for removal`},
+		{Constraint: `Owner == "bob"`, Message: `Couldn't find/remove all jobs matching constraint (Owner == "bob")`},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+
+	results = parseJobActionOutput(holdAction, []byte("Job 42.0 held\nJob 42.1 already held\n"))
+	if len(results) != 2 || !results[0].OK || results[1].OK {
+		t.Errorf("unexpected hold results %+v", results)
+	}
+}
+
+func TestJobSelectionEditArgs(t *testing.T) {
+	tests := []struct {
+		jobs     JobSelection
+		expected []string
+	}{
+		{Jobs(JobID{42, 1}), []string{"42.1"}},
+		{Jobs(JobID{42, 1}, JobID{42, 2}), []string{"-constraint", "(ClusterId == 42 && ProcId >= 1 && ProcId <= 2)"}},
+		{JobsMatching(`Owner == "alice"`), []string{"-constraint", `Owner == "alice"`}},
+	}
+	for _, tt := range tests {
+		if args := tt.jobs.editArgs(); !reflect.DeepEqual(args, tt.expected) {
+			t.Errorf("expected %q, got %q", tt.expected, args)
+		}
+	}
+}
+
+func TestParseQeditOutput(t *testing.T) {
+	tests := []struct {
+		out      string
+		expected int
+	}{
+		{"Set attribute \"RequestMemory\" for 3 matching jobs.\n", 3},
+		{"Set attribute \"RequestMemory\" for 1 matching job.\n", 1},
+		{"Set attribute \"Foo\".\nSet attribute \"Foo\".\n", 2},
+		{"Failed to set attribute \"Foo\" by constraint: false\n", 0},
+	}
+	for _, tt := range tests {
+		if n := parseQeditOutput([]byte(tt.out)); n != tt.expected {
+			t.Errorf("%q: expected %d, got %d", tt.out, tt.expected, n)
+		}
+	}
+}
+
+func TestScheddEdit_bad(t *testing.T) {
+	s := NewSchedd("", "")
+	if _, err := s.Edit(context.Background(), JobSelection{}, "Foo", classad.Attribute{Type: classad.Integer, Value: int64(1)}); err == nil {
+		t.Error("expected error for empty selection")
+	}
+	if _, err := s.Edit(context.Background(), Jobs(JobID{1, 0}), "Foo Bar", classad.Attribute{Type: classad.Integer, Value: int64(1)}); err == nil {
+		t.Error("expected error for invalid attribute name")
+	}
+}
+
+func TestScheddAct_noJobs(t *testing.T) {
+	if _, err := NewSchedd("", "").Remove(context.Background(), JobSelection{}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestJobActionError(t *testing.T) {
+	cause := errors.New("exit status 1")
+	err := error(&JobActionError{
+		Command: "condor_rm",
+		Failed:  []JobActionResult{{JobID: JobID{42, 1}, Message: "Job 42.1 not found"}},
+		Err:     cause,
+	})
+	if err.Error() != "condor_rm failed: Job 42.1 not found" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	var e *JobActionError
+	if !errors.As(err, &e) || !errors.Is(err, cause) {
+		t.Error("expected error chain to contain JobActionError and cause")
+	}
+}
+
+func TestCondorScheddQuery(t *testing.T) {
+	jobs, err := NewSchedd("", "").Query(context.Background(), JobSelection{}, "ClusterId", "ProcId", "JobStatus")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(jobs) != 1 {
+		t.Errorf("expected one job, got %d", len(jobs))
+	}
+	for _, j := range jobs {
+		id, err := j.JobID()
+		if err != nil {
+			t.Error(err)
+		}
+		t.Log(id)
+	}
+}
@@ -0,0 +1,181 @@
+package htcondor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/submit"
+)
+
+var (
+	// ErrSubmitRequirement is matched (with errors.Is) by a SubmitError when
+	// the submission was rejected by a schedd SUBMIT_REQUIREMENT.
+	ErrSubmitRequirement = errors.New("submit requirement not met")
+	// ErrSubmitSyntax is matched (with errors.Is) by a SubmitError when the
+	// submit description could not be parsed.
+	ErrSubmitSyntax = errors.New("invalid submit description")
+)
+
+var (
+	terseSubmitRegexp       = regexp.MustCompile(`^(\d+)\.(\d+) - (\d+)\.(\d+)$`)
+	submitErrorRegexp       = regexp.MustCompile(`^ERROR:?\s*(.*)$`)
+	submitRequirementRegexp = regexp.MustCompile(`(?i)submit requirement (\S+)`)
+	submitSyntaxRegexp      = regexp.MustCompile(`(?i)(on line \d+ of submit file|parse error|syntax error|unknown command)`)
+)
+
+// SubmitOptions are options for Schedd.Submit.
+type SubmitOptions struct {
+	// Spool transfers the input files to the schedd's spool directory, as
+	// needed when submitting to a remote schedd.
+	Spool bool
+	// BatchName sets the batch name of the jobs.
+	BatchName string
+	// Args are extra arguments to pass to condor_submit.
+	Args []string
+}
+
+// SubmitResult describes the jobs created by a submission.
+type SubmitResult struct {
+	// Cluster is the cluster ID of the first (usually only) cluster.
+	Cluster int64
+	// JobIDs are the IDs of every job created.
+	JobIDs []JobID
+}
+
+// Procs returns the number of jobs created.
+func (r *SubmitResult) Procs() int {
+	return len(r.JobIDs)
+}
+
+// Submit submits jobs to the schedd by running condor_submit with the
+// description on standard input, and returns the IDs of the jobs created. The
+// description is built with submit.New or parsed from a submit file with
+// submit.ParseFile, and must have at least one queue statement. Include
+// statements and relative paths in the description are resolved by
+// condor_submit in the working directory.
+func (s *Schedd) Submit(ctx context.Context, desc *submit.File, opts SubmitOptions) (*SubmitResult, error) {
+	if len(desc.Queues()) == 0 {
+		return nil, fmt.Errorf("condor_submit: no queue statement in submit description")
+	}
+	cmd := s.Command("condor_submit")
+	// a submission that failed, e.g. timed out, may still have queued jobs
+	cmd.opts.retry = nil
+	args := append(cmd.targetArgs(), "-terse")
+	if opts.Spool {
+		args = append(args, "-spool")
+	}
+	if opts.BatchName != "" {
+		args = append(args, "-batch-name", opts.BatchName)
+	}
+	args = append(args, opts.Args...)
+	args = append(args, "-")
+	var input bytes.Buffer
+	if _, err := desc.WriteTo(&input); err != nil {
+		return nil, fmt.Errorf("error writing submit description: %w", err)
+	}
+	stdout, stderr, err := cmd.runOutput(ctx, args, &input)
+	if err != nil {
+		return nil, newSubmitError(stderr, err)
+	}
+	res, err := parseTerseSubmitOutput(stdout)
+	if err != nil {
+		return nil, newSubmitError(stderr, err)
+	}
+	return res, nil
+}
+
+// parseTerseSubmitOutput parses the "first - last" job ranges printed by
+// condor_submit -terse, one per cluster.
+func parseTerseSubmitOutput(out []byte) (*SubmitResult, error) {
+	res := SubmitResult{JobIDs: make([]JobID, 0)}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := terseSubmitRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil {
+			continue
+		}
+		cluster, _ := strconv.ParseInt(m[1], 10, 64)
+		first, _ := strconv.ParseInt(m[2], 10, 64)
+		lastCluster, _ := strconv.ParseInt(m[3], 10, 64)
+		last, _ := strconv.ParseInt(m[4], 10, 64)
+		if lastCluster != cluster || last < first {
+			return nil, fmt.Errorf("invalid job range: \"%s\"", m[0])
+		}
+		if len(res.JobIDs) == 0 {
+			res.Cluster = cluster
+		}
+		for p := first; p <= last; p++ {
+			res.JobIDs = append(res.JobIDs, JobID{Cluster: cluster, Proc: p})
+		}
+	}
+	if len(res.JobIDs) == 0 {
+		return nil, fmt.Errorf("no jobs in condor_submit output: \"%s\"", strings.TrimSpace(string(out)))
+	}
+	return &res, nil
+}
+
+// SubmitError is returned when condor_submit fails.
+type SubmitError struct {
+	// Messages are the ERROR messages printed by condor_submit.
+	Messages []string
+	// Requirement is the name of the submit requirement that rejected the
+	// jobs, if condor_submit reported it.
+	Requirement string
+	// Stderr is the standard error output of condor_submit.
+	Stderr string
+	// Err is the underlying error.
+	Err error
+
+	requirement bool
+	syntax      bool
+}
+
+func newSubmitError(stderr []byte, err error) *SubmitError {
+	e := SubmitError{
+		Messages: make([]string, 0),
+		Stderr:   strings.TrimSpace(string(stderr)),
+		Err:      err,
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(stderr))
+	for scanner.Scan() {
+		m := submitErrorRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil || m[1] == "" {
+			continue
+		}
+		e.Messages = append(e.Messages, m[1])
+		if r := submitRequirementRegexp.FindStringSubmatch(m[1]); r != nil {
+			e.requirement = true
+			e.Requirement = r[1]
+		}
+		if submitSyntaxRegexp.MatchString(m[1]) {
+			e.syntax = true
+		}
+	}
+	return &e
+}
+
+func (e *SubmitError) Error() string {
+	if len(e.Messages) > 0 {
+		return "condor_submit failed: " + strings.Join(e.Messages, "; ")
+	}
+	if e.Stderr != "" {
+		return "condor_submit failed: " + e.Stderr
+	}
+	return "condor_submit failed: " + e.Err.Error()
+}
+
+func (e *SubmitError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is ErrSubmitRequirement or ErrSubmitSyntax.
+func (e *SubmitError) Is(target error) bool {
+	return (target == ErrSubmitRequirement && e.requirement) ||
+		(target == ErrSubmitSyntax && e.syntax)
+}
@@ -0,0 +1,467 @@
+package submit
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/retzkek/htcondor-go/classad"
+	"github.com/retzkek/htcondor-go/internal/macro"
+)
+
+// ExpandOptions configures File.Expand.
+type ExpandOptions struct {
+	// Cluster is the cluster ID to use for $(Cluster). Defaults to 1.
+	Cluster int
+	// Dir is the directory that include files, "queue from" files and
+	// "queue matching" patterns are relative to. Defaults to the current
+	// directory.
+	Dir string
+	// Macros are additional macros, as if set with -append or on the
+	// condor_submit command line.
+	Macros map[string]string
+	// Getenv looks up environment variables for $ENV(). Defaults to
+	// os.Getenv.
+	Getenv func(string) string
+}
+
+// Job is the description of a single job, produced by expanding a submit
+// description.
+type Job struct {
+	Cluster int
+	Proc    int
+	// Step is the index of the job within its item, from 0 to the queue
+	// count.
+	Step int
+	// ItemIndex is the index of the item the job was queued for.
+	ItemIndex int
+	// Vars are the queue variables set for the job.
+	Vars map[string]string
+	// Commands are the commands in effect for the job, with macros
+	// expanded. Later commands with the same key replace earlier ones.
+	Commands []Command
+}
+
+// Get returns the expanded value of a submit command. Keys are
+// case-insensitive.
+func (j *Job) Get(key string) (string, bool) {
+	for _, c := range j.Commands {
+		if strings.EqualFold(c.Key, key) {
+			return c.Value, true
+		}
+	}
+	return "", false
+}
+
+// Attributes returns the custom job attributes ("+Name" or "MY.Name"), keyed
+// by attribute name.
+func (j *Job) Attributes() map[string]string {
+	attrs := make(map[string]string)
+	for _, c := range j.Commands {
+		if name := attributeName(c.Key); name != "" {
+			attrs[name] = c.Value
+		}
+	}
+	return attrs
+}
+
+// Expand expands the submit description into the jobs that condor_submit
+// would queue, evaluating macros and queue statements.
+func (f *File) Expand(opts ExpandOptions) ([]*Job, error) {
+	if opts.Cluster == 0 {
+		opts.Cluster = 1
+	}
+	if opts.Getenv == nil {
+		opts.Getenv = os.Getenv
+	}
+	e := expander{
+		opts:   opts,
+		macros: make(map[string]string),
+	}
+	for k, v := range opts.Macros {
+		e.macros[strings.ToLower(k)] = v
+	}
+	if err := e.run(f, 0); err != nil {
+		return nil, err
+	}
+	return e.jobs, nil
+}
+
+type expander struct {
+	opts     ExpandOptions
+	macros   map[string]string
+	commands []Command
+	jobs     []*Job
+}
+
+// run processes the statements of a file, recursing into includes.
+func (e *expander) run(f *File, depth int) error {
+	if depth > macro.MaxDepth {
+		return fmt.Errorf("include files nested too deeply")
+	}
+	for _, s := range f.Statements {
+		switch s := s.(type) {
+		case *Command:
+			e.commands = append(e.commands, *s)
+			if !s.IsAttribute() {
+				e.macros[strings.ToLower(s.Key)] = s.Value
+			}
+		case *Include:
+			if s.Command {
+				return fmt.Errorf("line %d: include command is not supported", s.Line)
+			}
+			path, err := e.expand(s.Path, nil, 0)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", s.Line, err)
+			}
+			inc, err := ParseFile(e.path(path))
+			if err != nil {
+				return fmt.Errorf("line %d: error including %s: %w", s.Line, path, err)
+			}
+			if err := e.run(inc, depth+1); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		case *Queue:
+			if err := e.queue(s); err != nil {
+				return fmt.Errorf("line %d: %w", s.Line, err)
+			}
+		}
+	}
+	return nil
+}
+
+// path returns path relative to the base directory.
+func (e *expander) path(path string) string {
+	if filepath.IsAbs(path) || e.opts.Dir == "" {
+		return path
+	}
+	return filepath.Join(e.opts.Dir, path)
+}
+
+// queue queues the jobs for a queue statement.
+func (e *expander) queue(q *Queue) error {
+	count := 1
+	if q.Count != "" {
+		s, err := e.expand(q.Count, nil, 0)
+		if err != nil {
+			return err
+		}
+		count, err = strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || count < 0 {
+			return fmt.Errorf("invalid queue count \"%s\"", s)
+		}
+	}
+	vars := q.Vars
+	if len(vars) == 0 {
+		vars = []string{"Item"}
+	}
+
+	var items []map[string]string
+	switch q.Source {
+	case SourceCount:
+		items = []map[string]string{{}}
+	case SourceIn:
+		items = make([]map[string]string, len(q.Items))
+		for i, item := range q.Items {
+			items[i] = map[string]string{vars[0]: item}
+		}
+	case SourceFrom:
+		rows := q.Items
+		if q.File != "" {
+			var err error
+			if rows, err = e.readRows(q.File); err != nil {
+				return err
+			}
+		}
+		items = make([]map[string]string, len(rows))
+		for i, row := range rows {
+			items[i] = splitRow(row, vars)
+		}
+	case SourceMatching:
+		matches, err := e.match(q)
+		if err != nil {
+			return err
+		}
+		items = make([]map[string]string, len(matches))
+		for i, m := range matches {
+			items[i] = map[string]string{vars[0]: m}
+		}
+	}
+
+	indices, err := q.sliceIndices(len(items))
+	if err != nil {
+		return err
+	}
+	for _, i := range indices {
+		item := items[i]
+		for step := 0; step < count; step++ {
+			job := Job{
+				Cluster:   e.opts.Cluster,
+				Proc:      len(e.jobs),
+				Step:      step,
+				ItemIndex: i,
+				Vars:      item,
+			}
+			local := map[string]string{
+				"cluster":   strconv.Itoa(job.Cluster),
+				"clusterid": strconv.Itoa(job.Cluster),
+				"process":   strconv.Itoa(job.Proc),
+				"procid":    strconv.Itoa(job.Proc),
+				"step":      strconv.Itoa(step),
+				"itemindex": strconv.Itoa(i),
+				"row":       strconv.Itoa(i),
+			}
+			for k, v := range item {
+				local[strings.ToLower(k)] = v
+			}
+			cmds, err := e.expandCommands(local)
+			if err != nil {
+				return err
+			}
+			job.Commands = cmds
+			e.jobs = append(e.jobs, &job)
+		}
+	}
+	return nil
+}
+
+// expandCommands returns the commands in effect, with later values replacing
+// earlier ones, and their macros expanded.
+func (e *expander) expandCommands(local map[string]string) ([]Command, error) {
+	index := make(map[string]int)
+	cmds := make([]Command, 0, len(e.commands))
+	for _, c := range e.commands {
+		v, err := e.expand(c.Value, local, 0)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", c.Line, err)
+		}
+		key := strings.ToLower(c.Key)
+		if name := attributeName(c.Key); name != "" {
+			key = "+" + strings.ToLower(name)
+		}
+		if i, ok := index[key]; ok {
+			cmds[i].Value = v
+			continue
+		}
+		index[key] = len(cmds)
+		cmds = append(cmds, Command{Key: c.Key, Value: v, Line: c.Line})
+	}
+	return cmds, nil
+}
+
+// readRows reads the rows of a "queue from" file.
+func (e *expander) readRows(file string) ([]string, error) {
+	fh, err := os.Open(e.path(file))
+	if err != nil {
+		return nil, fmt.Errorf("error reading queue items: %w", err)
+	}
+	defer fh.Close()
+	rows := make([]string, 0)
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+		if l != "" && !strings.HasPrefix(l, "#") {
+			rows = append(rows, l)
+		}
+	}
+	return rows, scanner.Err()
+}
+
+// match returns the files or directories matching the patterns of a "queue
+// matching" statement, relative to the base directory.
+func (e *expander) match(q *Queue) ([]string, error) {
+	matches := make([]string, 0)
+	for _, pat := range q.Patterns {
+		paths, err := filepath.Glob(e.path(pat))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern \"%s\": %w", pat, err)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			fi, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			if (q.Match == "files" && fi.IsDir()) || (q.Match == "dirs" && !fi.IsDir()) {
+				continue
+			}
+			if e.opts.Dir != "" && !filepath.IsAbs(pat) {
+				if rel, err := filepath.Rel(e.opts.Dir, p); err == nil {
+					p = rel
+				}
+			}
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
+
+// splitRow splits a "queue from" row into its variables. Values are separated
+// by commas or whitespace; the last variable gets the rest of the row.
+func splitRow(row string, vars []string) map[string]string {
+	item := make(map[string]string, len(vars))
+	rest := row
+	for i, v := range vars {
+		rest = strings.TrimLeft(rest, ", \t")
+		if i == len(vars)-1 {
+			item[v] = strings.TrimSpace(rest)
+			break
+		}
+		j := strings.IndexAny(rest, ", \t")
+		if j < 0 {
+			item[v] = rest
+			rest = ""
+			continue
+		}
+		item[v] = rest[:j]
+		rest = rest[j:]
+	}
+	return item
+}
+
+// lookup returns the value of a macro, preferring the per-job macros.
+func (e *expander) lookup(name string, local map[string]string) (string, bool) {
+	name = strings.ToLower(name)
+	if v, ok := local[name]; ok {
+		return v, true
+	}
+	v, ok := e.macros[name]
+	return v, ok
+}
+
+// expand expands the macros in s: $(name), $(name:default), $ENV(name),
+// $INT(name[,format]), $REAL(name[,format]) and $CHOICE(index,list). Match-time
+// substitutions ($$(name)) are left alone, and undefined macros expand to the
+// empty string, as in condor_submit.
+func (e *expander) expand(s string, local map[string]string, depth int) (string, error) {
+	if depth > macro.MaxDepth {
+		return "", fmt.Errorf("macros nested too deeply in \"%s\"", s)
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		ref, ok := macro.Next(s, i)
+		if !ok {
+			b.WriteString(s[i:])
+			break
+		}
+		b.WriteString(s[i:ref.Start])
+		if ref.End < 0 {
+			return "", fmt.Errorf("unterminated macro in \"%s\"", s)
+		}
+		i = ref.End + 1
+		arg, err := e.expand(ref.Arg(s), local, depth+1)
+		if err != nil {
+			return "", err
+		}
+		if ref.Func == "$" {
+			// keep match-time substitution, but expand within it
+			b.WriteString("$$(" + arg + ")")
+			continue
+		}
+		v, err := e.function(ref.Func, arg, local, depth)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+	}
+	return b.String(), nil
+}
+
+// function evaluates a macro function; fn is empty for plain $(name).
+func (e *expander) function(fn, arg string, local map[string]string, depth int) (string, error) {
+	switch fn {
+	case "":
+		name, def, hasDef := strings.Cut(arg, ":")
+		if strings.EqualFold(name, "DOLLAR") {
+			return "$", nil
+		}
+		v, ok := e.lookup(name, local)
+		if !ok {
+			if hasDef {
+				return def, nil
+			}
+			return "", nil
+		}
+		return e.expand(v, local, depth+1)
+	case "ENV":
+		return e.opts.Getenv(strings.TrimSpace(arg)), nil
+	case "INT", "REAL":
+		args := strings.SplitN(arg, ",", 2)
+		val, err := e.evaluate(args[0], local, depth)
+		if err != nil {
+			return "", err
+		}
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return "", fmt.Errorf("$%s(%s): \"%s\" is not a number", fn, arg, val)
+		}
+		if fn == "INT" {
+			format := "%d"
+			if len(args) > 1 {
+				format = strings.TrimSpace(args[1])
+			}
+			return fmt.Sprintf(format, int64(f)), nil
+		}
+		format := "%g"
+		if len(args) > 1 {
+			format = strings.TrimSpace(args[1])
+		}
+		return fmt.Sprintf(format, f), nil
+	case "CHOICE":
+		args := strings.Split(arg, ",")
+		if len(args) < 2 {
+			return "", fmt.Errorf("$CHOICE(%s): expected index and list", arg)
+		}
+		val, err := e.evaluate(args[0], local, depth)
+		if err != nil {
+			return "", err
+		}
+		index, err := strconv.Atoi(val)
+		if err != nil {
+			return "", fmt.Errorf("$CHOICE(%s): invalid index \"%s\"", arg, val)
+		}
+		list := args[1:]
+		if len(list) == 1 {
+			// a single macro name holding the list
+			if v, ok := e.lookup(strings.TrimSpace(list[0]), local); ok {
+				expanded, err := e.expand(v, local, depth+1)
+				if err != nil {
+					return "", err
+				}
+				list = strings.Split(expanded, ",")
+			}
+		}
+		if index < 0 || index >= len(list) {
+			return "", fmt.Errorf("$CHOICE(%s): index %d out of range", arg, index)
+		}
+		return strings.TrimSpace(list[index]), nil
+	}
+	return "", fmt.Errorf("unknown macro function $%s()", fn)
+}
+
+// evaluate returns the value of a macro name or arithmetic expression, as
+// used by $INT, $REAL and $CHOICE.
+func (e *expander) evaluate(s string, local map[string]string, depth int) (string, error) {
+	s = strings.TrimSpace(s)
+	if v, ok := e.lookup(s, local); ok {
+		expanded, err := e.expand(v, local, depth+1)
+		if err != nil {
+			return "", err
+		}
+		s = expanded
+	}
+	x, err := classad.ParseExpr(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression \"%s\": %w", s, err)
+	}
+	v := x.Eval(classad.ClassAd{})
+	switch v.Type {
+	case classad.Integer, classad.Real:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("expression \"%s\" is not a number", s)
+}
@@ -0,0 +1,553 @@
+// Package submit models the HTCondor submit description file language. It can
+// generate submit files from Go, and parse and expand submit files into
+// per-job descriptions without contacting a schedd. A *File can be submitted
+// with htcondor.Schedd.Submit.
+package submit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Statement is a statement in a submit description: a *Command, *Queue or
+// *Include.
+type Statement interface {
+	// String returns the statement in submit file syntax.
+	String() string
+}
+
+// Command is a "key = value" submit command. Custom job attributes keep their
+// "+" or "MY." prefix in Key.
+type Command struct {
+	Key   string
+	Value string
+	// Line is the line number the command was read from, if parsed.
+	Line int
+}
+
+// String returns the command in submit file syntax.
+func (c *Command) String() string {
+	return c.Key + " = " + c.Value
+}
+
+// IsAttribute returns true if the command sets a custom job attribute
+// ("+Name" or "MY.Name").
+func (c *Command) IsAttribute() bool {
+	return attributeName(c.Key) != ""
+}
+
+// attributeName returns the attribute name of a custom attribute key, or ""
+// if the key is not a custom attribute.
+func attributeName(key string) string {
+	if strings.HasPrefix(key, "+") {
+		return key[1:]
+	}
+	if len(key) > 3 && strings.EqualFold(key[:3], "MY.") {
+		return key[3:]
+	}
+	return ""
+}
+
+// Include is an "include : file" statement. If Command is set, it is an
+// "include command : cmd" statement, which runs a command and includes its
+// output; these are not supported when expanding.
+type Include struct {
+	Path    string
+	Command bool
+	// Line is the line number the statement was read from, if parsed.
+	Line int
+}
+
+// String returns the statement in submit file syntax.
+func (i *Include) String() string {
+	if i.Command {
+		return "include command : " + i.Path
+	}
+	return "include : " + i.Path
+}
+
+// File is a submit description: a sequence of commands, include statements
+// and queue statements.
+type File struct {
+	Statements []Statement
+}
+
+// New creates an empty submit description.
+func New() *File {
+	return &File{}
+}
+
+// Set appends a submit command.
+func (f *File) Set(key, value string) *File {
+	f.Statements = append(f.Statements, &Command{Key: key, Value: value})
+	return f
+}
+
+// SetAttribute appends a custom job attribute ("+Name = value"). The value is
+// a ClassAd expression, so string values must be quoted.
+func (f *File) SetAttribute(name, value string) *File {
+	return f.Set("+"+name, value)
+}
+
+// Include appends an include statement.
+func (f *File) Include(path string) *File {
+	f.Statements = append(f.Statements, &Include{Path: path})
+	return f
+}
+
+// Queue appends a queue statement.
+func (f *File) Queue(q *Queue) *File {
+	f.Statements = append(f.Statements, q)
+	return f
+}
+
+// Commands returns the commands in the file, in order, not including those
+// in included files.
+func (f *File) Commands() []*Command {
+	cmds := make([]*Command, 0, len(f.Statements))
+	for _, s := range f.Statements {
+		if c, ok := s.(*Command); ok {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+// Queues returns the queue statements in the file.
+func (f *File) Queues() []*Queue {
+	queues := make([]*Queue, 0)
+	for _, s := range f.Statements {
+		if q, ok := s.(*Queue); ok {
+			queues = append(queues, q)
+		}
+	}
+	return queues
+}
+
+// WriteTo writes the submit description in submit file syntax.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	for _, s := range f.Statements {
+		b.WriteString(s.String())
+		b.WriteByte('\n')
+	}
+	return b.WriteTo(w)
+}
+
+// String returns the submit description in submit file syntax.
+func (f *File) String() string {
+	var b strings.Builder
+	f.WriteTo(&b)
+	return b.String()
+}
+
+// SyntaxError is returned when a submit description cannot be parsed.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ParseFile parses the submit description file at path.
+func ParseFile(path string) (*File, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return Parse(fh)
+}
+
+// Parse parses a submit description. Include statements are not followed
+// until the description is expanded.
+func Parse(r io.Reader) (*File, error) {
+	f := File{Statements: make([]Statement, 0)}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		start := lineNum
+		line := scanner.Text()
+		// join continued lines
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			lineNum++
+			line = strings.TrimSuffix(line, "\\") + scanner.Text()
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if isKeyword(line, "queue") {
+			// items in parentheses may span multiple lines
+			for strings.Contains(line, "(") && !strings.Contains(line[strings.Index(line, "("):], ")") {
+				if !scanner.Scan() {
+					return nil, &SyntaxError{start, "unterminated queue item list"}
+				}
+				lineNum++
+				next := strings.TrimSpace(scanner.Text())
+				if next == "" || strings.HasPrefix(next, "#") {
+					continue
+				}
+				line += "\n" + next
+			}
+			q, err := parseQueue(strings.TrimSpace(line[len("queue"):]))
+			if err != nil {
+				return nil, &SyntaxError{start, err.Error()}
+			}
+			q.Line = start
+			f.Statements = append(f.Statements, q)
+			continue
+		}
+		if isKeyword(line, "include") {
+			i := strings.Index(line, ":")
+			if i < 0 {
+				return nil, &SyntaxError{start, "include statement missing ':'"}
+			}
+			inc := Include{Path: strings.TrimSpace(line[i+1:]), Line: start}
+			switch mod := strings.ToLower(strings.TrimSpace(line[len("include"):i])); mod {
+			case "":
+			case "command":
+				inc.Command = true
+			default:
+				return nil, &SyntaxError{start, fmt.Sprintf("unsupported include modifier %q", mod)}
+			}
+			if inc.Path == "" {
+				return nil, &SyntaxError{start, "include statement missing file"}
+			}
+			f.Statements = append(f.Statements, &inc)
+			continue
+		}
+		for _, kw := range []string{"if", "elif", "else", "endif", "error", "warning"} {
+			if isKeyword(line, kw) {
+				return nil, &SyntaxError{start, fmt.Sprintf("unsupported statement %q", kw)}
+			}
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, &SyntaxError{start, fmt.Sprintf("invalid submit command: \"%s\"", line)}
+		}
+		key := strings.TrimSpace(line[:i])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, &SyntaxError{start, fmt.Sprintf("invalid submit command: \"%s\"", line)}
+		}
+		f.Statements = append(f.Statements, &Command{
+			Key:   key,
+			Value: strings.TrimSpace(line[i+1:]),
+			Line:  start,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// isKeyword returns true if line starts with the keyword kw, followed by
+// whitespace, punctuation that can't be part of a key, or the end of the line.
+func isKeyword(line, kw string) bool {
+	if len(line) < len(kw) || !strings.EqualFold(line[:len(kw)], kw) {
+		return false
+	}
+	if len(line) == len(kw) {
+		return true
+	}
+	switch line[len(kw)] {
+	case ' ', '\t', ':', '(':
+		rest := strings.TrimSpace(line[len(kw):])
+		// "queue = 1" is a command named queue, not a queue statement
+		return !strings.HasPrefix(rest, "=")
+	}
+	return false
+}
+
+// QueueSource is the form of a queue statement.
+type QueueSource int
+
+// Queue statement forms.
+const (
+	// SourceCount is "queue [N]".
+	SourceCount QueueSource = iota
+	// SourceIn is "queue [N] [var] in (item, ...)".
+	SourceIn
+	// SourceFrom is "queue [N] [vars] from file" or "from ( rows )".
+	SourceFrom
+	// SourceMatching is "queue [N] [var] matching [files|dirs] pattern ...".
+	SourceMatching
+)
+
+// Queue is a queue statement.
+type Queue struct {
+	// Count is the number of jobs to queue for each item, which may be a
+	// macro reference. If empty, one job is queued per item.
+	Count string
+	// Source is the form of the statement.
+	Source QueueSource
+	// Vars are the names of the variables set from each item. If empty,
+	// the item is assigned to "Item".
+	Vars []string
+	// Items are the items of "in" statements, or the rows of "from"
+	// statements with inline rows.
+	Items []string
+	// File is the file rows are read from, for "from file" statements.
+	File string
+	// Match restricts "matching" statements to "files" or "dirs". If empty,
+	// both are matched.
+	Match string
+	// Patterns are the glob patterns of "matching" statements.
+	Patterns []string
+	// Slice selects some of the items of "in", "from" and "matching"
+	// statements, in Python slice syntax, e.g. "[1:10:2]". If empty, every
+	// item is used.
+	Slice string
+	// Line is the line number the statement was read from, if parsed.
+	Line int
+}
+
+// QueueN returns a "queue N" statement.
+func QueueN(n int) *Queue {
+	return &Queue{Count: strconv.Itoa(n)}
+}
+
+// QueueIn returns a "queue var in (items)" statement.
+func QueueIn(v string, items ...string) *Queue {
+	return &Queue{Source: SourceIn, Vars: varList(v), Items: items}
+}
+
+// QueueFromRows returns a "queue vars from ( rows )" statement. Each row
+// holds the values of the variables, separated by commas or whitespace.
+func QueueFromRows(vars []string, rows ...string) *Queue {
+	return &Queue{Source: SourceFrom, Vars: vars, Items: rows}
+}
+
+// QueueFromFile returns a "queue vars from file" statement.
+func QueueFromFile(vars []string, file string) *Queue {
+	return &Queue{Source: SourceFrom, Vars: vars, File: file}
+}
+
+// QueueMatching returns a "queue var matching patterns" statement.
+func QueueMatching(v string, patterns ...string) *Queue {
+	return &Queue{Source: SourceMatching, Vars: varList(v), Patterns: patterns}
+}
+
+func varList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return []string{v}
+}
+
+// String returns the statement in submit file syntax.
+func (q *Queue) String() string {
+	parts := []string{"queue"}
+	if q.Count != "" {
+		parts = append(parts, q.Count)
+	}
+	if len(q.Vars) > 0 {
+		parts = append(parts, strings.Join(q.Vars, ","))
+	}
+	switch q.Source {
+	case SourceIn:
+		parts = append(parts, "in")
+		if q.Slice != "" {
+			parts = append(parts, q.Slice)
+		}
+		parts = append(parts, "("+strings.Join(q.Items, ", ")+")")
+	case SourceFrom:
+		parts = append(parts, "from")
+		if q.Slice != "" {
+			parts = append(parts, q.Slice)
+		}
+		if q.File != "" {
+			parts = append(parts, q.File)
+		} else {
+			parts = append(parts, "(\n"+strings.Join(q.Items, "\n")+"\n)")
+		}
+	case SourceMatching:
+		parts = append(parts, "matching")
+		if q.Slice != "" {
+			parts = append(parts, q.Slice)
+		}
+		if q.Match != "" {
+			parts = append(parts, q.Match)
+		}
+		parts = append(parts, q.Patterns...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// sliceIndices returns the indices of the items selected by the slice from n
+// items, with Python slice semantics: negative bounds count from the end, and
+// bounds are clamped to the items.
+func (q *Queue) sliceIndices(n int) ([]int, error) {
+	if q.Slice == "" {
+		indices := make([]int, n)
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices, nil
+	}
+	invalid := fmt.Errorf("invalid queue item slice \"%s\"", q.Slice)
+	if !strings.HasPrefix(q.Slice, "[") || !strings.HasSuffix(q.Slice, "]") {
+		return nil, invalid
+	}
+	parts := strings.Split(q.Slice[1:len(q.Slice)-1], ":")
+	if len(parts) > 3 {
+		return nil, invalid
+	}
+	bounds := []int{0, n, 1}
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, invalid
+		}
+		bounds[i] = v
+	}
+	if len(parts) == 1 {
+		// "[i]" selects a single item
+		bounds[1] = bounds[0] + 1
+		if bounds[0] == -1 {
+			bounds[1] = n
+		}
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step <= 0 {
+		return nil, invalid
+	}
+	clamp := func(i int) int {
+		if i < 0 {
+			i += n
+		}
+		return max(0, min(i, n))
+	}
+	start, end = clamp(start), clamp(end)
+	indices := make([]int, 0)
+	for i := start; i < end; i += step {
+		indices = append(indices, i)
+	}
+	return indices, nil
+}
+
+// parseQueue parses the arguments of a queue statement.
+func parseQueue(args string) (*Queue, error) {
+	q := Queue{}
+	head, kw, tail := splitQueueKeyword(args)
+	fields := strings.FieldsFunc(head, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) > 0 && isCount(fields[0]) {
+		q.Count = fields[0]
+		fields = fields[1:]
+	}
+	if kw == "" {
+		if len(fields) > 0 {
+			return nil, fmt.Errorf("invalid queue statement: \"queue %s\"", args)
+		}
+		return &q, nil
+	}
+	if strings.HasPrefix(tail, "[") {
+		i := strings.Index(tail, "]")
+		if i < 0 {
+			return nil, fmt.Errorf("unterminated queue item slice")
+		}
+		q.Slice = tail[:i+1]
+		if _, err := q.sliceIndices(0); err != nil {
+			return nil, err
+		}
+		tail = strings.TrimSpace(tail[i+1:])
+	}
+	switch kw {
+	case "in":
+		q.Source = SourceIn
+		q.Items = splitItems(trimParens(tail))
+	case "from":
+		q.Source = SourceFrom
+		if strings.HasPrefix(tail, "(") {
+			q.Items = splitRows(trimParens(tail))
+		} else if tail != "" {
+			q.File = tail
+		} else {
+			return nil, fmt.Errorf("queue from statement missing file")
+		}
+	case "matching":
+		q.Source = SourceMatching
+		pats := strings.Fields(trimParens(tail))
+		if len(pats) > 0 && (strings.EqualFold(pats[0], "files") || strings.EqualFold(pats[0], "dirs")) {
+			q.Match = strings.ToLower(pats[0])
+			pats = pats[1:]
+		}
+		if len(pats) == 0 {
+			return nil, fmt.Errorf("queue matching statement missing patterns")
+		}
+		q.Patterns = pats
+	}
+	q.Vars = fields
+	return &q, nil
+}
+
+// splitQueueKeyword splits queue arguments around the in, from or matching
+// keyword.
+func splitQueueKeyword(args string) (string, string, string) {
+	words := strings.Fields(args)
+	pos := 0
+	for _, w := range words {
+		i := strings.Index(args[pos:], w) + pos
+		lw := strings.ToLower(w)
+		if lw == "in" || lw == "from" || lw == "matching" {
+			return args[:i], lw, strings.TrimSpace(args[i+len(w):])
+		}
+		if strings.HasPrefix(w, "(") {
+			break
+		}
+		pos = i + len(w)
+	}
+	return args, "", ""
+}
+
+// isCount returns true if s looks like a queue count: an integer or a macro
+// reference.
+func isCount(s string) bool {
+	if _, err := strconv.Atoi(s); err == nil {
+		return true
+	}
+	return strings.HasPrefix(s, "$")
+}
+
+func trimParens(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		return strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// splitItems splits the items of a "queue in" statement, which are separated
+// by commas or whitespace.
+func splitItems(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// splitRows splits the rows of a "queue from" statement.
+func splitRows(s string) []string {
+	rows := make([]string, 0)
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "#") {
+			rows = append(rows, l)
+		}
+	}
+	return rows
+}
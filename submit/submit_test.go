@@ -0,0 +1,237 @@
+package submit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	f := New().
+		Set("executable", "hello.sh").
+		Set("arguments", "$(Process)").
+		SetAttribute("AccountingGroup", `"group_a.alice"`).
+		Set("executable", "bye.sh").
+		Queue(QueueIn("name", "a", "b"))
+	expected := `executable = hello.sh
+arguments = $(Process)
++AccountingGroup = "group_a.alice"
+executable = bye.sh
+queue name in (a, b)
+`
+	if s := f.String(); s != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, s)
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := `# a comment
+executable = hello.sh
+arguments  = one \
+two
+MY.Foo = "bar"
+include : common.sub
+queue 2 a,b from (
+  1 x
+  2 y
+)
+queue name matching files *.dat
+queue
+`
+	f, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := f.Commands()
+	if len(cmds) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(cmds))
+	}
+	if cmds[1].Value != "one two" {
+		t.Errorf("expected continued value \"one two\", got \"%s\"", cmds[1].Value)
+	}
+	if !cmds[2].IsAttribute() {
+		t.Errorf("expected MY.Foo to be an attribute")
+	}
+	qs := f.Queues()
+	if len(qs) != 3 {
+		t.Fatalf("expected 3 queue statements, got %d", len(qs))
+	}
+	if q := qs[0]; q.Source != SourceFrom || q.Count != "2" ||
+		!reflect.DeepEqual(q.Vars, []string{"a", "b"}) ||
+		!reflect.DeepEqual(q.Items, []string{"1 x", "2 y"}) {
+		t.Errorf("unexpected from statement %+v", q)
+	}
+	if q := qs[1]; q.Source != SourceMatching || q.Match != "files" ||
+		!reflect.DeepEqual(q.Patterns, []string{"*.dat"}) {
+		t.Errorf("unexpected matching statement %+v", q)
+	}
+	if q := qs[2]; q.Source != SourceCount || q.Count != "" {
+		t.Errorf("unexpected queue statement %+v", q)
+	}
+
+	// round trip
+	f2, err := Parse(strings.NewReader(f.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.String() != f2.String() {
+		t.Errorf("round trip mismatch:\n%s\n%s", f, f2)
+	}
+}
+
+func TestParse_bad(t *testing.T) {
+	tests := []string{
+		"executable",
+		"if $(foo)\nendif",
+		"queue 1 a in (x",
+		"queue a in [1:2 (x, y)",
+		"queue a in [x] (x, y)",
+		"queue a in [::0] (x, y)",
+	}
+	for _, src := range tests {
+		_, err := Parse(strings.NewReader(src))
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%q: expected syntax error, got %v", src, err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	src := `executable = run.sh
+base = /data
+arguments = $(Process) $(name) $(base)/$(name:none)
+output = out.$(Cluster).$(ProcId)
++Idx = $INT(Step,%02d)
++Half = $REAL($(ProcId) / 2.0,%.1f)
+color = $CHOICE(ProcId, red, green, blue)
+env = $ENV(HOME)
+req = $$(OpSys)
+price = $(DOLLAR)5
+queue 1 name in (x, y)
+base = /scratch
+queue 1
+`
+	f, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := f.Expand(ExpandOptions{
+		Cluster: 42,
+		Getenv:  func(string) string { return "/home/alice" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(jobs))
+	}
+	tests := []struct {
+		job   int
+		key   string
+		value string
+	}{
+		{0, "arguments", "0 x /data/x"},
+		{1, "arguments", "1 y /data/y"},
+		{2, "arguments", "2  /scratch/none"},
+		{1, "output", "out.42.1"},
+		{1, "+Idx", "00"},
+		{1, "+Half", "0.5"},
+		{2, "color", "blue"},
+		{0, "env", "/home/alice"},
+		{0, "req", "$$(OpSys)"},
+		{0, "price", "$5"},
+		{2, "base", "/scratch"},
+	}
+	for _, tt := range tests {
+		v, ok := jobs[tt.job].Get(tt.key)
+		if !ok || v != tt.value {
+			t.Errorf("job %d %s: expected \"%s\", got \"%s\"", tt.job, tt.key, tt.value, v)
+		}
+	}
+	if attrs := jobs[0].Attributes(); attrs["Idx"] != "00" {
+		t.Errorf("expected attribute Idx=00, got %v", attrs)
+	}
+}
+
+func TestExpand_slices(t *testing.T) {
+	tests := []struct {
+		queue string
+		items []string
+	}{
+		{"queue name in [1:3] (a, b, c, d)", []string{"b", "c"}},
+		{"queue name in [::2] (a, b, c, d)", []string{"a", "c"}},
+		{"queue name in [-2:] (a, b, c, d)", []string{"c", "d"}},
+		{"queue name in [2] (a, b, c, d)", []string{"c"}},
+		{"queue name in [:10] (a, b)", []string{"a", "b"}},
+		{"queue name, n from [1:] (\na 1\nb 2\nc 3\n)", []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		f, err := Parse(strings.NewReader("executable = run.sh\narguments = $(name)\n" + tt.queue + "\n"))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.queue, err)
+		}
+		// the slice survives a round trip
+		if f2, err := Parse(strings.NewReader(f.String())); err != nil || f2.Queues()[0].Slice != f.Queues()[0].Slice {
+			t.Errorf("%s: round trip got %v (%v)", tt.queue, f2, err)
+		}
+		jobs, err := f.Expand(ExpandOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.queue, err)
+		}
+		items := make([]string, len(jobs))
+		for i, j := range jobs {
+			items[i], _ = j.Get("arguments")
+		}
+		if !reflect.DeepEqual(items, tt.items) {
+			t.Errorf("%s: expected %v, got %v", tt.queue, tt.items, items)
+		}
+	}
+}
+
+func TestExpand_files(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"common.sub": "universe = vanilla\n",
+		"items.txt":  "# header\na 1\nb 2\n",
+		"in1.dat":    "",
+		"in2.dat":    "",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d.dat"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := `include : common.sub
+arguments = $(name) $(n)
+queue name,n from items.txt
+queue 2 name matching files *.dat
+`
+	f, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := f.Expand(ExpandOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := make([]string, len(jobs))
+	for i, j := range jobs {
+		args[i], _ = j.Get("arguments")
+		if u, _ := j.Get("universe"); u != "vanilla" {
+			t.Errorf("job %d: expected included universe, got \"%s\"", i, u)
+		}
+	}
+	expected := []string{"a 1", "b 2", "in1.dat ", "in1.dat ", "in2.dat ", "in2.dat "}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected arguments %q, got %q", expected, args)
+	}
+	if jobs[3].Step != 1 || jobs[3].ItemIndex != 0 || jobs[3].Proc != 3 {
+		t.Errorf("unexpected job %+v", jobs[3])
+	}
+}
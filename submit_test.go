@@ -0,0 +1,54 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/retzkek/htcondor-go/submit"
+)
+
+func TestSubmitNoQueue(t *testing.T) {
+	fake := &fakeExecutor{}
+	desc := submit.New().Set("executable", "/bin/true")
+	if _, err := NewSchedd("", "").WithExecutor(fake).Submit(context.Background(), desc, SubmitOptions{}); err == nil || len(fake.calls) != 0 {
+		t.Errorf("expected error without running condor_submit, got %v after %d calls", err, len(fake.calls))
+	}
+}
+
+func TestParseTerseSubmitOutput(t *testing.T) {
+	res, err := parseTerseSubmitOutput([]byte("42.0 - 42.2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Cluster != 42 || res.Procs() != 3 ||
+		!reflect.DeepEqual(res.JobIDs, []JobID{{42, 0}, {42, 1}, {42, 2}}) {
+		t.Errorf("unexpected result %+v", res)
+	}
+	for _, s := range []string{"", "Submitting job(s).\n", "42.3 - 42.1\n", "42.0 - 43.0\n"} {
+		if _, err := parseTerseSubmitOutput([]byte(s)); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestSubmitError(t *testing.T) {
+	cause := errors.New("exit status 1")
+	err := error(newSubmitError([]byte(`
+ERROR: Failed to commit job submission into the queue.
+ERROR: Submit requirement NotTooMuchMemory evaluated to non-true
+`), cause))
+	if !errors.Is(err, ErrSubmitRequirement) || errors.Is(err, ErrSubmitSyntax) || !errors.Is(err, cause) {
+		t.Errorf("unexpected error chain: %v", err)
+	}
+	var e *SubmitError
+	if !errors.As(err, &e) || e.Requirement != "NotTooMuchMemory" || len(e.Messages) != 2 {
+		t.Errorf("unexpected error %+v", e)
+	}
+
+	err = newSubmitError([]byte("\nERROR: on Line 3 of submit file: \nsubmit: syntax error\n"), cause)
+	if !errors.Is(err, ErrSubmitSyntax) || errors.Is(err, ErrSubmitRequirement) {
+		t.Errorf("unexpected error chain: %v", err)
+	}
+}
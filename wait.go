@@ -0,0 +1,221 @@
+package htcondor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrWaitTimeout is returned by WaitForJobs when the timeout expires before
+// all jobs are done.
+var ErrWaitTimeout = errors.New("timed out waiting for jobs")
+
+// DefaultWaitPollInterval is how often WaitForJobs checks the event log for
+// new events if WaitOptions.PollInterval is not set.
+const DefaultWaitPollInterval = time.Second
+
+// WaitOptions configures WaitForJobs.
+type WaitOptions struct {
+	// Timeout is the maximum time to wait. If zero, wait until the context is
+	// done.
+	Timeout time.Duration
+	// PollInterval is how often to check the event log for new events.
+	PollInterval time.Duration
+}
+
+// JobOutcome describes how a job left the queue (or was held).
+type JobOutcome struct {
+	// JobID is the job.
+	JobID JobID
+	// Event is the event that ended the wait for this job: JobTerminatedEvent,
+	// JobAbortedEvent or JobHeldEvent.
+	Event EventType
+	// Time is when the event was logged.
+	Time time.Time
+	// ExitCode is the return value of a job that terminated normally.
+	ExitCode int
+	// ExitBySignal is true if the job was terminated by a signal.
+	ExitBySignal bool
+	// Signal is the signal that terminated the job.
+	Signal int
+	// Reason is the hold reason for held jobs, or the removal reason for
+	// removed jobs.
+	Reason string
+	// HoldReasonCode and HoldReasonSubCode are set for held jobs.
+	HoldReasonCode    HoldReasonCode
+	HoldReasonSubCode HoldReasonSubCode
+}
+
+// Terminated returns true if the job ran to completion, whether or not it
+// succeeded.
+func (o JobOutcome) Terminated() bool {
+	return o.Event == JobTerminatedEvent
+}
+
+// Removed returns true if the job was removed.
+func (o JobOutcome) Removed() bool {
+	return o.Event == JobAbortedEvent
+}
+
+// Held returns true if the job was held.
+func (o JobOutcome) Held() bool {
+	return o.Event == JobHeldEvent
+}
+
+// WaitForJobs watches the job event log at logPath until every job in jobIDs
+// has terminated, been removed, or been held, and returns the outcome of each
+// job. It is a pure-Go equivalent of condor_wait.
+//
+// A job ID with a negative Proc waits for every job in the cluster that has
+// been submitted to the log. If jobIDs is empty, WaitForJobs waits for every
+// job in the log. A held job that is later released is waited on again.
+//
+// If the timeout expires or the context is cancelled, the outcomes seen so far
+// are returned along with ErrWaitTimeout or the context error.
+func WaitForJobs(ctx context.Context, logPath string, jobIDs []JobID, opts WaitOptions) (map[JobID]JobOutcome, error) {
+	ctx, span := tracer.Start(ctx, "WaitForJobs")
+	defer span.End()
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultWaitPollInterval
+	}
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening event log: %w", err)
+	}
+	defer f.Close()
+
+	w := newJobWaiter(jobIDs)
+	r := NewEventLogReader(f)
+	for {
+		for {
+			ev, err := r.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return w.outcomes, fmt.Errorf("error reading event log: %w", err)
+			}
+			w.handle(ev)
+		}
+		if w.done() {
+			return w.outcomes, nil
+		}
+		select {
+		case <-ctx.Done():
+			return w.outcomes, ctx.Err()
+		case <-timeout:
+			return w.outcomes, ErrWaitTimeout
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
+
+// jobWaiter tracks the state of the jobs being waited on.
+type jobWaiter struct {
+	exact    map[JobID]bool
+	clusters map[int64]bool
+	all      bool
+	seen     map[JobID]bool
+	outcomes map[JobID]JobOutcome
+}
+
+func newJobWaiter(jobIDs []JobID) *jobWaiter {
+	w := jobWaiter{
+		exact:    make(map[JobID]bool),
+		clusters: make(map[int64]bool),
+		all:      len(jobIDs) == 0,
+		seen:     make(map[JobID]bool),
+		outcomes: make(map[JobID]JobOutcome),
+	}
+	for _, id := range jobIDs {
+		if id.Proc < 0 {
+			w.clusters[id.Cluster] = true
+		} else {
+			w.exact[id] = true
+		}
+	}
+	return &w
+}
+
+// wanted returns true if the job is one we are waiting on.
+func (w *jobWaiter) wanted(id JobID) bool {
+	return id.Proc >= 0 && (w.all || w.exact[id] || w.clusters[id.Cluster])
+}
+
+// handle updates the job states with an event.
+func (w *jobWaiter) handle(ev *Event) {
+	if !w.wanted(ev.JobID) {
+		return
+	}
+	w.seen[ev.JobID] = true
+	switch ev.Type {
+	case JobTerminatedEvent:
+		w.outcomes[ev.JobID] = JobOutcome{
+			JobID:        ev.JobID,
+			Event:        ev.Type,
+			Time:         ev.Time,
+			ExitCode:     ev.ReturnValue,
+			ExitBySignal: ev.TerminatedBySignal,
+			Signal:       ev.Signal,
+		}
+	case JobAbortedEvent:
+		w.outcomes[ev.JobID] = JobOutcome{
+			JobID:  ev.JobID,
+			Event:  ev.Type,
+			Time:   ev.Time,
+			Reason: ev.Reason,
+		}
+	case JobHeldEvent:
+		w.outcomes[ev.JobID] = JobOutcome{
+			JobID:             ev.JobID,
+			Event:             ev.Type,
+			Time:              ev.Time,
+			Reason:            ev.Reason,
+			HoldReasonCode:    ev.HoldReasonCode,
+			HoldReasonSubCode: ev.HoldReasonSubCode,
+		}
+	case JobReleasedEvent:
+		if o, ok := w.outcomes[ev.JobID]; ok && o.Held() {
+			delete(w.outcomes, ev.JobID)
+		}
+	}
+}
+
+// done returns true once every job being waited on has an outcome.
+func (w *jobWaiter) done() bool {
+	for id := range w.exact {
+		if _, ok := w.outcomes[id]; !ok {
+			return false
+		}
+	}
+	for cluster := range w.clusters {
+		n := 0
+		for id := range w.seen {
+			if id.Cluster == cluster {
+				n++
+			}
+		}
+		if n == 0 {
+			return false
+		}
+	}
+	if w.all && len(w.seen) == 0 {
+		return false
+	}
+	for id := range w.seen {
+		if _, ok := w.outcomes[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
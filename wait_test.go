@@ -0,0 +1,69 @@
+package htcondor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitForJobs(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logPath, []byte(eventLog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outcomes, err := WaitForJobs(context.Background(), logPath, []JobID{{42, -1}, {43, 0}}, WaitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outcomes) != 3 {
+		t.Fatalf("expected 3 outcomes, got %d", len(outcomes))
+	}
+	if o := outcomes[JobID{42, 0}]; !o.Terminated() || o.ExitCode != 3 {
+		t.Errorf("bad outcome for 42.0: %+v", o)
+	}
+	if o := outcomes[JobID{42, 1}]; !o.Terminated() || !o.ExitBySignal || o.Signal != 9 {
+		t.Errorf("bad outcome for 42.1: %+v", o)
+	}
+	if o := outcomes[JobID{43, 0}]; !o.Removed() {
+		t.Errorf("bad outcome for 43.0: %+v", o)
+	}
+}
+
+func TestWaitForJobs_timeout(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "job.log")
+	if err := os.WriteFile(logPath, []byte(eventLog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outcomes, err := WaitForJobs(context.Background(), logPath, []JobID{{42, 0}, {44, 0}},
+		WaitOptions{Timeout: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+	if err != ErrWaitTimeout {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if _, ok := outcomes[JobID{42, 0}]; !ok {
+		t.Error("expected outcome for 42.0")
+	}
+}
+
+func TestWaitForJobs_held(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "job.log")
+	f, err := os.Create(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("000 (007.000.000) 2024-01-02 10:00:00 Job submitted from host: <10.0.0.1:9618>\n...\n")
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		f.WriteString("012 (007.000.000) 2024-01-02 10:01:30 Job was held.\n\tvia condor_hold (by user tester)\n\tCode 1 Subcode 0\n...\n")
+	}()
+	outcomes, err := WaitForJobs(context.Background(), logPath, nil,
+		WaitOptions{Timeout: 5 * time.Second, PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := outcomes[JobID{7, 0}]; !o.Held() || o.HoldReasonCode != 1 || o.Reason != "via condor_hold (by user tester)" {
+		t.Errorf("bad outcome for 7.0: %+v", o)
+	}
+}